@@ -0,0 +1,46 @@
+package engram
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Archive hides a memory from all retrieval (search, context, reflection input)
+// without deleting it. It stays recoverable via Restore until the decay sweep
+// purges it after Config.ArchiveRetention.
+func (cm *Engram) Archive(memoryID int64) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.store.ArchiveMemory(memoryID); err != nil {
+		return archiveError("archive", memoryID, err)
+	}
+	return nil
+}
+
+// Restore makes an archived memory retrievable again.
+func (cm *Engram) Restore(memoryID int64) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.store.RestoreMemory(memoryID); err != nil {
+		return archiveError("restore", memoryID, err)
+	}
+	return nil
+}
+
+func archiveError(op string, memoryID int64, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("engram: %s: memory %d not found", op, memoryID)
+	}
+	return fmt.Errorf("engram: %s memory %d: %w", op, memoryID, err)
+}
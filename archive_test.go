@@ -0,0 +1,62 @@
+package engram
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchiveRestoreAndPurge(t *testing.T) {
+	cm := testEngramConfig(t, Config{
+		EmbeddingProvider: &mockEmbedder{dim: 3},
+		ArchiveRetention:  24 * time.Hour,
+	})
+
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "My sister is Maya", Vector: []float32{1, 0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func() []SearchResult {
+		return cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+	}
+
+	if err := cm.Archive(id); err != nil {
+		t.Fatal(err)
+	}
+	if results := search(); len(results) != 0 {
+		t.Errorf("expected archived memory hidden from search, got %v", results)
+	}
+
+	if err := cm.Restore(id); err != nil {
+		t.Fatal(err)
+	}
+	if results := search(); len(results) != 1 || results[0].ID != id {
+		t.Errorf("expected restored memory back in search, got %v", results)
+	}
+
+	// Archive again and age past retention
+	cm.Archive(id)
+	old := time.Now().UTC().Add(-48 * time.Hour).Format("2006-01-02 15:04:05")
+	cm.store.db.Exec(`UPDATE memories SET archived_at = ? WHERE id = ?`, old, id)
+	cm.runDecaySweep()
+
+	var count int
+	cm.store.db.QueryRow(`SELECT COUNT(*) FROM memories WHERE id = ?`, id).Scan(&count)
+	if count != 0 {
+		t.Error("expected archived memory to be hard-deleted after retention")
+	}
+	if err := cm.Restore(id); err == nil {
+		t.Error("expected error restoring a purged memory")
+	}
+}
+
+func TestArchiveWithinRetentionSurvivesSweep(t *testing.T) {
+	cm := testEngramConfig(t, Config{ArchiveRetention: 24 * time.Hour})
+
+	id, _ := cm.store.InsertMemory(Memory{Content: "kept", Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+	cm.Archive(id)
+	cm.runDecaySweep()
+
+	if err := cm.Restore(id); err != nil {
+		t.Errorf("expected archived memory within retention to be restorable: %v", err)
+	}
+}
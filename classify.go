@@ -2,41 +2,137 @@ package engram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // HeuristicClassifier determines which cognitive sector a memory belongs to.
 // Uses a keyword heuristic first (zero-cost), falls back to Gemini for ambiguous content.
 // Implements SectorClassifier.
 type HeuristicClassifier struct {
-	apiKey string
-	client *http.Client
+	apiKey       string
+	baseURL      string // Gemini API URL (overridable for tests)
+	client       *http.Client
+	fallback     Sector          // sector for content with no keyword signal
+	priority     []Sector        // tie-break order among equally scored sectors
+	syncFallback bool            // ask Gemini inline about low-confidence content
+	stopwords    map[string]bool // filler words skipped when matching signals
+}
+
+// HeuristicOption configures a HeuristicClassifier.
+type HeuristicOption func(*HeuristicClassifier)
+
+// WithFallbackSector sets the sector for content with no keyword signal at
+// all, such as "hmm, okay" (default: semantic). An emotionally driven
+// character might prefer SectorEmotional.
+func WithFallbackSector(sector Sector) HeuristicOption {
+	return func(c *HeuristicClassifier) { c.fallback = sector }
+}
+
+// WithSectorPriority sets which sector wins when several score equally,
+// earliest first (default: semantic, episodic, emotional, procedural,
+// reflective). Sectors left out rank after the listed ones, in default order.
+func WithSectorPriority(sectors ...Sector) HeuristicOption {
+	return func(c *HeuristicClassifier) {
+		priority := append([]Sector(nil), sectors...)
+		for _, s := range defaultSectorPriority() {
+			if !containsSector(priority, s) {
+				priority = append(priority, s)
+			}
+		}
+		c.priority = priority
+	}
+}
+
+// WithSyncFallback controls whether Classify calls Gemini inline for
+// low-confidence content (default true when an API key is set). Disable it
+// on latency-sensitive Add paths: low-confidence content then gets the
+// heuristic's best guess, which LLMClassifier can correct asynchronously.
+func WithSyncFallback(enabled bool) HeuristicOption {
+	return func(c *HeuristicClassifier) { c.syncFallback = enabled }
+}
+
+// WithClassifyTimeout bounds each synchronous Gemini call (default 5s).
+func WithClassifyTimeout(d time.Duration) HeuristicOption {
+	return func(c *HeuristicClassifier) { c.client.Timeout = d }
+}
+
+func defaultSectorPriority() []Sector {
+	return []Sector{SectorSemantic, SectorEpisodic, SectorEmotional, SectorProcedural, SectorReflective}
+}
+
+func containsSector(sectors []Sector, s Sector) bool {
+	for _, x := range sectors {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultStopwords are fillers that don't change what a phrase signals, so
+// "is really a regular" still matches "is a".
+var defaultStopwords = []string{
+	"um", "uh", "really", "very", "just", "quite", "actually", "truly",
+	"totally", "basically", "honestly", "pretty",
+}
+
+// WithStopwords replaces the filler words skipped when matching multi-word
+// signals (default: um, uh, really, very, just, ...). Pass none to match
+// signals only as exact word runs.
+func WithStopwords(words ...string) HeuristicOption {
+	return func(c *HeuristicClassifier) { c.stopwords = stopwordSet(words) }
+}
+
+func stopwordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[strings.ToLower(w)] = true
+	}
+	return set
 }
 
 // NewHeuristicClassifier creates a sector classifier.
 // If apiKey is empty, only heuristic classification is used (no LLM fallback).
-func NewHeuristicClassifier(apiKey string) *HeuristicClassifier {
-	return &HeuristicClassifier{
-		apiKey: apiKey,
-		client: &http.Client{Timeout: 5 * time.Second},
+func NewHeuristicClassifier(apiKey string, opts ...HeuristicOption) *HeuristicClassifier {
+	c := &HeuristicClassifier{
+		apiKey:       apiKey,
+		baseURL:      "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash-lite:generateContent",
+		client:       &http.Client{Timeout: 5 * time.Second},
+		fallback:     SectorSemantic,
+		priority:     defaultSectorPriority(),
+		syncFallback: true,
+		stopwords:    stopwordSet(defaultStopwords),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Classify determines the sector for a piece of memory content.
 func (c *HeuristicClassifier) Classify(content string) Sector {
+	return c.ClassifyContext(context.Background(), content)
+}
+
+// ClassifyContext is Classify with a context bounding the synchronous Gemini
+// fallback; on cancellation the heuristic's best guess is returned.
+func (c *HeuristicClassifier) ClassifyContext(ctx context.Context, content string) Sector {
 	sector, confidence := c.heuristicClassify(content)
 	if confidence >= 0.6 {
 		return sector
 	}
 
 	// Low confidence: try Gemini for disambiguation
-	if c.apiKey != "" {
-		if geminiSector, err := c.geminiClassify(content); err == nil {
+	if c.apiKey != "" && c.syncFallback {
+		if geminiSector, err := c.geminiClassify(ctx, content); err == nil {
 			return geminiSector
 		} else {
 			log.Printf("[engram] Gemini classify fallback failed: %v", err)
@@ -46,10 +142,47 @@ func (c *HeuristicClassifier) Classify(content string) Sector {
 	return sector // fallback to heuristic even if low confidence
 }
 
+// ClassifyExchange classifies an exchange from its halves scored separately,
+// the user's keyword scores weighted by userWeight and the assistant's by the
+// rest, so the user's framing decides a question answered with facts.
+// Low-confidence results fall back to Gemini on the joined exchange, like
+// Classify. Implements ExchangeClassifier.
+func (c *HeuristicClassifier) ClassifyExchange(userMessage, assistantMessage string, userWeight float64) Sector {
+	sector, confidence := c.exchangeClassify(userMessage, assistantMessage, userWeight)
+	if confidence >= 0.6 {
+		return sector
+	}
+	if c.apiKey != "" && c.syncFallback {
+		if geminiSector, err := c.geminiClassify(context.Background(), userMessage+" | "+assistantMessage); err == nil {
+			return geminiSector
+		} else {
+			log.Printf("[engram] Gemini classify fallback failed: %v", err)
+		}
+	}
+	return sector
+}
+
+// exchangeClassify is heuristicClassify over the weighted sum of each half's
+// sector scores.
+func (c *HeuristicClassifier) exchangeClassify(userMessage, assistantMessage string, userWeight float64) (Sector, float64) {
+	userWeight = math.Max(0, math.Min(1, userWeight))
+	scores := c.sectorScores(userMessage)
+	for sector, score := range c.sectorScores(assistantMessage) {
+		scores[sector] = userWeight*scores[sector] + (1-userWeight)*score
+	}
+	return c.bestSector(scores)
+}
+
 // heuristicClassify uses keyword matching to classify content into a sector.
 // Returns the best sector and a confidence score (0.0-1.0).
 func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64) {
-	lower := strings.ToLower(content)
+	return c.bestSector(c.sectorScores(content))
+}
+
+// sectorScores sums keyword signals per sector, 0.3 per signal matched as
+// whole words (see signalMatcher).
+func (c *HeuristicClassifier) sectorScores(content string) map[Sector]float64 {
+	words := newSignalMatcher(content, c.stopwords)
 
 	scores := map[Sector]float64{
 		SectorEpisodic:   0,
@@ -66,7 +199,7 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 		"that time", "the other day", "first time", "came back", "returned",
 	}
 	for _, s := range episodicSignals {
-		if strings.Contains(lower, s) {
+		if words.matches(s) {
 			scores[SectorEpisodic] += 0.3
 		}
 	}
@@ -78,7 +211,7 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 		"from", "lives in", "speaks", "knows about",
 	}
 	for _, s := range semanticSignals {
-		if strings.Contains(lower, s) {
+		if words.matches(s) {
 			scores[SectorSemantic] += 0.3
 		}
 	}
@@ -89,7 +222,7 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 		"method", "approach", "process", "step", "instruction",
 	}
 	for _, s := range proceduralSignals {
-		if strings.Contains(lower, s) {
+		if words.matches(s) {
 			scores[SectorProcedural] += 0.3
 		}
 	}
@@ -102,7 +235,7 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 		"sweet", "nice", "mean", "fun", "boring",
 	}
 	for _, s := range emotionalSignals {
-		if strings.Contains(lower, s) {
+		if words.matches(s) {
 			scores[SectorEmotional] += 0.3
 		}
 	}
@@ -111,19 +244,24 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 	reflectiveSignals := []string{
 		"pattern", "notice that", "tend to", "seem to", "often",
 		"every time", "consistently", "in general", "overall",
-		"reflects", "suggests", "implies", "correlat",
+		"reflects", "suggests", "implies", "correlat*",
 	}
 	for _, s := range reflectiveSignals {
-		if strings.Contains(lower, s) {
+		if words.matches(s) {
 			scores[SectorReflective] += 0.3
 		}
 	}
 
-	// Find highest scoring sector
-	bestSector := SectorSemantic // default
+	return scores
+}
+
+// bestSector picks the highest scoring sector and its confidence (the score,
+// capped at 1.0); ties go to the earliest in priority order.
+func (c *HeuristicClassifier) bestSector(scores map[Sector]float64) (Sector, float64) {
+	bestSector := c.fallback
 	bestScore := 0.0
-	for sector, score := range scores {
-		if score > bestScore {
+	for _, sector := range c.priority {
+		if score := scores[sector]; score > bestScore {
 			bestScore = score
 			bestSector = sector
 		}
@@ -139,8 +277,8 @@ func (c *HeuristicClassifier) heuristicClassify(content string) (Sector, float64
 }
 
 // geminiClassify uses Gemini to classify content when heuristics are ambiguous.
-func (c *HeuristicClassifier) geminiClassify(content string) (Sector, error) {
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash-lite:generateContent?key=" + c.apiKey
+func (c *HeuristicClassifier) geminiClassify(ctx context.Context, content string) (Sector, error) {
+	url := c.baseURL + "?key=" + c.apiKey
 
 	prompt := `Classify this memory into exactly one sector. Reply with ONLY the sector name, nothing else.
 Sectors: episodic (events/experiences), semantic (facts/knowledge), emotional (feelings/sentiment), procedural (skills/how-to), reflective (patterns/insights)
@@ -162,7 +300,7 @@ Memory: "` + content + `"`
 		return SectorSemantic, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return SectorSemantic, err
 	}
@@ -224,3 +362,67 @@ func (e *classifyError) Error() string {
 	}
 	return "gemini classify: " + e.body
 }
+
+// signalMatcher matches keyword signals against content's words, so a signal
+// never fires from inside another word ("skill" in "skillet", "into" in
+// "pintos"). Stopwords are dropped from the content first.
+type signalMatcher []string
+
+func newSignalMatcher(content string, stopwords map[string]bool) signalMatcher {
+	var words signalMatcher
+	for _, w := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		if !stopwords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// matches reports whether signal's words occur consecutively. Each signal
+// word also matches its simple inflections ("feel" matches "feels" and
+// "feeling"); a trailing '*' marks a stem matching any word it begins
+// ("correlat*" matches "correlation").
+func (m signalMatcher) matches(signal string) bool {
+	parts := strings.Fields(signal)
+	for i := 0; i+len(parts) <= len(m); i++ {
+		ok := true
+		for j, part := range parts {
+			if !wordMatches(m[i+j], part) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inflections are the suffixes a signal word may carry and still match.
+var inflections = []string{"s", "es", "ed", "d", "ing", "er", "ers", "ly"}
+
+func wordMatches(word, signal string) bool {
+	if stem, ok := strings.CutSuffix(signal, "*"); ok {
+		return strings.HasPrefix(word, stem)
+	}
+	if word == signal {
+		return true
+	}
+	rest, ok := strings.CutPrefix(word, signal)
+	if !ok {
+		// "love" -> "loving"
+		if base, silentE := strings.CutSuffix(signal, "e"); silentE {
+			return word == base+"ing"
+		}
+		return false
+	}
+	for _, suffix := range inflections {
+		if rest == suffix {
+			return true
+		}
+	}
+	return false
+}
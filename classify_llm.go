@@ -2,7 +2,9 @@ package engram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -88,31 +90,38 @@ func (lc *LLMClassifier) worker() {
 	}
 }
 
-// reclassify calls Gemini to classify the content and updates the DB if
-// the LLM sector differs from the heuristic sector.
+// reclassify is the async worker's path: failures are logged, not returned.
 func (lc *LLMClassifier) reclassify(req reclassRequest) {
-	llmSector, err := lc.llmClassify(req.content)
-	if err != nil {
+	if err := lc.ReclassifySync(context.Background(), req.memoryID, req.content); err != nil {
 		log.Printf("[engram] LLM reclassify failed for memory #%d: %v", req.memoryID, err)
-		return
+	}
+}
+
+// ReclassifySync calls Gemini to classify the content and updates the DB if
+// the LLM sector differs from the heuristic sector, blocking until done. Use it
+// where the final sector must be known immediately (tests, bulk imports).
+func (lc *LLMClassifier) ReclassifySync(ctx context.Context, memoryID int64, content string) error {
+	llmSector, err := lc.llmClassify(ctx, content)
+	if err != nil {
+		return err
 	}
 
 	// Only update if LLM disagrees with the heuristic
-	heuristicSector, _ := lc.heuristic.heuristicClassify(req.content)
+	heuristicSector, _ := lc.heuristic.heuristicClassify(content)
 	if llmSector == heuristicSector {
-		return
+		return nil
 	}
 
-	if err := lc.store.UpdateMemorySector(req.memoryID, llmSector); err != nil {
-		log.Printf("[engram] Update sector failed for memory #%d: %v", req.memoryID, err)
-		return
+	if err := lc.store.UpdateMemorySector(memoryID, llmSector); err != nil {
+		return fmt.Errorf("update sector: %w", err)
 	}
 
-	log.Printf("[engram] Reclassified memory #%d: %s → %s", req.memoryID, heuristicSector, llmSector)
+	log.Printf("[engram] Reclassified memory #%d: %s → %s", memoryID, heuristicSector, llmSector)
+	return nil
 }
 
 // llmClassify calls Gemini to classify content into a sector.
-func (lc *LLMClassifier) llmClassify(content string) (Sector, error) {
+func (lc *LLMClassifier) llmClassify(ctx context.Context, content string) (Sector, error) {
 	url := lc.baseURL + "?key=" + lc.apiKey
 
 	prompt := `Classify this memory into exactly one cognitive sector. Reply with ONLY the sector name, nothing else.
@@ -141,7 +150,7 @@ Memory: "` + content + `"`
 		return SectorSemantic, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return SectorSemantic, err
 	}
@@ -191,3 +200,11 @@ Memory: "` + content + `"`
 		return SectorSemantic, nil
 	}
 }
+
+// ClassifyExchange returns the heuristic's user-weighted sector for an
+// exchange; reclassification still sees the joined content.
+// Implements ExchangeClassifier.
+func (lc *LLMClassifier) ClassifyExchange(userMessage, assistantMessage string, userWeight float64) Sector {
+	sector, _ := lc.heuristic.exchangeClassify(userMessage, assistantMessage, userWeight)
+	return sector
+}
@@ -249,6 +249,37 @@ func TestLLMClassifier_LLMErrorFallsBack(t *testing.T) {
 	}
 }
 
+func TestAddSyncReclassifyUpdatesSectorBeforeReturn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(geminiClassifyResponse("episodic")))
+	}))
+	defer server.Close()
+
+	cm := testEngramConfig(t, Config{
+		GeminiAPIKey:      "test-key",
+		EmbeddingProvider: &mockEmbedder{dim: 3},
+		SyncReclassify:    true,
+	})
+	cm.classifier.(*LLMClassifier).baseURL = server.URL
+
+	memID, err := cm.AddWithOptions(AddOptions{
+		UserID:           "test:user",
+		UserMessage:      "I just got back from Tokyo",
+		AssistantMessage: "Welcome back",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No sleep: the sector must already be final
+	var sector string
+	cm.store.db.QueryRow(`SELECT sector FROM memories WHERE id = ?`, memID).Scan(&sector)
+	if Sector(sector) != SectorEpisodic {
+		t.Errorf("expected sector episodic before Add returned, got %s", sector)
+	}
+}
+
 func TestUpdateMemorySector(t *testing.T) {
 	store := testStoreForClassify(t)
 
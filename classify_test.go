@@ -1,6 +1,12 @@
 package engram
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestHeuristicClassifyEpisodic(t *testing.T) {
 	c := NewHeuristicClassifier("")
@@ -58,3 +64,124 @@ func TestHeuristicClassifyNoGeminiFallbackWithoutKey(t *testing.T) {
 		t.Errorf("without API key, ambiguous should default to semantic, got %s", sector)
 	}
 }
+
+func TestHeuristicClassifyFallbackSector(t *testing.T) {
+	if sector := NewHeuristicClassifier("").Classify("hmm, okay"); sector != SectorSemantic {
+		t.Errorf("expected default fallback semantic, got %s", sector)
+	}
+
+	c := NewHeuristicClassifier("", WithFallbackSector(SectorEmotional))
+	if sector := c.Classify("hmm, okay"); sector != SectorEmotional {
+		t.Errorf("expected ambiguous content to fall back to emotional, got %s", sector)
+	}
+	if sector := c.Classify("Alex likes jazz and prefers vinyl"); sector != SectorSemantic {
+		t.Errorf("expected a clear signal to override the fallback, got %s", sector)
+	}
+}
+
+func TestHeuristicClassifySectorPriorityBreaksTies(t *testing.T) {
+	tie := "she likes it and I feel good" // one semantic and one emotional signal
+
+	for i := 0; i < 20; i++ { // map order must not leak into the result
+		if sector := NewHeuristicClassifier("").Classify(tie); sector != SectorSemantic {
+			t.Fatalf("expected default priority to pick semantic, got %s", sector)
+		}
+	}
+	c := NewHeuristicClassifier("", WithSectorPriority(SectorEmotional))
+	if sector := c.Classify(tie); sector != SectorEmotional {
+		t.Errorf("expected emotional to win the tie, got %s", sector)
+	}
+}
+
+// slowClassifyServer stalls like an overloaded Gemini endpoint, counting calls.
+func slowClassifyServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) }) // runs first, so Close needn't wait out the stall
+	return server
+}
+
+func TestHeuristicClassifySyncFallbackDisabledDoesNotBlockAdd(t *testing.T) {
+	var calls atomic.Int32
+	server := slowClassifyServer(t, &calls)
+	c := NewHeuristicClassifier("test-key", WithSyncFallback(false), WithFallbackSector(SectorEpisodic))
+	c.baseURL = server.URL
+	cm := testEngramConfig(t, Config{Classifier: c})
+
+	start := time.Now()
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "hmm", AssistantMessage: "okay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Add not to wait on the classifier, took %v", elapsed)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no synchronous Gemini calls, got %d", n)
+	}
+	mems, _ := cm.ListRecent("u1", 1, nil)
+	if len(mems) != 1 || mems[0].ID != id || mems[0].Sector != SectorEpisodic {
+		t.Errorf("expected the heuristic best guess (episodic), got %v", mems)
+	}
+}
+
+func TestHeuristicClassifyTimeoutBoundsSyncFallback(t *testing.T) {
+	var calls atomic.Int32
+	server := slowClassifyServer(t, &calls)
+	c := NewHeuristicClassifier("test-key", WithClassifyTimeout(50*time.Millisecond))
+	c.baseURL = server.URL
+
+	start := time.Now()
+	if sector := c.Classify("hmm, okay"); sector != SectorSemantic {
+		t.Errorf("expected heuristic fallback after timeout, got %s", sector)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the timeout to cut the call short, took %v", elapsed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one synchronous Gemini call, got %d", n)
+	}
+}
+
+func TestClassifyExchangeWeightsUserFraming(t *testing.T) {
+	c := NewHeuristicClassifier("")
+	user := "I feel so sad and nervous about tomorrow's exam"
+	assistant := "The exam usually starts at 9. Professor Kim is a strict grader who prefers typed answers and likes citations."
+
+	if sector := c.Classify(user + " | " + assistant); sector != SectorSemantic {
+		t.Errorf("expected the joined content to classify semantic, got %s", sector)
+	}
+	if sector := c.ClassifyExchange(user, assistant, 0.7); sector != SectorEmotional {
+		t.Errorf("expected the user-weighted exchange to classify emotional, got %s", sector)
+	}
+}
+
+func TestHeuristicClassifyMatchesWholeWords(t *testing.T) {
+	c := NewHeuristicClassifier("")
+
+	if sector, _ := c.heuristicClassify("she showed me her knife technique"); sector != SectorProcedural {
+		t.Errorf("expected a whole-word signal to classify procedural, got %s", sector)
+	}
+	if _, confidence := c.heuristicClassify("we cooked pintos in a cast iron skillet"); confidence != 0 {
+		t.Errorf("expected no signal from words merely containing \"into\" or \"skill\", got confidence %.1f", confidence)
+	}
+	if sector, _ := c.heuristicClassify("we kept feeling nervous"); sector != SectorEmotional {
+		t.Errorf("expected inflected signals to match, got %s", sector)
+	}
+
+	// Stopwords are skipped inside multi-word signals
+	if _, confidence := c.heuristicClassify("he is really a regular"); confidence == 0 {
+		t.Error("expected \"is a\" to match across the stopword \"really\"")
+	}
+	strict := NewHeuristicClassifier("", WithStopwords())
+	if _, confidence := strict.heuristicClassify("he is really a regular"); confidence != 0 {
+		t.Errorf("expected no match without stopwords, got confidence %.1f", confidence)
+	}
+}
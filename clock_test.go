@@ -0,0 +1,47 @@
+package engram
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockDrivesDecayAndRecency(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, Clock: clock})
+
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "I grew up in Lisbon", SectorHint: SectorSemantic, Vector: []float32{1, 0, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mems, _ := cm.ListRecent("u1", 1, nil)
+	if !mems[0].CreatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected created_at from fake clock %v, got %v", clock.Now(), mems[0].CreatedAt)
+	}
+
+	score := func() float64 {
+		results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+		if len(results) != 1 || results[0].ID != id {
+			t.Fatalf("expected memory %d, got %v", id, results)
+		}
+		return results[0].CompositeScore
+	}
+
+	// Searching reinforces and stamps last access at the fake "now"
+	fresh := score()
+	cm.runDecaySweep()
+	before, _ := cm.ListRecent("u1", 1, nil)
+
+	clock.Advance(30 * 24 * time.Hour)
+	cm.runDecaySweep()
+	after, _ := cm.ListRecent("u1", 1, nil)
+
+	if after[0].DecayScore >= before[0].DecayScore {
+		t.Errorf("expected decay after 30 fake days: before %.3f, after %.3f", before[0].DecayScore, after[0].DecayScore)
+	}
+	if stale := score(); stale >= fresh {
+		t.Errorf("expected lower composite score after 30 fake days: fresh %.3f, stale %.3f", fresh, stale)
+	}
+}
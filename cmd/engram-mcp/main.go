@@ -2,8 +2,14 @@
 //
 // Environment variables:
 //
+//	ENGRAM_CONFIG    — optional JSON config file (see engram.LoadConfig)
 //	ENGRAM_DB_PATH   — SQLite database path (default: ./data/engram.db)
 //	GEMINI_API_KEY   — Gemini API key for embeddings + optional reflection
+//	ENGRAM_EMBEDDER  — optional gemini | openai | ollama embedder, configured by
+//	                   ENGRAM_EMBED_MODEL, ENGRAM_EMBED_DIM and the provider's
+//	                   key or host (see engram.ProviderFromEnv)
+//
+// The environment only fills in what the config file leaves unset.
 //
 // Usage:
 //
@@ -24,16 +30,33 @@ import (
 )
 
 func main() {
-	dbPath := os.Getenv("ENGRAM_DB_PATH")
-	if dbPath == "" {
-		dbPath = "./data/engram.db"
+	var cfg engram.Config
+	if path := os.Getenv("ENGRAM_CONFIG"); path != "" {
+		var err error
+		cfg, err = engram.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("engram config: %v", err)
+		}
 	}
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-
-	cfg := engram.Config{
-		DBPath:       dbPath,
-		GeminiAPIKey: apiKey,
+	if cfg.DBPath == "" {
+		cfg.DBPath = os.Getenv("ENGRAM_DB_PATH")
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = "./data/engram.db"
+	}
+	if cfg.GeminiAPIKey == "" {
+		cfg.GeminiAPIKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if cfg.EmbeddingProvider == nil {
+		embedder, err := engram.ProviderFromEnv()
+		if err != nil {
+			log.Fatalf("engram embedder: %v", err)
+		}
+		if embedder != nil {
+			cfg.EmbeddingProvider = embedder
+			cfg.EmbedDimension = embedder.Dimension()
+		}
 	}
 
 	cm, err := engram.Init(cfg)
@@ -71,12 +94,48 @@ func main() {
 		Description: "Retrieve all memories from a conversation session. If no session_id is given, returns the user's most recent session.",
 	}, getSessionHandler(cm))
 
+	// --- Tool: transcript ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "transcript",
+		Description: "Render one conversation session as a readable, chronological User/Assistant transcript.",
+	}, transcriptHandler(cm))
+
 	// --- Tool: inspect ---
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "inspect",
 		Description: "Browse recent memories for a user. Useful for debugging and understanding what the character remembers.",
 	}, inspectHandler(cm))
 
+	// --- Tool: list_reflections ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_reflections",
+		Description: "List the character's reflective observations about a user, newest first.",
+	}, listReflectionsHandler(cm))
+
+	// --- Tool: prune_reflections ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "prune_reflections",
+		Description: "Delete stale reflective observations created before a cutoff timestamp.",
+	}, pruneReflectionsHandler(cm))
+
+	// --- Tool: clear_user ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "clear_user",
+		Description: "Permanently delete ALL memories for a user (character reset). Requires confirm=true.",
+	}, clearUserHandler(cm))
+
+	// --- Tool: set_user_config ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "set_user_config",
+		Description: "Store the character's persona and sector weights for a user. reflect and recall use them when no character_context or weights are given.",
+	}, setUserConfigHandler(cm))
+
+	// --- Tool: get_user_config ---
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_user_config",
+		Description: "Show the stored persona config for a user.",
+	}, getUserConfigHandler(cm))
+
 	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatalf("engram-mcp: %v", err)
 	}
@@ -85,23 +144,33 @@ func main() {
 // --- Input types ---
 
 type rememberInput struct {
-	UserID           string  `json:"user_id"               jsonschema:"User/character pair ID, e.g. lily:player123"`
-	UserMessage      string  `json:"user_message"          jsonschema:"What the user said"`
-	AssistantMessage string  `json:"assistant_message"     jsonschema:"What the character/assistant replied"`
-	SessionID        string  `json:"session_id,omitempty"  jsonschema:"Optional conversation session ID for threading"`
-	ParentID         int64   `json:"parent_id,omitempty"   jsonschema:"Optional parent memory ID for conversation chains"`
-	SectorHint       string  `json:"sector_hint,omitempty" jsonschema:"Optional sector override: episodic, semantic, procedural, emotional, reflective"`
-	Salience         float64 `json:"salience,omitempty"    jsonschema:"Optional salience score 0.0-1.0 (default 0.5)"`
+	UserID           string            `json:"user_id"               jsonschema:"User/character pair ID, e.g. lily:player123"`
+	UserMessage      string            `json:"user_message"          jsonschema:"What the user said"`
+	AssistantMessage string            `json:"assistant_message"     jsonschema:"What the character/assistant replied"`
+	SessionID        string            `json:"session_id,omitempty"  jsonschema:"Optional conversation session ID for threading"`
+	ParentID         int64             `json:"parent_id,omitempty"   jsonschema:"Optional parent memory ID for conversation chains"`
+	SectorHint       string            `json:"sector_hint,omitempty" jsonschema:"Optional sector override: episodic, semantic, procedural, emotional, reflective"`
+	Salience         float64           `json:"salience,omitempty"    jsonschema:"Optional salience score 0.0-1.0 (default 0.5)"`
+	MediaRefs        []engram.MediaRef `json:"media_refs,omitempty" jsonschema:"Optional media the character saw: uri plus a caption that is what gets searched"`
+	IdempotencyKey   string            `json:"idempotency_key,omitempty" jsonschema:"Optional unique request key; retrying with the same key returns the original memory_id instead of storing a duplicate"`
+	DecayLambda      float64           `json:"decay_lambda,omitempty" jsonschema:"Optional per-memory decay rate per day overriding the sector default (higher fades faster)"`
+	Confidence       float64           `json:"confidence,omitempty"  jsonschema:"Optional certainty 0.0-1.0 (default 1); lets the character hedge on hazy memories"`
 }
 
 type recallInput struct {
-	Query     string   `json:"query"               jsonschema:"Search query to find relevant memories"`
-	UserID    string   `json:"user_id"              jsonschema:"User/character pair ID"`
-	Limit     int      `json:"limit,omitempty"      jsonschema:"Max results to return (default 5)"`
-	SessionID string   `json:"session_id,omitempty" jsonschema:"Filter to a specific session"`
-	Sectors   []string `json:"sectors,omitempty"    jsonschema:"Filter to specific sectors: episodic, semantic, procedural, emotional, reflective"`
-	After     string   `json:"after,omitempty"      jsonschema:"Only memories after this RFC3339 timestamp"`
-	Before    string   `json:"before,omitempty"     jsonschema:"Only memories before this RFC3339 timestamp"`
+	Query      string   `json:"query"               jsonschema:"Search query to find relevant memories"`
+	UserID     string   `json:"user_id"              jsonschema:"User/character pair ID"`
+	Limit      int      `json:"limit,omitempty"      jsonschema:"Max results to return (default 5)"`
+	SessionID  string   `json:"session_id,omitempty" jsonschema:"Filter to a specific session"`
+	Sectors    []string `json:"sectors,omitempty"    jsonschema:"Filter to specific sectors: episodic, semantic, procedural, emotional, reflective"`
+	After      string   `json:"after,omitempty"      jsonschema:"Only memories after this RFC3339 timestamp"`
+	Before     string   `json:"before,omitempty"     jsonschema:"Only memories before this RFC3339 timestamp"`
+	TieBreaker string   `json:"tie_breaker,omitempty" jsonschema:"Order near-equal results by recency or salience (default: score only)"`
+	Expansions []string `json:"query_expansions,omitempty" jsonschema:"Paraphrases of a terse query; a memory matching any of them counts as a match"`
+	Balance    bool     `json:"balance_sectors,omitempty" jsonschema:"With several sectors, return at least one memory from each when available"`
+	MinConf    float64  `json:"min_confidence,omitempty" jsonschema:"Skip memories the character is less sure of than this (0.0-1.0)"`
+	ExclSince  string   `json:"exclude_since,omitempty" jsonschema:"Skip memories stored at or after this RFC3339 timestamp, e.g. the current turn, to avoid echoing it"`
+	Expand     int      `json:"expand_context,omitempty" jsonschema:"Return each memory with this many neighboring turns from its session on either side"`
 }
 
 type reflectInput struct {
@@ -110,11 +179,21 @@ type reflectInput struct {
 	MemoryWindow     int      `json:"memory_window,omitempty"     jsonschema:"How many recent memories to analyze (default 50)"`
 	Sectors          []string `json:"sectors,omitempty"           jsonschema:"Which sectors to draw from"`
 	MinMemories      int      `json:"min_memories,omitempty"      jsonschema:"Minimum memories needed before reflecting (default 5)"`
+	DefaultSalience  float64  `json:"default_salience,omitempty"  jsonschema:"Salience for reflections the model doesn't score (default 0.7)"`
+	MinSalience      float64  `json:"min_salience,omitempty"      jsonschema:"Floor for every stored reflection's salience (default none)"`
+	MaxReflections   int      `json:"max_reflections,omitempty"   jsonschema:"Store at most this many reflections, the most salient (default no cap)"`
+	WindowLabel      string   `json:"window_label,omitempty"      jsonschema:"Label for the window being reflected over, e.g. session or weekly, for filtering later"`
+	IncludeSalient   int      `json:"include_salient,omitempty"   jsonschema:"Also give the model this many of the most salient older memories (default none)"`
 }
 
 type getSessionInput struct {
 	UserID    string `json:"user_id"              jsonschema:"User/character pair ID (required when getting last session)"`
 	SessionID string `json:"session_id,omitempty" jsonschema:"Specific session ID. If empty, returns the last session for the user."`
+	MaxAge    string `json:"max_age,omitempty"    jsonschema:"Treat a last session older than this Go duration (e.g. 72h) as no recent session"`
+}
+
+type transcriptInput struct {
+	SessionID string `json:"session_id" jsonschema:"Session ID to render"`
 }
 
 type inspectInput struct {
@@ -123,6 +202,32 @@ type inspectInput struct {
 	Sectors []string `json:"sectors,omitempty"  jsonschema:"Filter to specific sectors"`
 }
 
+type listReflectionsInput struct {
+	UserID      string `json:"user_id"                jsonschema:"User/character pair ID"`
+	Limit       int    `json:"limit,omitempty"        jsonschema:"Max reflections to list (default 20)"`
+	WindowLabel string `json:"window_label,omitempty" jsonschema:"Only list reflections made with this window label"`
+}
+
+type pruneReflectionsInput struct {
+	UserID    string `json:"user_id"    jsonschema:"User/character pair ID"`
+	OlderThan string `json:"older_than" jsonschema:"Delete reflections created before this RFC3339 timestamp"`
+}
+
+type clearUserInput struct {
+	UserID  string `json:"user_id" jsonschema:"User/character pair ID to wipe"`
+	Confirm bool   `json:"confirm" jsonschema:"Must be true; deletion cannot be undone"`
+}
+
+type setUserConfigInput struct {
+	UserID        string             `json:"user_id"                  jsonschema:"User/character pair ID"`
+	Persona       string             `json:"persona,omitempty"        jsonschema:"Character personality description used as the default reflection context"`
+	SectorWeights map[string]float64 `json:"sector_weights,omitempty" jsonschema:"Default retrieval weight per sector, e.g. {\"emotional\": 1.5}"`
+}
+
+type getUserConfigInput struct {
+	UserID string `json:"user_id" jsonschema:"User/character pair ID"`
+}
+
 // --- Handlers ---
 
 func rememberHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, rememberInput) (*mcp.CallToolResult, any, error) {
@@ -135,6 +240,10 @@ func rememberHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReque
 			ParentID:         input.ParentID,
 			SectorHint:       engram.Sector(input.SectorHint),
 			Salience:         input.Salience,
+			MediaRefs:        input.MediaRefs,
+			IdempotencyKey:   input.IdempotencyKey,
+			DecayLambda:      input.DecayLambda,
+			Confidence:       input.Confidence,
 		})
 		if err != nil {
 			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
@@ -149,10 +258,20 @@ func rememberHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReque
 func recallHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, recallInput) (*mcp.CallToolResult, any, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, input recallInput) (*mcp.CallToolResult, any, error) {
 		opts := engram.SearchOptions{
-			Query:     input.Query,
-			UserID:    input.UserID,
-			Limit:     input.Limit,
-			SessionID: input.SessionID,
+			Query:                input.Query,
+			UserID:               input.UserID,
+			Limit:                input.Limit,
+			SessionID:            input.SessionID,
+			QueryExpansions:      input.Expansions,
+			BalanceAcrossSectors: input.Balance,
+			MinConfidence:        input.MinConf,
+			ExpandContext:        input.Expand,
+		}
+		switch tb := engram.TieBreaker(input.TieBreaker); tb {
+		case engram.TieBreakComposite, engram.TieBreakRecency, engram.TieBreakSalience:
+			opts.TieBreaker = tb
+		default:
+			return textResult(fmt.Sprintf("error: unknown tie_breaker %q (want recency or salience)", input.TieBreaker)), nil, nil
 		}
 
 		if input.After != "" {
@@ -169,6 +288,13 @@ func recallHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest
 			}
 			opts.Before = &t
 		}
+		if input.ExclSince != "" {
+			t, err := time.Parse(time.RFC3339, input.ExclSince)
+			if err != nil {
+				return textResult(fmt.Sprintf("invalid 'exclude_since' timestamp: %v", err)), nil, nil
+			}
+			opts.ExcludeSince = &t
+		}
 		for _, s := range input.Sectors {
 			opts.Sectors = append(opts.Sectors, engram.Sector(s))
 		}
@@ -190,6 +316,11 @@ func reflectHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReques
 			CharacterContext: input.CharacterContext,
 			MemoryWindow:     input.MemoryWindow,
 			MinMemories:      input.MinMemories,
+			DefaultSalience:  input.DefaultSalience,
+			MinSalience:      input.MinSalience,
+			MaxReflections:   input.MaxReflections,
+			WindowLabel:      input.WindowLabel,
+			IncludeSalient:   input.IncludeSalient,
 		}
 		for _, s := range input.Sectors {
 			opts.Sectors = append(opts.Sectors, engram.Sector(s))
@@ -220,7 +351,14 @@ func getSessionHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReq
 		if input.SessionID != "" {
 			memories, err = cm.GetSession(input.SessionID)
 		} else if input.UserID != "" {
-			memories, err = cm.GetLastSession(input.UserID)
+			var maxAge time.Duration
+			if input.MaxAge != "" {
+				maxAge, err = time.ParseDuration(input.MaxAge)
+				if err != nil {
+					return textResult(fmt.Sprintf("error: invalid max_age: %v", err)), nil, nil
+				}
+			}
+			memories, err = cm.GetLastSessionWithin(input.UserID, maxAge)
 		} else {
 			return textResult(`{"error": "provide either session_id or user_id"}`), nil, nil
 		}
@@ -237,6 +375,16 @@ func getSessionHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReq
 	}
 }
 
+func transcriptHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, transcriptInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input transcriptInput) (*mcp.CallToolResult, any, error) {
+		transcript, err := cm.SessionTranscript(input.SessionID)
+		if err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+		return textResult(transcript), nil, nil
+	}
+}
+
 func inspectHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, inspectInput) (*mcp.CallToolResult, any, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, input inspectInput) (*mcp.CallToolResult, any, error) {
 		limit := input.Limit
@@ -262,6 +410,92 @@ func inspectHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolReques
 	}
 }
 
+func listReflectionsHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, listReflectionsInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input listReflectionsInput) (*mcp.CallToolResult, any, error) {
+		var memories []engram.Memory
+		var err error
+		if input.WindowLabel != "" {
+			memories, err = cm.ListReflectionsInWindow(input.UserID, input.WindowLabel, input.Limit)
+		} else {
+			memories, err = cm.ListReflections(input.UserID, input.Limit)
+		}
+		if err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+
+		out := make([]map[string]any, len(memories))
+		for i, m := range memories {
+			out[i] = memoryToMap(m)
+		}
+		return textResult(jsonString(out)), nil, nil
+	}
+}
+
+func pruneReflectionsHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, pruneReflectionsInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input pruneReflectionsInput) (*mcp.CallToolResult, any, error) {
+		cutoff, err := time.Parse(time.RFC3339, input.OlderThan)
+		if err != nil {
+			return textResult(fmt.Sprintf("invalid 'older_than' timestamp: %v", err)), nil, nil
+		}
+
+		n, err := cm.PruneReflections(input.UserID, cutoff)
+		if err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+		return textResult(jsonString(map[string]any{
+			"pruned": n,
+			"status": "ok",
+		})), nil, nil
+	}
+}
+
+func clearUserHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, clearUserInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input clearUserInput) (*mcp.CallToolResult, any, error) {
+		if !input.Confirm {
+			return textResult(`{"error": "refusing to clear memories without confirm=true"}`), nil, nil
+		}
+
+		n, err := cm.ClearUser(input.UserID)
+		if err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+		return textResult(jsonString(map[string]any{
+			"deleted": n,
+			"status":  "ok",
+		})), nil, nil
+	}
+}
+
+func setUserConfigHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, setUserConfigInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input setUserConfigInput) (*mcp.CallToolResult, any, error) {
+		uc := engram.UserConfig{Persona: input.Persona}
+		if len(input.SectorWeights) > 0 {
+			uc.SectorWeights = make(engram.SectorWeights, len(input.SectorWeights))
+			for sector, w := range input.SectorWeights {
+				uc.SectorWeights[engram.Sector(sector)] = w
+			}
+		}
+
+		if err := cm.SetUserConfig(input.UserID, uc); err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+		return textResult(`{"status": "ok"}`), nil, nil
+	}
+}
+
+func getUserConfigHandler(cm *engram.Engram) func(context.Context, *mcp.CallToolRequest, getUserConfigInput) (*mcp.CallToolResult, any, error) {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input getUserConfigInput) (*mcp.CallToolResult, any, error) {
+		uc, err := cm.GetUserConfig(input.UserID)
+		if err != nil {
+			return textResult(fmt.Sprintf("error: %v", err)), nil, nil
+		}
+		if uc == nil {
+			return textResult(`{"status": "not_configured"}`), nil, nil
+		}
+		return textResult(jsonString(uc)), nil, nil
+	}
+}
+
 // --- Helpers ---
 
 func textResult(text string) *mcp.CallToolResult {
@@ -273,23 +507,48 @@ func textResult(text string) *mcp.CallToolResult {
 }
 
 func memoryToMap(m engram.Memory) map[string]any {
-	return map[string]any{
-		"id":          m.ID,
-		"content":     m.Content,
-		"sector":      m.Sector,
-		"salience":    m.Salience,
-		"decay_score": m.DecayScore,
-		"summary":     m.Summary,
-		"session_id":  m.SessionID,
-		"parent_id":   m.ParentID,
-		"created_at":  m.CreatedAt.Format(time.RFC3339),
+	out := map[string]any{
+		"id":                m.ID,
+		"content":           m.Content,
+		"user_message":      m.UserMessage,
+		"assistant_message": m.AssistantMessage,
+		"sector":            m.Sector,
+		"salience":          m.Salience,
+		"confidence":        m.Confidence,
+		"decay_score":       m.DecayScore,
+		"summary":           m.Summary,
+		"session_id":        m.SessionID,
+		"parent_id":         m.ParentID,
+		"media_refs":        m.MediaRefs,
+		"created_at":        m.CreatedAt.Format(time.RFC3339),
 	}
+	if m.ClassifyConfidence != nil {
+		out["classify_confidence"] = *m.ClassifyConfidence
+	}
+	if m.ReflectionWindow != "" {
+		out["reflection_window"] = m.ReflectionWindow
+	}
+	if !m.WindowStart.IsZero() {
+		out["window_start"] = m.WindowStart.Format(time.RFC3339)
+		out["window_end"] = m.WindowEnd.Format(time.RFC3339)
+	}
+	return out
 }
 
 func searchResultToMap(r engram.SearchResult) map[string]any {
 	m := memoryToMap(r.Memory)
 	m["composite_score"] = r.CompositeScore
 	m["similarity"] = r.Similarity
+	if r.Injected {
+		m["injected"] = true // surfaced for salience, not relevance
+	}
+	if r.Context != nil {
+		turns := make([]map[string]any, len(r.Context))
+		for i, c := range r.Context {
+			turns[i] = memoryToMap(c)
+		}
+		m["context"] = turns
+	}
 	return m
 }
 
@@ -0,0 +1,317 @@
+package engram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// LoadConfig reads a JSON config file into a Config, so deployers (e.g. the
+// MCP server via ENGRAM_CONFIG) can tune engram without Go code. ${VAR}
+// references in the api_key and base_url fields are expanded from the
+// environment after parsing, which keeps API keys out of the file; a bare $
+// elsewhere is left alone. Unknown fields and invalid values are errors.
+//
+// Schema (every field optional; durations use Go syntax like "12h"):
+//
+//	{
+//	  "db_path": "./data/engram.db",
+//	  "read_replica": false,                   // separate read-only connection for searches
+//	  "max_memories_per_user": 500,
+//	  "max_adds_per_minute": 0,
+//	  "min_decay_score": 0.01,
+//	  "search_candidate_cap": 0,
+//	  "similarity_metric": "cosine",           // cosine | dot | euclidean
+//	  "scoring_weights": {"similarity": 0.6, "salience": 0.2, "recency": 0.1, "link_weight": 0.1},
+//	  "decay_interval": "12h",
+//	  "decay_on_write": false,
+//	  "decay_on_read": false,
+//	  "decay_rates": {"episodic": 0.005},      // per-sector lambda overrides
+//	  "archive_retention": "720h",
+//	  "gemini_api_key": "${GEMINI_API_KEY}",
+//	  "embedding": {
+//	    "provider": "gemini",                  // gemini | openai | ollama
+//	    "api_key": "${OPENAI_API_KEY}",        // gemini, openai (gemini defaults to gemini_api_key)
+//	    "model": "text-embedding-3-small",     // openai, ollama (required for ollama)
+//	    "dimension": 768,
+//	    "base_url": "http://localhost:11434"   // openai base URL or ollama host
+//	  },
+//	  "reflection": {
+//	    "provider": "gemini",                  // only gemini
+//	    "api_key": "${GEMINI_API_KEY}",        // defaults to gemini_api_key
+//	    "model": "gemini-2.5-flash-lite",
+//	    "interval": "1h",
+//	    "every_n_memories": 25,                // reflect after this many new memories
+//	    "prompt_template": "..."
+//	  }
+//	}
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("engram: read config: %w", err)
+	}
+
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return Config{}, fmt.Errorf("engram: parse config %s: %w", path, err)
+	}
+	fc.expandEnv()
+
+	cfg, err := fc.toConfig()
+	if err != nil {
+		return Config{}, fmt.Errorf("engram: config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// fileConfig is the on-disk shape documented on LoadConfig.
+type fileConfig struct {
+	DBPath             string             `json:"db_path"`
+	ReadReplica        bool               `json:"read_replica"`
+	MaxMemoriesPerUser int                `json:"max_memories_per_user"`
+	MaxAddsPerMinute   int                `json:"max_adds_per_minute"`
+	MinDecayScore      float64            `json:"min_decay_score"`
+	SearchCandidateCap int                `json:"search_candidate_cap"`
+	SimilarityMetric   SimilarityMetric   `json:"similarity_metric"`
+	ScoringWeights     *fileWeights       `json:"scoring_weights"`
+	DecayInterval      fileDuration       `json:"decay_interval"`
+	DecayOnWrite       bool               `json:"decay_on_write"`
+	DecayOnRead        bool               `json:"decay_on_read"`
+	DecayRates         map[Sector]float64 `json:"decay_rates"`
+	ArchiveRetention   fileDuration       `json:"archive_retention"`
+	GeminiAPIKey       string             `json:"gemini_api_key"`
+	Embedding          *fileEmbedding     `json:"embedding"`
+	Reflection         *fileReflection    `json:"reflection"`
+}
+
+type fileWeights struct {
+	Similarity float64 `json:"similarity"`
+	Salience   float64 `json:"salience"`
+	Recency    float64 `json:"recency"`
+	LinkWeight float64 `json:"link_weight"`
+}
+
+type fileEmbedding struct {
+	Provider  string `json:"provider"`
+	APIKey    string `json:"api_key"`
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+	BaseURL   string `json:"base_url"`
+}
+
+type fileReflection struct {
+	Provider       string       `json:"provider"`
+	APIKey         string       `json:"api_key"`
+	Model          string       `json:"model"`
+	Interval       fileDuration `json:"interval"`
+	EveryNMemories int          `json:"every_n_memories"`
+	PromptTemplate string       `json:"prompt_template"`
+}
+
+// envRef matches a ${VAR} reference; bare $VAR is not expanded.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in the secret and endpoint fields.
+func (fc *fileConfig) expandEnv() {
+	expand := func(s *string) {
+		*s = envRef.ReplaceAllStringFunc(*s, func(ref string) string {
+			return os.Getenv(ref[2 : len(ref)-1])
+		})
+	}
+	expand(&fc.GeminiAPIKey)
+	if fc.Embedding != nil {
+		expand(&fc.Embedding.APIKey)
+		expand(&fc.Embedding.BaseURL)
+	}
+	if fc.Reflection != nil {
+		expand(&fc.Reflection.APIKey)
+	}
+}
+
+// fileDuration decodes a Go duration string such as "12h" or "90m".
+type fileDuration time.Duration
+
+func (d *fileDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"12h\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %q must not be negative", s)
+	}
+	*d = fileDuration(parsed)
+	return nil
+}
+
+func (fc fileConfig) toConfig() (Config, error) {
+	cfg := Config{
+		DBPath:             fc.DBPath,
+		ReadReplica:        fc.ReadReplica,
+		MaxMemoriesPerUser: fc.MaxMemoriesPerUser,
+		MaxAddsPerMinute:   fc.MaxAddsPerMinute,
+		MinDecayScore:      fc.MinDecayScore,
+		SearchCandidateCap: fc.SearchCandidateCap,
+		SimilarityMetric:   fc.SimilarityMetric,
+		DecayInterval:      time.Duration(fc.DecayInterval),
+		DecayOnWrite:       fc.DecayOnWrite,
+		DecayOnRead:        fc.DecayOnRead,
+		DecayRates:         fc.DecayRates,
+		ArchiveRetention:   time.Duration(fc.ArchiveRetention),
+		GeminiAPIKey:       fc.GeminiAPIKey,
+	}
+
+	if fc.MaxMemoriesPerUser < 0 || fc.MaxAddsPerMinute < 0 || fc.SearchCandidateCap < 0 {
+		return Config{}, fmt.Errorf("limits must not be negative")
+	}
+	if fc.MinDecayScore < 0 || fc.MinDecayScore >= 1 {
+		return Config{}, fmt.Errorf("min_decay_score %v must be in [0, 1)", fc.MinDecayScore)
+	}
+	switch fc.SimilarityMetric {
+	case "", MetricCosine, MetricDot, MetricEuclidean:
+	default:
+		return Config{}, fmt.Errorf("unknown similarity_metric %q", fc.SimilarityMetric)
+	}
+	known := DefaultDecayRates()
+	for sector, lambda := range fc.DecayRates {
+		if _, ok := known[sector]; !ok {
+			return Config{}, fmt.Errorf("decay_rates: unknown sector %q", sector)
+		}
+		if lambda < 0 {
+			return Config{}, fmt.Errorf("decay_rates: %s rate must not be negative", sector)
+		}
+	}
+	if w := fc.ScoringWeights; w != nil {
+		if w.Similarity < 0 || w.Salience < 0 || w.Recency < 0 || w.LinkWeight < 0 {
+			return Config{}, fmt.Errorf("scoring_weights must not be negative")
+		}
+		cfg.ScoringWeights = &ScoringWeights{
+			Similarity: w.Similarity,
+			Salience:   w.Salience,
+			Recency:    w.Recency,
+			LinkWeight: w.LinkWeight,
+		}
+	}
+
+	if fc.Embedding != nil {
+		embedder, err := fc.Embedding.provider(fc.GeminiAPIKey)
+		if err != nil {
+			return Config{}, fmt.Errorf("embedding: %w", err)
+		}
+		cfg.EmbeddingProvider = embedder
+		cfg.EmbedDimension = embedder.Dimension()
+	}
+
+	if r := fc.Reflection; r != nil {
+		if r.Provider != "gemini" {
+			return Config{}, fmt.Errorf("reflection: unknown provider %q (want gemini)", r.Provider)
+		}
+		apiKey := r.APIKey
+		if apiKey == "" {
+			apiKey = fc.GeminiAPIKey
+		}
+		var opts []GeminiReflectorOption
+		if r.Model != "" {
+			opts = append(opts, WithReflectorModel(r.Model))
+		}
+		cfg.ReflectionProvider = NewGeminiReflector(apiKey, opts...)
+		cfg.ReflectionInterval = time.Duration(r.Interval)
+		cfg.ReflectEveryNMemories = r.EveryNMemories
+		cfg.ReflectionPromptTemplate = r.PromptTemplate
+	}
+	return cfg, nil
+}
+
+// ProviderFromEnv builds the EmbeddingProvider named by ENGRAM_EMBEDDER, the
+// environment counterpart of a config file's "embedding" section, so the MCP
+// server and examples can switch providers without code changes:
+//
+//	ENGRAM_EMBEDDER    — gemini | openai | ollama (unset: nil, use Config defaults)
+//	ENGRAM_EMBED_MODEL — openai, ollama (required for ollama)
+//	ENGRAM_EMBED_DIM   — output dimension (gemini 768, openai 1536; required for ollama)
+//	GEMINI_API_KEY     — gemini
+//	OPENAI_API_KEY     — openai
+//	OPENAI_BASE_URL    — optional OpenAI-compatible base URL
+//	OLLAMA_HOST        — optional ollama host (default http://localhost:11434)
+func ProviderFromEnv() (EmbeddingProvider, error) {
+	e := fileEmbedding{
+		Provider: os.Getenv("ENGRAM_EMBEDDER"),
+		Model:    os.Getenv("ENGRAM_EMBED_MODEL"),
+	}
+	if e.Provider == "" {
+		return nil, nil
+	}
+	if dim := os.Getenv("ENGRAM_EMBED_DIM"); dim != "" {
+		n, err := strconv.Atoi(dim)
+		if err != nil {
+			return nil, fmt.Errorf("engram: ENGRAM_EMBED_DIM: %w", err)
+		}
+		e.Dimension = n
+	}
+	switch e.Provider {
+	case "openai":
+		e.APIKey = os.Getenv("OPENAI_API_KEY")
+		e.BaseURL = os.Getenv("OPENAI_BASE_URL")
+	case "ollama":
+		e.BaseURL = os.Getenv("OLLAMA_HOST")
+	}
+	p, err := e.provider(os.Getenv("GEMINI_API_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("engram: ENGRAM_EMBEDDER: %w", err)
+	}
+	return p, nil
+}
+
+// provider builds the EmbeddingProvider named by e.Provider.
+func (e *fileEmbedding) provider(geminiAPIKey string) (EmbeddingProvider, error) {
+	if e.Dimension < 0 {
+		return nil, fmt.Errorf("dimension must not be negative")
+	}
+	switch e.Provider {
+	case "gemini":
+		if e.Model != "" || e.BaseURL != "" {
+			return nil, fmt.Errorf("gemini does not support model or base_url")
+		}
+		apiKey := e.APIKey
+		if apiKey == "" {
+			apiKey = geminiAPIKey
+		}
+		dim := e.Dimension
+		if dim == 0 {
+			dim = 768
+		}
+		return NewGeminiEmbedder(apiKey, dim), nil
+	case "openai":
+		var opts []OpenAIOption
+		if e.Model != "" {
+			opts = append(opts, WithOpenAIModel(e.Model))
+		}
+		if e.Dimension != 0 {
+			opts = append(opts, WithOpenAIDimension(e.Dimension))
+		}
+		if e.BaseURL != "" {
+			opts = append(opts, WithOpenAIBaseURL(e.BaseURL))
+		}
+		return NewOpenAIEmbedder(e.APIKey, opts...), nil
+	case "ollama":
+		if e.Model == "" || e.Dimension == 0 {
+			return nil, fmt.Errorf("ollama requires model and dimension")
+		}
+		var opts []OllamaOption
+		if e.BaseURL != "" {
+			opts = append(opts, WithOllamaHost(e.BaseURL))
+		}
+		return NewOllamaEmbedder(e.Model, e.Dimension, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want gemini, openai, or ollama)", e.Provider)
+	}
+}
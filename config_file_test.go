@@ -0,0 +1,194 @@
+package engram
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "engram.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigResolvesProvidersAndRates(t *testing.T) {
+	t.Setenv("TEST_OPENAI_KEY", "sk-test")
+	path := writeConfigFile(t, `{
+		"db_path": "/tmp/x.db",
+		"decay_interval": "6h",
+		"decay_rates": {"episodic": 0.01, "reflective": 0.1},
+		"scoring_weights": {"similarity": 0.5, "salience": 0.3, "recency": 0.1, "link_weight": 0.1},
+		"embedding": {"provider": "openai", "api_key": "${TEST_OPENAI_KEY}", "model": "text-embedding-3-large", "dimension": 256},
+		"reflection": {"provider": "gemini", "api_key": "g-key", "model": "gemini-2.5-pro", "interval": "1h", "every_n_memories": 25}
+	}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBPath != "/tmp/x.db" || cfg.DecayInterval != 6*time.Hour {
+		t.Errorf("unexpected storage/decay settings: %q %v", cfg.DBPath, cfg.DecayInterval)
+	}
+
+	emb, ok := cfg.EmbeddingProvider.(*OpenAIEmbedder)
+	if !ok {
+		t.Fatalf("expected *OpenAIEmbedder, got %T", cfg.EmbeddingProvider)
+	}
+	if emb.apiKey != "sk-test" || emb.model != "text-embedding-3-large" || emb.dimension != 256 {
+		t.Errorf("embedder not configured from file: %+v", emb)
+	}
+	if cfg.EmbedDimension != 256 {
+		t.Errorf("expected EmbedDimension 256, got %d", cfg.EmbedDimension)
+	}
+
+	ref, ok := cfg.ReflectionProvider.(*GeminiReflector)
+	if !ok {
+		t.Fatalf("expected *GeminiReflector, got %T", cfg.ReflectionProvider)
+	}
+	if ref.model != "gemini-2.5-pro" || cfg.ReflectionInterval != time.Hour || cfg.ReflectEveryNMemories != 25 {
+		t.Errorf("reflection not configured from file: model=%s interval=%v every=%d", ref.model, cfg.ReflectionInterval, cfg.ReflectEveryNMemories)
+	}
+
+	cfg.ApplyDefaults()
+	if cfg.decayRates[SectorEpisodic] != 0.01 || cfg.decayRates[SectorReflective] != 0.1 {
+		t.Errorf("expected overridden decay rates, got %v", cfg.decayRates)
+	}
+	if cfg.decayRates[SectorSemantic] != DefaultDecayRates()[SectorSemantic] {
+		t.Errorf("expected default semantic rate kept, got %v", cfg.decayRates[SectorSemantic])
+	}
+	if cfg.scoringWeights.Similarity != 0.5 {
+		t.Errorf("expected similarity weight 0.5, got %v", cfg.scoringWeights.Similarity)
+	}
+}
+
+func TestLoadConfigOllamaAndGeminiDefaults(t *testing.T) {
+	cfg, err := LoadConfig(writeConfigFile(t, `{"embedding": {"provider": "ollama", "model": "nomic-embed-text", "dimension": 768, "base_url": "http://gpu:11434"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := cfg.EmbeddingProvider.(*OllamaEmbedder); !ok || o.host != "http://gpu:11434" {
+		t.Errorf("expected ollama embedder on gpu host, got %#v", cfg.EmbeddingProvider)
+	}
+
+	cfg, err = LoadConfig(writeConfigFile(t, `{"gemini_api_key": "k", "embedding": {"provider": "gemini"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := cfg.EmbeddingProvider.(*GeminiEmbedder); !ok || g.apiKey != "k" || g.dimension != 768 {
+		t.Errorf("expected gemini embedder using gemini_api_key, got %#v", cfg.EmbeddingProvider)
+	}
+}
+
+func TestLoadConfigExpandsOnlyBracedRefsInKeysAndURLs(t *testing.T) {
+	t.Setenv("TEST_OLLAMA_HOST", "http://gpu:11434")
+	t.Setenv("TEST_GEMINI_KEY", `k"ey`)
+	t.Setenv("HOME", "/home/engram")
+	cfg, err := LoadConfig(writeConfigFile(t, `{
+		"gemini_api_key": "${TEST_GEMINI_KEY}",
+		"embedding": {"provider": "ollama", "model": "nomic-embed-text", "dimension": 768, "base_url": "${TEST_OLLAMA_HOST}"},
+		"reflection": {"provider": "gemini", "prompt_template": "Costs $HOME and ${HOME}"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GeminiAPIKey != `k"ey` {
+		t.Errorf("expected key expanded verbatim, got %q", cfg.GeminiAPIKey)
+	}
+	if o, ok := cfg.EmbeddingProvider.(*OllamaEmbedder); !ok || o.host != "http://gpu:11434" {
+		t.Errorf("expected base_url expanded, got %#v", cfg.EmbeddingProvider)
+	}
+	if cfg.ReflectionPromptTemplate != "Costs $HOME and ${HOME}" {
+		t.Errorf("expected prompt template left unexpanded, got %q", cfg.ReflectionPromptTemplate)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	cases := map[string]string{
+		"malformed":        `{"db_path": `,
+		"unknown field":    `{"decay_intervall": "1h"}`,
+		"bad duration":     `{"decay_interval": "soon"}`,
+		"unknown sector":   `{"decay_rates": {"dreams": 0.1}}`,
+		"unknown provider": `{"embedding": {"provider": "cohere"}}`,
+		"ollama no model":  `{"embedding": {"provider": "ollama"}}`,
+		"unknown metric":   `{"similarity_metric": "manhattan"}`,
+		"negative weight":  `{"scoring_weights": {"similarity": -1}}`,
+	}
+	for name, body := range cases {
+		_, err := LoadConfig(writeConfigFile(t, body))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "engram: ") {
+			t.Errorf("%s: expected engram-prefixed error, got %v", name, err)
+		}
+	}
+}
+
+func TestProviderFromEnv(t *testing.T) {
+	env := func(vars map[string]string) {
+		for _, k := range []string{"ENGRAM_EMBEDDER", "ENGRAM_EMBED_MODEL", "ENGRAM_EMBED_DIM", "GEMINI_API_KEY", "OPENAI_API_KEY", "OPENAI_BASE_URL", "OLLAMA_HOST"} {
+			t.Setenv(k, vars[k])
+		}
+	}
+
+	env(nil)
+	if p, err := ProviderFromEnv(); p != nil || err != nil {
+		t.Errorf("expected no provider when ENGRAM_EMBEDDER is unset, got %T (err %v)", p, err)
+	}
+
+	env(map[string]string{"ENGRAM_EMBEDDER": "gemini", "GEMINI_API_KEY": "g-key"})
+	p, err := ProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := p.(*GeminiEmbedder); !ok || g.Dimension() != 768 {
+		t.Errorf("expected a 768-dim *GeminiEmbedder by default, got %T dim %d", p, p.Dimension())
+	}
+
+	env(map[string]string{"ENGRAM_EMBEDDER": "openai", "OPENAI_API_KEY": "sk-test"})
+	p, err = ProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := p.(*OpenAIEmbedder); !ok || o.Dimension() != 1536 || o.model != "text-embedding-3-small" {
+		t.Errorf("expected default 1536-dim text-embedding-3-small, got %T dim %d", p, p.Dimension())
+	}
+
+	env(map[string]string{"ENGRAM_EMBEDDER": "openai", "OPENAI_API_KEY": "sk-test", "ENGRAM_EMBED_MODEL": "text-embedding-3-large", "ENGRAM_EMBED_DIM": "256"})
+	p, err = ProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := p.(*OpenAIEmbedder); o.Dimension() != 256 || o.model != "text-embedding-3-large" {
+		t.Errorf("expected env model and dimension to apply, got %s dim %d", o.model, o.Dimension())
+	}
+
+	env(map[string]string{"ENGRAM_EMBEDDER": "ollama", "ENGRAM_EMBED_MODEL": "nomic-embed-text", "ENGRAM_EMBED_DIM": "768", "OLLAMA_HOST": "http://gpu:11434"})
+	p, err = ProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := p.(*OllamaEmbedder); !ok || o.host != "http://gpu:11434" || o.Dimension() != 768 {
+		t.Errorf("expected ollama at the env host, got %T", p)
+	}
+
+	for name, vars := range map[string]map[string]string{
+		"ollama without dimension": {"ENGRAM_EMBEDDER": "ollama", "ENGRAM_EMBED_MODEL": "nomic-embed-text"},
+		"bad dimension":            {"ENGRAM_EMBEDDER": "gemini", "ENGRAM_EMBED_DIM": "big"},
+		"unknown provider":         {"ENGRAM_EMBEDDER": "cohere"},
+	} {
+		env(vars)
+		if _, err := ProviderFromEnv(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
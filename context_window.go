@@ -0,0 +1,69 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+)
+
+// BuildContext assembles a prompt context window for a user: the last few
+// turns of the current session plus the top-k retrieved long-term memories.
+// Turns already included as recent context are not repeated as retrieved
+// memories.
+func (cm *Engram) BuildContext(ctx context.Context, userID, query string, opts ContextOptions) (ContextResult, error) {
+	if err := cm.enter(); err != nil {
+		return ContextResult{}, err
+	}
+	defer cm.leave()
+	var result ContextResult
+	if userID == "" {
+		return result, nil
+	}
+	if opts.RecentTurns <= 0 {
+		opts.RecentTurns = 5
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 5
+	}
+
+	// 1. Recent session turns
+	sessionID := opts.SessionID
+	if sessionID == "" {
+		var err error
+		sessionID, err = cm.store.GetLastSessionIDSince(userID, cm.sessionCutoff(opts.SessionMaxAge))
+		if err != nil {
+			return result, fmt.Errorf("engram: last session: %w", err)
+		}
+	}
+	if sessionID != "" {
+		turns, err := cm.store.GetSessionMemories(sessionID)
+		if err != nil {
+			return result, fmt.Errorf("engram: load session: %w", err)
+		}
+		if len(turns) > opts.RecentTurns {
+			turns = turns[len(turns)-opts.RecentTurns:]
+		}
+		result.RecentTurns = turns
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	// 2. Retrieved long-term memories other than the recent turns
+	exclude := make([]int64, len(result.RecentTurns))
+	for i, m := range result.RecentTurns {
+		exclude[i] = m.ID
+	}
+	result.Memories = cm.search(ctx, SearchOptions{
+		Query:      query,
+		UserID:     userID,
+		Limit:      opts.Limit,
+		Weights:    opts.Weights,
+		ExcludeIDs: exclude,
+	})
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package engram
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildContextMixesTurnsAndMemoriesWithoutDuplicates(t *testing.T) {
+	embed := &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3}
+	cm := testEngram(t, nil, embed)
+
+	// Long-term memories from an earlier session
+	var oldIDs []int64
+	for _, msg := range []string{"I grew up in Osaka", "My sister plays violin"} {
+		id, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: msg, AssistantMessage: "noted", SessionID: "s1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldIDs = append(oldIDs, id)
+	}
+	cm.store.db.Exec(`UPDATE memories SET created_at = '2024-01-01 12:00:00' WHERE session_id = 's1'`)
+
+	// Current session
+	var turnIDs []int64
+	for _, msg := range []string{"hey again", "rough day", "need a drink"} {
+		id, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: msg, AssistantMessage: "ok", SessionID: "s2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		turnIDs = append(turnIDs, id)
+	}
+
+	res, err := cm.BuildContext(context.Background(), "u1", "what should I drink", ContextOptions{RecentTurns: 2, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.RecentTurns) != 2 || res.RecentTurns[0].ID != turnIDs[1] || res.RecentTurns[1].ID != turnIDs[2] {
+		t.Fatalf("expected last 2 turns of s2 in order, got %v", res.RecentTurns)
+	}
+
+	retrieved := make(map[int64]bool)
+	for _, r := range res.Memories {
+		if r.ID == turnIDs[1] || r.ID == turnIDs[2] {
+			t.Errorf("memory %d appears in both recent turns and retrieved memories", r.ID)
+		}
+		retrieved[r.ID] = true
+	}
+	for _, id := range oldIDs {
+		if !retrieved[id] {
+			t.Errorf("expected long-term memory %d in retrieved memories", id)
+		}
+	}
+}
+
+// cancelingEmbedder cancels the caller's context while embedding, as a
+// request abandoned mid-search would.
+type cancelingEmbedder struct {
+	cancel context.CancelFunc
+}
+
+func (e cancelingEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	e.cancel()
+	return nil, ctx.Err()
+}
+
+func (cancelingEmbedder) Dimension() int { return 3 }
+
+func TestBuildContextStopsWhenContextCanceledDuringSearch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cm := testEngram(t, nil, cancelingEmbedder{cancel})
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "hi", AssistantMessage: "hello", SessionID: "s1", Vector: []float32{1, 0, 0}})
+
+	res, err := cm.BuildContext(ctx, "u1", "greeting", ContextOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled context's error, got %v", err)
+	}
+	if len(res.Memories) != 0 {
+		t.Errorf("expected no retrieved memories, got %d", len(res.Memories))
+	}
+}
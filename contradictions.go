@@ -0,0 +1,44 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+)
+
+// contradictionCandidates is how many of the most similar memories
+// FindContradictions considers.
+const contradictionCandidates = 10
+
+// FindContradictions returns prior memories that may contradict statement:
+// those at least threshold similar (default 0.75), closest first. Opposite
+// claims about the same subject ("love jazz" / "hate jazz") embed close
+// together, so similarity finds the candidates; Config.ContradictionChecker,
+// if set, then keeps only the ones it judges contradictory. Without a checker
+// the caller compares them. Like SimilaritySearch, nothing is reinforced.
+func (cm *Engram) FindContradictions(ctx context.Context, userID, statement string, threshold float64) ([]SearchResult, error) {
+	if threshold <= 0 {
+		threshold = 0.75
+	}
+	similar, err := cm.SimilaritySearch(ctx, userID, statement, contradictionCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("engram: find contradictions: %w", err)
+	}
+
+	var found []SearchResult
+	for _, r := range similar {
+		if r.Similarity < threshold {
+			break // ordered by similarity
+		}
+		if checker := cm.config.ContradictionChecker; checker != nil {
+			conflict, err := checker.Contradicts(ctx, statement, r.Content)
+			if err != nil {
+				return nil, fmt.Errorf("engram: contradiction check for memory #%d: %w", r.ID, err)
+			}
+			if !conflict {
+				continue
+			}
+		}
+		found = append(found, r)
+	}
+	return found, nil
+}
@@ -0,0 +1,63 @@
+package engram
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// negationChecker flags memories that share the statement's subject but not
+// its verb, e.g. "love jazz" vs "hate jazz".
+type negationChecker struct{ calls int }
+
+func (n *negationChecker) Contradicts(ctx context.Context, statement, memory string) (bool, error) {
+	n.calls++
+	return strings.Contains(statement, "hate") && strings.HasSuffix(memory, "love jazz"), nil
+}
+
+func contradictionEngram(t *testing.T, checker ContradictionChecker) *Engram {
+	emb := keywordVectors{
+		"I hate jazz":        {0.98, 0.2, 0},
+		"I love jazz":        {1, 0, 0},
+		"jazz bars are loud": {0.8, 0.6, 0},
+		"my cat is grey":     {0, 1, 0},
+	}
+	cm := testEngramConfig(t, Config{EmbeddingProvider: emb, ContradictionChecker: checker})
+	for _, content := range []string{"I love jazz", "jazz bars are loud", "my cat is grey"} {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, SectorHint: SectorSemantic})
+	}
+	return cm
+}
+
+func TestFindContradictionsReturnsClosestPriorMemories(t *testing.T) {
+	cm := contradictionEngram(t, nil)
+
+	found, err := cm.FindContradictions(context.Background(), "u1", "I hate jazz", 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Content != "I love jazz" || found[1].Content != "jazz bars are loud" {
+		t.Fatalf("expected the two jazz memories, closest first, got %v", found)
+	}
+
+	// Nothing was reinforced by looking
+	if mems, _ := cm.ListRecent("u1", 3, nil); mems[0].AccessCount != 0 {
+		t.Errorf("expected no reinforcement, got access count %d", mems[0].AccessCount)
+	}
+}
+
+func TestFindContradictionsUsesChecker(t *testing.T) {
+	checker := &negationChecker{}
+	cm := contradictionEngram(t, checker)
+
+	found, err := cm.FindContradictions(context.Background(), "u1", "I hate jazz", 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Content != "I love jazz" {
+		t.Fatalf("expected only the contradicted memory, got %v", found)
+	}
+	if checker.calls != 2 {
+		t.Errorf("expected the checker to see only the 2 similar memories, got %d calls", checker.calls)
+	}
+}
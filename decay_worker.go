@@ -2,6 +2,7 @@ package engram
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 )
@@ -12,18 +13,18 @@ func (cm *Engram) startDecayWorker(interval time.Duration) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cm.cancelDecay = cancel
 
+	cm.workers.Add(1)
 	go func() {
+		defer cm.workers.Done()
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				updated, deleted, err := cm.store.RunDecaySweep(cm.config.MinDecayScore, cm.config.decayRates)
-				if err != nil {
-					log.Printf("[engram] Decay sweep error: %v", err)
-				} else if updated > 0 || deleted > 0 {
-					log.Printf("[engram] Decay sweep: %d updated, %d deleted", updated, deleted)
+				if cm.sweeping.CompareAndSwap(false, true) {
+					cm.runWorkerCycle(WorkerDecay, cm.runDecaySweep)
+					cm.sweeping.Store(false)
 				}
 			case <-ctx.Done():
 				return
@@ -31,3 +32,86 @@ func (cm *Engram) startDecayWorker(interval time.Duration) {
 		}
 	}()
 }
+
+// runDecaySweep applies one decay sweep and records when it ran. Per-user
+// failures don't stop the sweep; the last one is returned. It pauses
+// DecayYield between users, so it must only run from a background worker,
+// never on a caller's Add with cm.mu held.
+func (cm *Engram) runDecaySweep() error {
+	cm.sweepMu.Lock()
+	cm.lastSweep = cm.config.Clock.Now()
+	cm.sweepMu.Unlock()
+
+	// One short transaction per user: with a single DB connection, a global
+	// sweep would block every live search and add until it finished.
+	userIDs, err := cm.store.GetActiveUserIDs()
+	if err != nil {
+		log.Printf("[engram] Decay sweep error: %v", err)
+		return fmt.Errorf("engram: decay sweep: %w", err)
+	}
+
+	var sweepErr error
+	var updated, deleted int
+	for i, userID := range userIDs {
+		if i > 0 && cm.config.DecayYield > 0 {
+			time.Sleep(cm.config.DecayYield)
+		}
+		u, d, err := cm.store.RunUserDecaySweep(userID, cm.config.MinDecayScore, cm.config.decayRates, cm.config.SectorMinDecayFloor)
+		if err != nil {
+			log.Printf("[engram] Decay sweep error for %s: %v", userID, err)
+			sweepErr = fmt.Errorf("engram: decay sweep for %s: %w", userID, err)
+			continue
+		}
+		updated += u
+		deleted += d
+		if cm.decayHook != nil {
+			cm.decayHook(userID)
+		}
+	}
+	if err := cm.store.DecayAssociations(); err != nil {
+		log.Printf("[engram] Association decay error: %v", err)
+		sweepErr = fmt.Errorf("engram: association decay: %w", err)
+	}
+	if updated > 0 || deleted > 0 {
+		log.Printf("[engram] Decay sweep: %d updated, %d deleted", updated, deleted)
+	}
+
+	if cm.config.ArchiveRetention > 0 {
+		purged, err := cm.store.PurgeArchived(cm.config.Clock.Now().Add(-cm.config.ArchiveRetention))
+		if err != nil {
+			log.Printf("[engram] Purge archived error: %v", err)
+			sweepErr = fmt.Errorf("engram: purge archived: %w", err)
+		} else if purged > 0 {
+			log.Printf("[engram] Purged %d archived memories", purged)
+		}
+	}
+	if err := cm.vectors.pruneAll(cm.store); err != nil {
+		log.Printf("[engram] Vector cache prune error: %v", err)
+		sweepErr = err
+	}
+	return sweepErr
+}
+
+// maybeSweepOnWrite starts a background decay sweep when DecayOnWrite is
+// enabled and no sweep has run within DecayInterval (including none since
+// startup), so decay advances with activity even if the timer never fires.
+// The sweep covers every user, so it runs off the Add path, and not at all
+// while another sweep is in flight.
+func (cm *Engram) maybeSweepOnWrite() {
+	if !cm.config.DecayOnWrite {
+		return
+	}
+	cm.sweepMu.Lock()
+	stale := cm.config.Clock.Now().Sub(cm.lastSweep) >= cm.config.DecayInterval
+	cm.sweepMu.Unlock()
+	if !stale || !cm.sweeping.CompareAndSwap(false, true) {
+		return
+	}
+	started := cm.goWorker(func() {
+		defer cm.sweeping.Store(false)
+		cm.runWorkerCycle(WorkerDecay, cm.runDecaySweep)
+	})
+	if !started {
+		cm.sweeping.Store(false)
+	}
+}
@@ -0,0 +1,164 @@
+package engram
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDecayOnWritePrunesFadingMemory(t *testing.T) {
+	cm := testEngramConfig(t, Config{DecayOnWrite: true})
+
+	cm.store.InsertMemory(Memory{Content: "fading", Sector: SectorSemantic, Salience: 0.001, UserID: "u1", Summary: "f"})
+	cm.store.db.Exec(`UPDATE memories SET last_accessed_at = '2020-01-01 00:00:00'`)
+
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "hello", AssistantMessage: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, cm, func(s WorkerStatus) bool { return !s.LastRun.IsZero() })
+
+	mems, _ := cm.ListRecent("u1", 10, nil)
+	for _, m := range mems {
+		if m.Content == "fading" {
+			t.Fatal("expected fading memory to be pruned by the on-write sweep")
+		}
+	}
+	if len(mems) != 1 {
+		t.Errorf("expected only the new memory to remain, got %d", len(mems))
+	}
+}
+
+func TestDecayOnWriteRunsOffTheAddPath(t *testing.T) {
+	cm := testEngramConfig(t, Config{DecayOnWrite: true})
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	cm.decayHook = func(string) {
+		once.Do(func() { close(held) })
+		<-release
+	}
+	defer close(release)
+
+	added := make(chan error, 1)
+	go func() {
+		_, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "hello", AssistantMessage: "hi"})
+		added <- err
+	}()
+
+	<-held
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Add waited for the decay sweep it triggered")
+	}
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u2", UserMessage: "hey", AssistantMessage: "yo"}); err != nil {
+		t.Fatalf("expected Add to proceed during the sweep, got %v", err)
+	}
+}
+
+func TestDecayOnWriteDisabledByDefault(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+
+	cm.store.InsertMemory(Memory{Content: "fading", Sector: SectorSemantic, Salience: 0.001, UserID: "u1", Summary: "f"})
+	cm.store.db.Exec(`UPDATE memories SET last_accessed_at = '2020-01-01 00:00:00'`)
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "hello", AssistantMessage: "hi"})
+
+	mems, _ := cm.ListRecent("u1", 10, nil)
+	if len(mems) != 2 {
+		t.Errorf("expected no sweep without DecayOnWrite, got %d memories", len(mems))
+	}
+}
+
+func TestDecaySweepDoesNotStallSearches(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}})
+
+	for u := 0; u < 3; u++ {
+		id, err := cm.store.InsertMemory(Memory{Content: "m", Sector: SectorSemantic, Salience: 0.5, UserID: fmt.Sprintf("user%d", u)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cm.store.InsertVector(id, SectorSemantic, []float32{1, 0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Hold the sweep between its first and second user
+	held := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	cm.decayHook = func(string) {
+		once.Do(func() {
+			close(held)
+			<-release
+		})
+	}
+
+	swept := make(chan error, 1)
+	go func() { swept <- cm.runDecaySweep() }()
+	<-held
+
+	searched := make(chan []SearchResult, 1)
+	go func() {
+		searched <- cm.SearchWithOptions(SearchOptions{UserID: "user2", QueryVector: []float32{1, 0, 0}})
+	}()
+	select {
+	case results := <-searched:
+		if len(results) != 1 {
+			t.Errorf("expected 1 result mid-sweep, got %d", len(results))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("search did not complete between per-user sweep transactions")
+	}
+
+	close(release)
+	if err := <-swept; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecayWorkerRecordsErrorsAndKeepsRunning(t *testing.T) {
+	cm := testEngramConfig(t, Config{DecayInterval: 10 * time.Millisecond})
+
+	// Break the store out from under the worker: every sweep now fails.
+	if _, err := cm.store.db.Exec(`ALTER TABLE memories RENAME TO memories_hidden`); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, cm, func(s WorkerStatus) bool { return s.ConsecutiveErrors >= 2 })
+	if s := cm.WorkerStatus()[WorkerDecay]; s.LastError == nil || s.LastRun.IsZero() {
+		t.Fatalf("expected last error and run time recorded, got %+v", s)
+	}
+
+	// The goroutine survived the failures, so restoring the table heals it.
+	if _, err := cm.store.db.Exec(`ALTER TABLE memories_hidden RENAME TO memories`); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, cm, func(s WorkerStatus) bool { return s.ConsecutiveErrors == 0 && s.LastError == nil })
+}
+
+func TestWorkerCycleRecoversPanic(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+
+	cm.runWorkerCycle(WorkerReflection, func() error { panic("boom") })
+
+	s := cm.WorkerStatus()[WorkerReflection]
+	if s.ConsecutiveErrors != 1 || s.LastError == nil {
+		t.Fatalf("expected panic recorded as an error, got %+v", s)
+	}
+}
+
+func waitForStatus(t *testing.T, cm *Engram, ok func(WorkerStatus) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if s, found := cm.WorkerStatus()[WorkerDecay]; found && ok(s) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("decay worker status never reached expected state: %+v", cm.WorkerStatus()[WorkerDecay])
+}
@@ -0,0 +1,133 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+)
+
+// FindDuplicates groups a user's memories whose embeddings are at least
+// threshold similar (default 0.95), so restatements of the same fact can be
+// reviewed or consolidated. Grouping is single-linkage: a memory joins a
+// group if it is similar enough to any member. Each group lists IDs oldest
+// first, groups are ordered by their oldest member, and memories without a
+// near-duplicate (or without a vector) appear in no group. Comparison is
+// pairwise, which is fine at per-character scale but quadratic in the number
+// of memories. Nothing is modified or reinforced.
+func (cm *Engram) FindDuplicates(userID string, threshold float64) ([][]int64, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if userID == "" {
+		return nil, nil
+	}
+	if threshold <= 0 {
+		threshold = 0.95
+	}
+
+	all, err := cm.store.GetMemoriesWithVectors(userID)
+	if err != nil {
+		return nil, fmt.Errorf("engram: find duplicates: %w", err)
+	}
+	var mwvs []memoryWithVector
+	for _, m := range all {
+		if m.Vector != nil {
+			mwvs = append(mwvs, m)
+		}
+	}
+
+	// Union-find over indexes into mwvs
+	parent := make([]int, len(mwvs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range mwvs {
+		for j := i + 1; j < len(mwvs); j++ {
+			sim := similarityWithNorms(cm.config.SimilarityMetric, mwvs[i].Vector, mwvs[i].Norm, mwvs[j].Vector, mwvs[j].Norm)
+			if sim >= threshold {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	members := make(map[int][]int64)
+	for i, m := range mwvs {
+		root := find(i)
+		members[root] = append(members[root], m.ID)
+	}
+	var groups [][]int64
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+		groups = append(groups, ids)
+	}
+	sort.Slice(groups, func(a, b int) bool { return groups[a][0] < groups[b][0] })
+	return groups, nil
+}
+
+// MergeOptions controls MergeMemoriesWithOptions.
+type MergeOptions struct {
+	KeepID   int64   // The memory that survives
+	MergeIDs []int64 // Memories folded into it and deleted
+
+	// AppendContent appends each merged memory's content that differs from
+	// those already kept (ignoring case and whitespace) to the survivor's, one
+	// per line, and re-embeds the survivor
+	AppendContent bool
+}
+
+// MergeMemories consolidates duplicates, e.g. a group from FindDuplicates,
+// into keepID. See MergeMemoriesWithOptions.
+func (cm *Engram) MergeMemories(keepID int64, mergeIDs []int64) error {
+	return cm.MergeMemoriesWithOptions(MergeOptions{KeepID: keepID, MergeIDs: mergeIDs})
+}
+
+// MergeMemoriesWithOptions folds opts.MergeIDs into opts.KeepID without losing
+// signal: the survivor takes the highest salience, the summed access count,
+// and the union of the merged memories' entity associations (each at its
+// highest weight). The merged memories are then deleted. All must belong to
+// the same user; nothing changes if any is missing.
+func (cm *Engram) MergeMemoriesWithOptions(opts MergeOptions) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	mergeIDs := slices.Compact(slices.Sorted(slices.Values(opts.MergeIDs)))
+	if len(mergeIDs) == 0 {
+		return nil
+	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	keep, err := cm.store.MergeMemories(opts.KeepID, mergeIDs, opts.AppendContent)
+	if err != nil {
+		return fmt.Errorf("engram: merge into memory %d: %w", opts.KeepID, err)
+	}
+	cm.vectors.evict(keep.UserID, mergeIDs)
+
+	// The survivor's vector no longer covers its content
+	if opts.AppendContent && cm.embedder != nil {
+		vec, err := cm.embed(context.Background(), keep.Content, cm.config.documentTaskType(keep.Sector))
+		if err != nil {
+			log.Printf("[engram] Re-embed merged memory #%d failed, keeping its old vector: %v", keep.ID, err)
+			return nil
+		}
+		if err := cm.store.ReplaceVector(keep.ID, keep.Sector, vec); err != nil {
+			return fmt.Errorf("engram: store merged memory %d vector: %w", keep.ID, err)
+		}
+		cm.vectors.add(keep.UserID, keep.ID, vec)
+	}
+	return nil
+}
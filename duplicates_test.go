@@ -0,0 +1,147 @@
+package engram
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindDuplicatesClustersRestatements(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+	var ids []int64
+	for _, m := range []struct {
+		content string
+		vec     []float32
+	}{
+		{"the user has a dog named Biscuit", []float32{1, 0, 0}},
+		{"the user is afraid of heights", []float32{0, 1, 0}},
+		{"user's dog is called Biscuit", []float32{0.99, 0.1, 0}},
+		{"Biscuit is the user's dog", []float32{0.98, 0, 0.15}},
+	} {
+		id, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: m.content, Vector: m.vec, SectorHint: SectorSemantic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// Another user's identical memory is never grouped with u1's
+	cm.AddWithOptions(AddOptions{UserID: "u2", RawContent: "the user has a dog named Biscuit", Vector: []float32{1, 0, 0}, SectorHint: SectorSemantic})
+
+	groups, err := cm.FindDuplicates("u1", 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 duplicate group, got %v", groups)
+	}
+	if want := []int64{ids[0], ids[2], ids[3]}; !slices.Equal(groups[0], want) {
+		t.Errorf("expected the three restatements %v grouped, got %v", want, groups[0])
+	}
+
+	if groups, _ := cm.FindDuplicates("u1", 0.999); len(groups) != 0 {
+		t.Errorf("expected no groups at a stricter threshold, got %v", groups)
+	}
+}
+
+func TestMergeMemoriesPreservesSignal(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+	s := cm.store
+	var ids []int64
+	for _, m := range []struct {
+		content  string
+		salience float64
+		accesses int
+	}{
+		{"the user has a dog named Biscuit", 0.4, 2},
+		{"user's dog is called Biscuit", 0.8, 3},
+		{"Biscuit is the user's dog", 0.5, 1},
+	} {
+		id, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: m.content, Vector: []float32{1, 0, 0}, Salience: m.salience, SectorHint: SectorSemantic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.db.Exec(`UPDATE memories SET access_count = ? WHERE id = ?`, m.accesses, id)
+		ids = append(ids, id)
+	}
+	biscuit, _ := s.UpsertWaypoint("Biscuit", "pet")
+	park, _ := s.UpsertWaypoint("the park", "place")
+	vet, _ := s.UpsertWaypoint("the vet", "place")
+	s.InsertAssociation(ids[0], biscuit, 0.5)
+	s.InsertAssociation(ids[1], biscuit, 0.9)
+	s.InsertAssociation(ids[1], park, 0.5)
+	s.InsertAssociation(ids[2], vet, 0.6)
+
+	if err := cm.MergeMemories(ids[0], ids[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	mems, _ := cm.ListRecent("u1", 10, nil)
+	if len(mems) != 1 || mems[0].ID != ids[0] {
+		t.Fatalf("expected only the survivor #%d left, got %v", ids[0], mems)
+	}
+	if mems[0].Salience != 0.8 || mems[0].AccessCount != 6 {
+		t.Errorf("expected salience 0.8 and 6 accesses, got %v and %d", mems[0].Salience, mems[0].AccessCount)
+	}
+	if mems[0].Content != "the user has a dog named Biscuit" {
+		t.Errorf("expected content unchanged without AppendContent, got %q", mems[0].Content)
+	}
+
+	weights := map[int64]float64{}
+	rows, _ := s.db.Query(`SELECT waypoint_id, weight FROM associations WHERE memory_id = ?`, ids[0])
+	for rows.Next() {
+		var wp int64
+		var w float64
+		rows.Scan(&wp, &w)
+		weights[wp] = w
+	}
+	rows.Close()
+	if len(weights) != 3 || weights[biscuit] != 0.9 || weights[park] != 0.5 || weights[vet] != 0.6 {
+		t.Errorf("expected the union of associations at max weight, got %v", weights)
+	}
+
+	var orphans int
+	s.db.QueryRow(`SELECT COUNT(*) FROM vectors WHERE memory_id IN (?, ?)`, ids[1], ids[2]).Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("expected the merged memories' vectors deleted, got %d", orphans)
+	}
+
+	if err := cm.MergeMemories(ids[0], []int64{ids[1]}); err == nil {
+		t.Error("expected an error merging a deleted memory")
+	}
+}
+
+func TestMergeMemoriesAppendsDistinctContent(t *testing.T) {
+	// Content is whitespace-normalized before embedding
+	emb := keywordVectors{"likes jazz plays the saxophone": {0, 1, 0}}
+	cm := testEngramConfig(t, Config{EmbeddingProvider: emb, EventLog: true})
+	var ids []int64
+	for _, content := range []string{"likes jazz", "Likes  jazz", "plays the saxophone"} {
+		id, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Vector: []float32{1, 0, 0}, SectorHint: SectorSemantic})
+		ids = append(ids, id)
+	}
+
+	if err := cm.MergeMemoriesWithOptions(MergeOptions{KeepID: ids[0], MergeIDs: ids[1:], AppendContent: true}); err != nil {
+		t.Fatal(err)
+	}
+	mems, _ := cm.ListRecent("u1", 10, nil)
+	if len(mems) != 1 || mems[0].Content != "likes jazz\nplays the saxophone" {
+		t.Fatalf("expected the distinct content appended once, got %v", mems)
+	}
+	if mems[0].Summary != "likes jazz | plays the saxophone" {
+		t.Errorf("expected the summary to cover the appended content, got %q", mems[0].Summary)
+	}
+
+	vecs, err := cm.store.GetVectors([]int64{ids[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(vecs[ids[0]], []float32{0, 1, 0}) {
+		t.Errorf("expected the survivor re-embedded from its merged content, got %v", vecs[ids[0]])
+	}
+
+	var types []EventType
+	cm.ReplayEvents(func(e Event) error { types = append(types, e.Type); return nil })
+	want := []EventType{EventAdd, EventAdd, EventAdd, EventMerge, EventDelete, EventDelete}
+	if !slices.Equal(types, want) {
+		t.Errorf("expected events %v, got %v", want, types)
+	}
+}
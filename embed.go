@@ -4,17 +4,38 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"time"
 )
 
+// ErrDegenerateEmbedding is returned by embedders when a provider yields a
+// vector with a zero or non-finite norm. Such vectors would score 0 (or NaN)
+// against every query, so they are rejected rather than stored.
+var ErrDegenerateEmbedding = errors.New("degenerate embedding: zero or non-finite norm")
+
+// validateEmbedding checks that a vector has a nonzero, finite norm.
+func validateEmbedding(vec []float32) error {
+	var norm float64
+	for _, v := range vec {
+		f := float64(v)
+		norm += f * f
+	}
+	if norm == 0 || math.IsNaN(norm) || math.IsInf(norm, 0) {
+		return ErrDegenerateEmbedding
+	}
+	return nil
+}
+
 // GeminiEmbedder generates vector embeddings via the Gemini API.
 // Implements EmbeddingProvider.
 type GeminiEmbedder struct {
 	apiKey    string
 	dimension int
+	baseURL   string // Gemini embedContent URL (overridable for tests)
 	client    *http.Client
 }
 
@@ -23,18 +44,21 @@ func NewGeminiEmbedder(apiKey string, dimension int) *GeminiEmbedder {
 	return &GeminiEmbedder{
 		apiKey:    apiKey,
 		dimension: dimension,
+		baseURL:   "https://generativelanguage.googleapis.com/v1beta/models/gemini-embedding-001:embedContent",
 		client:    &http.Client{Timeout: 5 * time.Second},
 	}
 }
 
 // Embed generates a vector for the given text.
-// taskType should be "RETRIEVAL_QUERY" for search queries or "RETRIEVAL_DOCUMENT" for stored memories.
+// taskType is a Gemini task type: Engram passes "RETRIEVAL_QUERY" for search
+// queries and "RETRIEVAL_DOCUMENT" for stored memories unless configured
+// otherwise (Config.QueryTaskType, Config.EmbedTaskTypes).
 func (e *GeminiEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
 	if e.apiKey == "" {
 		return nil, fmt.Errorf("no API key")
 	}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-embedding-001:embedContent?key=" + e.apiKey
+	url := e.baseURL + "?key=" + e.apiKey
 
 	reqBody := geminiEmbedRequest{
 		Content: geminiEmbedContent{
@@ -80,6 +104,9 @@ func (e *GeminiEmbedder) Embed(ctx context.Context, text, taskType string) ([]fl
 	for i, v := range geminiResp.Embedding.Values {
 		vec[i] = float32(v)
 	}
+	if err := validateEmbedding(vec); err != nil {
+		return nil, err
+	}
 	return vec, nil
 }
 
@@ -0,0 +1,88 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+)
+
+const metaEmbedDimension = "embed_dimension"
+
+// dimensionDetector wraps an EmbeddingProvider whose configured dimension may
+// not match what it actually returns. The first successful embedding fixes the
+// dimension, which is persisted so vectors from later opens stay comparable.
+type dimensionDetector struct {
+	EmbeddingProvider
+	store *Store
+
+	mu       sync.RWMutex
+	detected int // 0 until the first embedding (or a previously stored value)
+}
+
+func newDimensionDetector(inner EmbeddingProvider, store *Store) (*dimensionDetector, error) {
+	d := &dimensionDetector{EmbeddingProvider: inner, store: store}
+
+	stored, err := store.GetMeta(metaEmbedDimension)
+	if err != nil {
+		return nil, fmt.Errorf("engram: load embed dimension: %w", err)
+	}
+	if stored != "" {
+		d.detected, _ = strconv.Atoi(stored)
+		if requested := inner.Dimension(); requested > 0 && requested != d.detected {
+			log.Printf("[engram] Embedder requests dimension %d but database holds %d-dim vectors; using %d", requested, d.detected, d.detected)
+		}
+	}
+	return d, nil
+}
+
+// Embed delegates to the wrapped provider, recording the dimension of the
+// first result and rejecting later results that don't match it.
+func (d *dimensionDetector) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	vec, err := d.EmbeddingProvider.Embed(ctx, text, taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.detected == 0 {
+		if requested := d.EmbeddingProvider.Dimension(); requested > 0 && requested != len(vec) {
+			log.Printf("[engram] Embedder returned %d-dim vectors but %d was requested; using detected dimension %d", len(vec), requested, len(vec))
+		}
+		if err := d.store.SetMeta(metaEmbedDimension, strconv.Itoa(len(vec))); err != nil {
+			return nil, fmt.Errorf("engram: store embed dimension: %w", err)
+		}
+		d.detected = len(vec)
+		return vec, nil
+	}
+
+	if len(vec) != d.detected {
+		return nil, fmt.Errorf("engram: embedder returned %d-dim vector, expected detected dimension %d", len(vec), d.detected)
+	}
+	return vec, nil
+}
+
+// Dimension returns the detected dimension once known, else the requested one.
+func (d *dimensionDetector) Dimension() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.detected > 0 {
+		return d.detected
+	}
+	return d.EmbeddingProvider.Dimension()
+}
+
+// redetect replaces the detected dimension with dim after the stored vectors
+// have been re-embedded at that size.
+func (d *dimensionDetector) redetect(dim int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.store.SetMeta(metaEmbedDimension, strconv.Itoa(dim)); err != nil {
+		return fmt.Errorf("engram: store embed dimension: %w", err)
+	}
+	d.detected = dim
+	return nil
+}
@@ -0,0 +1,75 @@
+package engram
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// misreportingEmbedder claims one dimension but returns vectors of another.
+type misreportingEmbedder struct {
+	claimed, actual int
+}
+
+func (m misreportingEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	vec := make([]float32, m.actual)
+	vec[0] = 1
+	return vec, nil
+}
+
+func (m misreportingEmbedder) Dimension() int { return m.claimed }
+
+func TestAutoDetectDimensionAdoptsActualSize(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	path := filepath.Join(t.TempDir(), "detect.db")
+	cm, err := Init(Config{
+		DBPath:              path,
+		DecayInterval:       999999 * 1e9,
+		EmbeddingProvider:   misreportingEmbedder{claimed: 768, actual: 512},
+		AutoDetectDimension: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "I keep bees", AssistantMessage: "How many hives?"}); err != nil {
+		t.Fatal(err)
+	}
+	if dim := cm.embedder.Dimension(); dim != 512 {
+		t.Errorf("expected detected dimension 512, got %d", dim)
+	}
+	if !strings.Contains(buf.String(), "using detected dimension 512") {
+		t.Errorf("expected mismatch warning in log, got %q", buf.String())
+	}
+	results := cm.Search("bees", "u1", 5, nil)
+	if len(results) != 1 || results[0].Similarity < 0.99 {
+		t.Errorf("expected retrieval to work with detected dimension, got %v", results)
+	}
+	cm.Close()
+
+	// A later open with a differently-sized embedder is rejected at embed time
+	cm, err = Init(Config{
+		DBPath:              path,
+		DecayInterval:       999999 * 1e9,
+		EmbeddingProvider:   misreportingEmbedder{claimed: 768, actual: 768},
+		AutoDetectDimension: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	if dim := cm.embedder.Dimension(); dim != 512 {
+		t.Errorf("expected stored dimension 512 after reopen, got %d", dim)
+	}
+	if _, err := cm.embedder.Embed(context.Background(), "x", "RETRIEVAL_QUERY"); err == nil {
+		t.Error("expected error embedding with a dimension that differs from the stored one")
+	}
+}
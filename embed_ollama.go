@@ -90,6 +90,9 @@ func (e *OllamaEmbedder) Embed(ctx context.Context, text, taskType string) ([]fl
 	for i, v := range ollamaResp.Embeddings[0] {
 		vec[i] = float32(v)
 	}
+	if err := validateEmbedding(vec); err != nil {
+		return nil, err
+	}
 	return vec, nil
 }
 
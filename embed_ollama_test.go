@@ -3,6 +3,7 @@ package engram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -86,6 +87,24 @@ func TestOllamaEmbedderEmptyEmbedding(t *testing.T) {
 	}
 }
 
+func TestOllamaEmbedderZeroEmbedding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ollamaEmbedResponse{
+			Embeddings: [][]float64{{0, 0, 0}},
+		})
+	}))
+	defer srv.Close()
+
+	e := NewOllamaEmbedder("model", 3, WithOllamaHost(srv.URL))
+	vec, err := e.Embed(context.Background(), "test", "")
+	if !errors.Is(err, ErrDegenerateEmbedding) {
+		t.Errorf("expected ErrDegenerateEmbedding for all-zero vector, got %v", err)
+	}
+	if vec != nil {
+		t.Errorf("expected nil vector, got %v", vec)
+	}
+}
+
 func TestOllamaEmbedderDimension(t *testing.T) {
 	e := NewOllamaEmbedder("nomic-embed-text", 768)
 	if e.Dimension() != 768 {
@@ -107,6 +107,9 @@ func (e *OpenAIEmbedder) Embed(ctx context.Context, text, taskType string) ([]fl
 	for i, v := range oaiResp.Data[0].Embedding {
 		vec[i] = float32(v)
 	}
+	if err := validateEmbedding(vec); err != nil {
+		return nil, err
+	}
 	return vec, nil
 }
 
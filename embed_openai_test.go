@@ -3,6 +3,7 @@ package engram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -99,6 +100,21 @@ func TestOpenAIEmbedderEmptyEmbedding(t *testing.T) {
 	}
 }
 
+func TestOpenAIEmbedderZeroEmbedding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openAIEmbedResponse{
+			Data: []openAIEmbedData{{Embedding: []float64{0, 0, 0}}},
+		})
+	}))
+	defer srv.Close()
+
+	e := NewOpenAIEmbedder("test-key", WithOpenAIBaseURL(srv.URL), WithOpenAIDimension(3))
+	_, err := e.Embed(context.Background(), "test", "")
+	if !errors.Is(err, ErrDegenerateEmbedding) {
+		t.Errorf("expected ErrDegenerateEmbedding for all-zero vector, got %v", err)
+	}
+}
+
 func TestOpenAIEmbedderDimension(t *testing.T) {
 	e := NewOpenAIEmbedder("key", WithOpenAIDimension(768))
 	if e.Dimension() != 768 {
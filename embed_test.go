@@ -0,0 +1,47 @@
+package engram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPerSectorEmbedTaskTypesReachGemini(t *testing.T) {
+	var mu sync.Mutex
+	taskTypes := make(map[string]string) // text -> task type
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiEmbedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		taskTypes[req.Content.Parts[0].Text] = req.TaskType
+		mu.Unlock()
+		w.Write([]byte(`{"embedding": {"values": [1, 0, 0]}}`))
+	}))
+	defer srv.Close()
+
+	e := NewGeminiEmbedder("test-key", 3)
+	e.baseURL = srv.URL
+	cm := testEngramConfig(t, Config{
+		EmbeddingProvider: e,
+		EmbedTaskTypes:    map[Sector]string{SectorEmotional: "CLASSIFICATION"},
+		QueryTaskType:     "QUESTION_ANSWERING",
+	})
+
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "felt betrayed", SectorHint: SectorEmotional})
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "lives in Lisbon", SectorHint: SectorSemantic})
+	cm.Search("where do they live?", "u1", 2, nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for text, want := range map[string]string{
+		"felt betrayed":       "CLASSIFICATION",
+		"lives in Lisbon":     "RETRIEVAL_DOCUMENT",
+		"where do they live?": "QUESTION_ANSWERING",
+	} {
+		if got := taskTypes[text]; got != want {
+			t.Errorf("%q: expected task type %q, got %q", text, want, got)
+		}
+	}
+}
@@ -2,15 +2,34 @@ package engram
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"math"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// exchangeSeparator joins the halves of an exchange into a memory's content.
+// Migration 17 splits legacy content on its first occurrence.
+const exchangeSeparator = " | "
+
+// ErrClosed is returned by Engram methods called after Close.
+var ErrClosed = errors.New("engram: use of closed Engram")
+
+// queryClassifier infers a query's sector for SearchOptions.QuerySectorAffinity.
+// Heuristic only: queries are never sent to an LLM for classification.
+var queryClassifier = NewHeuristicClassifier("")
+
 // scored pairs a memory+vector with its computed similarity to the query.
 type scored struct {
 	memoryWithVector
-	similarity float64
+	similarity  float64
+	entityBoost float64 // Config.EntityTypeRecallBoost multiplier (0 = none)
 }
 
 // Engram is the cognitive memory engine.
@@ -21,10 +40,27 @@ type Engram struct {
 	classifier    SectorClassifier
 	extractor     EntityExtractor
 	reflector     ReflectionProvider
+	limiter       *addLimiter   // nil = no Add rate limit
+	reflectPacer  *callPacer    // nil = no reflection provider rate limit
+	vectors       *vectorCache  // vectors of users preloaded by Warm
+	asyncSlots    chan struct{} // bounds concurrent AddAsync work
 	config        Config
 	mu            sync.RWMutex
 	cancelDecay   context.CancelFunc
 	cancelReflect context.CancelFunc
+	workers       sync.WaitGroup // background workers, waited on by Close
+	calls         sync.WaitGroup // in-flight public calls, waited on by Close
+	lifecycle     sync.Mutex     // orders closed against calls.Add and workers.Add
+	sweepMu       sync.Mutex
+	lastSweep     time.Time      // when the last decay sweep started
+	sweeping      atomic.Bool    // a decay sweep is in flight
+	sinceReflect  map[string]int // per-user adds since the last count-triggered reflection, guarded by mu
+	closed        atomic.Bool    // set by Close; public methods then return ErrClosed
+	reembedding   sync.Map       // user IDs with a background re-embed in flight
+	reflecting    sync.Map       // user IDs with a count-triggered reflection in flight
+	statusMu      sync.Mutex
+	status        map[string]WorkerStatus // per-worker health, see WorkerStatus
+	decayHook     func(userID string)     // test hook, run after each user's decay transaction
 }
 
 // Init creates an Engram instance, runs DB migrations, and starts the decay worker.
@@ -35,12 +71,36 @@ func Init(cfg Config) (*Engram, error) {
 	if err != nil {
 		return nil, err
 	}
+	store.clock = cfg.Clock
+	store.arousalDecay = cfg.EmotionalArousalDecay
+	store.deleteBatch = cfg.DecayDeleteBatch
+	if cfg.ReadReplica {
+		if err := store.OpenReadReplica(); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	if err := store.SetMeta("event_log", boolMeta(cfg.EventLog)); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("engram: store event log setting: %w", err)
+	}
+	if err := store.SetMeta("parent_on_delete", string(cfg.ParentOnDelete)); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("engram: store parent policy: %w", err)
+	}
 
 	// Resolve providers: use explicit config, or construct defaults from GeminiAPIKey
 	embedder := cfg.EmbeddingProvider
 	if embedder == nil && cfg.GeminiAPIKey != "" {
 		embedder = NewGeminiEmbedder(cfg.GeminiAPIKey, cfg.EmbedDimension)
 	}
+	if embedder != nil && cfg.AutoDetectDimension {
+		embedder, err = newDimensionDetector(embedder, store)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
 
 	classifier := cfg.Classifier
 	if classifier == nil {
@@ -56,13 +116,31 @@ func Init(cfg Config) (*Engram, error) {
 		extractor = &DefaultEntityExtractor{}
 	}
 
+	if cfg.ReflectionPromptTemplate != "" {
+		if gr, ok := cfg.ReflectionProvider.(*GeminiReflector); ok {
+			gr.SetPromptTemplate(cfg.ReflectionPromptTemplate)
+		} else {
+			log.Printf("[engram] ReflectionPromptTemplate set but provider is not a GeminiReflector; ignoring")
+		}
+	}
+
 	cm := &Engram{
-		store:      store,
-		embedder:   embedder,
-		classifier: classifier,
-		extractor:  extractor,
-		reflector:  cfg.ReflectionProvider, // explicit opt-in only, never auto-constructed
-		config:     cfg,
+		store:        store,
+		embedder:     embedder,
+		classifier:   classifier,
+		extractor:    extractor,
+		reflector:    cfg.ReflectionProvider, // explicit opt-in only, never auto-constructed
+		config:       cfg,
+		asyncSlots:   make(chan struct{}, cfg.MaxAsyncAdds),
+		vectors:      newVectorCache(),
+		sinceReflect: make(map[string]int),
+	}
+	if cfg.MaxAddsPerMinute > 0 {
+		cm.limiter = newAddLimiter(cfg.MaxAddsPerMinute)
+		cm.limiter.now = cfg.Clock.Now
+	}
+	if cfg.ReflectionCallsPerMinute > 0 {
+		cm.reflectPacer = newCallPacer(cfg.ReflectionCallsPerMinute)
 	}
 
 	cm.startDecayWorker(cfg.DecayInterval)
@@ -79,107 +157,12 @@ func Init(cfg Config) (*Engram, error) {
 
 // Search retrieves relevant memories for a user, scored by the composite formula.
 func (cm *Engram) Search(query, userID string, limit int, weights SectorWeights) []SearchResult {
-	if userID == "" {
-		return nil
-	}
-	if limit <= 0 {
-		limit = 5
-	}
-	if weights == nil {
-		weights = DefaultSectorWeights()
-	}
-
-	// 1. Embed the query
-	if cm.embedder == nil {
-		log.Printf("[engram] No embedding provider configured")
-		return nil
-	}
-	queryVec, err := cm.embedder.Embed(context.Background(), query, "RETRIEVAL_QUERY")
-	if err != nil {
-		log.Printf("[engram] Embed query failed: %v", err)
-		return nil
-	}
-
-	// 2. Load all memories + vectors for this user
-	candidates, err := cm.store.GetMemoriesWithVectors(userID)
-	if err != nil {
-		log.Printf("[engram] Load memories failed: %v", err)
-		return nil
-	}
-	if len(candidates) == 0 {
-		return nil
-	}
-
-	// 3. Compute similarity for each candidate
-	var scoredCandidates []scored
-	for _, c := range candidates {
-		if c.Vector == nil {
-			continue
-		}
-		sim := CosineSimilarity(queryVec, c.Vector)
-		scoredCandidates = append(scoredCandidates, scored{c, sim})
-	}
-
-	// Sort by similarity, take top candidates for waypoint expansion
-	sort.Slice(scoredCandidates, func(i, j int) bool {
-		return scoredCandidates[i].similarity > scoredCandidates[j].similarity
-	})
-
-	// Cap candidates for expansion (top 20 by similarity)
-	expandLimit := 20
-	if len(scoredCandidates) < expandLimit {
-		expandLimit = len(scoredCandidates)
-	}
-	topCandidates := scoredCandidates[:expandLimit]
-
-	// 4. Expand via waypoint graph (one-hop)
-	seedMWVs := make([]memoryWithVector, len(topCandidates))
-	for i, sc := range topCandidates {
-		seedMWVs[i] = sc.memoryWithVector
-	}
-	linkWeights := ExpandViaWaypoints(cm.store, seedMWVs, userID)
-
-	sw := cm.config.scoringWeights
-
-	// 5. Compute composite scores with personality weights
-	var results []SearchResult
-	for _, sc := range scoredCandidates {
-		sectorWeight := weights[sc.Sector]
-		if sectorWeight == 0 {
-			sectorWeight = 1.0
-		}
-
-		linkWeight := linkWeights[sc.ID] // 0 if not linked
-
-		days := DaysSince(sc.LastAccessedAt)
-		composite := CompositeScore(sc.similarity, sc.DecayScore, days, linkWeight, sectorWeight, sw)
-
-		results = append(results, SearchResult{
-			Memory:         sc.Memory,
-			CompositeScore: composite,
-			Similarity:     sc.similarity,
-		})
-	}
-
-	// 6. Sort by composite score, take top-k
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].CompositeScore > results[j].CompositeScore
+	return cm.SearchWithOptions(SearchOptions{
+		Query:   query,
+		UserID:  userID,
+		Limit:   limit,
+		Weights: weights,
 	})
-	if len(results) > limit {
-		results = results[:limit]
-	}
-
-	// 6b. High-salience guarantee
-	results = cm.guaranteeHighSalience(results, scoredCandidates, weights, linkWeights, limit)
-
-	// 7. Reinforce accessed memories
-	for _, r := range results {
-		if err := cm.store.ReinforceSalience(r.ID, 0.15); err != nil {
-			log.Printf("[engram] Reinforce failed for memory %d: %v", r.ID, err)
-		}
-	}
-
-	return results
 }
 
 // Add stores a new memory from a conversation exchange.
@@ -192,30 +175,115 @@ func (cm *Engram) Add(userMessage, assistantMessage, userID string) {
 	})
 }
 
+// AddAsync stores a memory in the background so the caller doesn't wait on
+// the embedding round-trip. The returned channel (buffered, so it may be
+// ignored) receives exactly one AddResult. At most Config.MaxAsyncAdds adds
+// run at once; Close waits for pending ones. After Close the result is ErrClosed.
+func (cm *Engram) AddAsync(opts AddOptions) <-chan AddResult {
+	ch := make(chan AddResult, 1)
+	if err := cm.enter(); err != nil {
+		ch <- AddResult{Err: err}
+		return ch
+	}
+	defer cm.leave()
+	cm.workers.Add(1)
+	go func() {
+		defer cm.workers.Done()
+		cm.asyncSlots <- struct{}{}
+		defer func() { <-cm.asyncSlots }()
+
+		id, err := cm.addWithOptions(opts) // accepted before Close, so Close lets it finish
+		ch <- AddResult{ID: id, Err: err}
+	}()
+	return ch
+}
+
 // AddWithOptions stores a new memory with full temporal and metadata control.
 // Returns the memory ID (useful for chaining parent_id) and any error.
 func (cm *Engram) AddWithOptions(opts AddOptions) (int64, error) {
+	if err := cm.enter(); err != nil {
+		return 0, err
+	}
+	defer cm.leave()
+	return cm.addWithOptions(opts)
+}
+
+func (cm *Engram) addWithOptions(opts AddOptions) (int64, error) {
 	if opts.UserID == "" {
 		return 0, nil
 	}
+	if err := cm.checkDimension(opts.Vector); err != nil {
+		return 0, err
+	}
+	if opts.DecayLambda < 0 {
+		return 0, fmt.Errorf("engram: decay lambda must not be negative, got %v", opts.DecayLambda)
+	}
+	if opts.Arousal < 0 || opts.Arousal > 1 {
+		return 0, fmt.Errorf("engram: arousal must be between 0 and 1, got %v", opts.Arousal)
+	}
+	if opts.Confidence < 0 || opts.Confidence > 1 {
+		return 0, fmt.Errorf("engram: confidence must be between 0 and 1, got %v", opts.Confidence)
+	}
+	// A retried request returns the original memory; checked before the rate
+	// limit so retries don't spend the user's budget
+	if opts.IdempotencyKey != "" {
+		id, err := cm.store.GetMemoryIDByIdempotencyKey(opts.UserID, opts.IdempotencyKey)
+		if err != nil || id != 0 {
+			return id, err
+		}
+	}
+	if cm.limiter != nil && !cm.limiter.allow(opts.UserID) {
+		return 0, ErrRateLimited
+	}
 
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	// 1. Build content
-	content := opts.UserMessage + " | " + opts.AssistantMessage
+	// 1. Build content (raw observations skip the exchange format)
+	content := opts.UserMessage + exchangeSeparator + opts.AssistantMessage
+	if opts.RawContent != "" {
+		content = opts.RawContent
+	}
+	captions := mediaCaptions(opts.MediaRefs)
+	captionOnly := captions != "" && opts.RawContent == "" && opts.UserMessage == "" && opts.AssistantMessage == ""
+	if captionOnly {
+		content = captions
+	} else if captions != "" {
+		content += exchangeSeparator + captions
+	}
+
+	// Redact before anything downstream (classifier, embedder, extractor) sees it
+	if redact := cm.config.RedactFunc; redact != nil {
+		content = redact(content)
+		opts.UserMessage = redact(opts.UserMessage)
+		opts.AssistantMessage = redact(opts.AssistantMessage)
+		opts.RawContent = redact(opts.RawContent)
+		captions = redact(captions)
+		if opts.MediaRefs != nil {
+			// Copy so the caller's slice isn't rewritten
+			refs := make([]MediaRef, len(opts.MediaRefs))
+			for i, ref := range opts.MediaRefs {
+				refs[i] = MediaRef{URI: redact(ref.URI), Caption: redact(ref.Caption)}
+			}
+			opts.MediaRefs = refs
+		}
+	}
 
 	// 2. Classify sector (or use hint)
 	sector := opts.SectorHint
+	var classifyConfidence *float64
 	if sector == "" {
-		sector = cm.classifier.Classify(content)
+		sector = cm.classifySector(content, opts)
+		if cm.config.RecordClassifyConfidence {
+			classifyConfidence = cm.classifyConfidence(content, opts, sector)
+		}
 	}
 
-	// 3. Generate embedding
-	var vec []float32
-	if cm.embedder != nil {
+	// 3. Generate embedding (unless the caller supplied one)
+	vec := opts.Vector
+	if vec == nil && cm.embedder != nil {
 		var err error
-		vec, err = cm.embedder.Embed(context.Background(), content, "RETRIEVAL_DOCUMENT")
+		vec, err = cm.embed(context.Background(), content, cm.config.documentTaskType(sector))
 		if err != nil {
 			log.Printf("[engram] Embed failed, storing without vector: %v", err)
 		}
@@ -223,6 +291,11 @@ func (cm *Engram) AddWithOptions(opts AddOptions) (int64, error) {
 
 	// 4. Generate summary
 	summary := buildSummary(opts.UserMessage, opts.AssistantMessage, 200)
+	if opts.RawContent != "" {
+		summary = truncateSummary(opts.RawContent, 200)
+	} else if captionOnly {
+		summary = truncateSummary(captions, 200)
+	}
 
 	// 5. Resolve salience
 	salience := opts.Salience
@@ -230,18 +303,37 @@ func (cm *Engram) AddWithOptions(opts AddOptions) (int64, error) {
 		salience = 0.5
 	}
 
+	if cm.config.RecordSessionMarkers && opts.SessionID != "" {
+		cm.recordSessionMarker(opts.UserID, opts.SessionID)
+	}
+
 	// 6. Store memory
 	mem := Memory{
-		Content:   content,
-		Sector:    sector,
-		Salience:  salience,
-		UserID:    opts.UserID,
-		Summary:   summary,
-		SessionID: opts.SessionID,
-		ParentID:  opts.ParentID,
+		Content:            content,
+		Sector:             sector,
+		Salience:           salience,
+		UserID:             opts.UserID,
+		Summary:            summary,
+		SessionID:          opts.SessionID,
+		ParentID:           opts.ParentID,
+		UserMessage:        opts.UserMessage,
+		AssistantMessage:   opts.AssistantMessage,
+		Valence:            opts.Valence,
+		Arousal:            opts.Arousal,
+		Confidence:         opts.Confidence,
+		MediaRefs:          opts.MediaRefs,
+		IdempotencyKey:     opts.IdempotencyKey,
+		DecayLambda:        opts.DecayLambda,
+		ClassifyConfidence: classifyConfidence,
 	}
 	memID, err := cm.store.InsertMemory(mem)
 	if err != nil {
+		// A concurrent retry with the same key won the unique index
+		if opts.IdempotencyKey != "" {
+			if id, lookupErr := cm.store.GetMemoryIDByIdempotencyKey(opts.UserID, opts.IdempotencyKey); lookupErr == nil && id != 0 {
+				return id, nil
+			}
+		}
 		log.Printf("[engram] Insert memory failed: %v", err)
 		return 0, err
 	}
@@ -250,13 +342,21 @@ func (cm *Engram) AddWithOptions(opts AddOptions) (int64, error) {
 	if vec != nil {
 		if err := cm.store.InsertVector(memID, sector, vec); err != nil {
 			log.Printf("[engram] Insert vector failed: %v", err)
+			vec = nil
 		}
 	}
+	cm.vectors.add(opts.UserID, memID, vec)
 
 	// 7b. Submit for async LLM reclassification (if available and no manual hint)
 	if opts.SectorHint == "" {
 		if lc, ok := cm.classifier.(*LLMClassifier); ok {
-			lc.SubmitForReclassification(memID, content)
+			if cm.config.SyncReclassify {
+				if err := lc.ReclassifySync(context.Background(), memID, content); err != nil {
+					log.Printf("[engram] LLM reclassify failed for memory #%d: %v", memID, err)
+				}
+			} else {
+				lc.SubmitForReclassification(memID, content)
+			}
 		}
 	}
 
@@ -264,64 +364,267 @@ func (cm *Engram) AddWithOptions(opts AddOptions) (int64, error) {
 	entities := opts.Entities
 	if entities == nil {
 		entities = cm.extractor.Extract(content)
-	}
-	for _, entity := range entities {
-		wpID, err := cm.store.UpsertWaypoint(entity.Text, entity.Type)
-		if err != nil {
-			continue
+		// Extractors return entities in priority order; keep the strongest
+		if len(entities) > cm.config.MaxEntitiesPerMemory {
+			entities = entities[:cm.config.MaxEntitiesPerMemory]
 		}
-		cm.store.InsertAssociation(memID, wpID, 0.5)
+	}
+	if err := cm.store.upsertWaypointsAndAssociate(memID, entities, cm.entityWeights(entities, opts)); err != nil {
+		log.Printf("[engram] Link entities failed for memory #%d: %v", memID, err)
 	}
 
 	// 9. Enforce per-user memory cap
-	if err := cm.store.EnforceMemoryLimit(opts.UserID, cm.config.MaxMemoriesPerUser); err != nil {
+	if capped, err := cm.store.enforceMemoryLimit(opts.UserID, cm.config.MaxMemoriesPerUser); err != nil {
 		log.Printf("[engram] Enforce limit failed: %v", err)
+	} else {
+		cm.vectors.evict(opts.UserID, capped)
 	}
 
+	// 10. Advance decay in the background if the timer has fallen behind
+	cm.maybeSweepOnWrite()
+
+	// 11. Reflect once enough new memories have accumulated
+	cm.countTowardReflection(opts.UserID, sector)
+
 	log.Printf("[engram] Stored memory #%d [%s] for %s (%d entities)", memID, sector, opts.UserID, len(entities))
 	return memID, nil
 }
 
+// classifySector classifies a new memory's content, or with
+// Config.ExchangeUserWeight set, a plain exchange from its two halves when
+// the classifier supports it.
+func (cm *Engram) classifySector(content string, opts AddOptions) Sector {
+	if ec, ok := cm.classifier.(ExchangeClassifier); ok && cm.classifiesExchange(opts) {
+		return ec.ClassifyExchange(opts.UserMessage, opts.AssistantMessage, cm.config.ExchangeUserWeight)
+	}
+	return cm.classifier.Classify(content)
+}
+
+// classifiesExchange reports whether an add is classified from its exchange
+// halves (Config.ExchangeUserWeight) rather than its joined content.
+func (cm *Engram) classifiesExchange(opts AddOptions) bool {
+	return cm.config.ExchangeUserWeight > 0 &&
+		opts.RawContent == "" && opts.UserMessage != "" && opts.AssistantMessage != "" && len(opts.MediaRefs) == 0
+}
+
+// classifyConfidence returns the confidence of the heuristic classification
+// classifySector ran to pick sector, exchange-weighted when it used the
+// halves. nil when the classifier can't report one: a custom
+// SectorClassifier, or Gemini's fallback overriding the heuristic.
+func (cm *Engram) classifyConfidence(content string, opts AddOptions, sector Sector) *float64 {
+	var heuristic *HeuristicClassifier
+	switch c := cm.classifier.(type) {
+	case *HeuristicClassifier:
+		heuristic = c
+	case *LLMClassifier:
+		heuristic = c.heuristic
+	default:
+		return nil
+	}
+
+	best, confidence := heuristic.heuristicClassify(content)
+	if cm.classifiesExchange(opts) {
+		best, confidence = heuristic.exchangeClassify(opts.UserMessage, opts.AssistantMessage, cm.config.ExchangeUserWeight)
+	}
+	if best != sector {
+		return nil
+	}
+	return &confidence
+}
+
+// entityWeights returns the association weight for each entity: 0.5, or
+// Config.MutualEntityWeight for entities named in both halves of the exchange.
+func (cm *Engram) entityWeights(entities []Entity, opts AddOptions) []float64 {
+	user, assistant := strings.ToLower(opts.UserMessage), strings.ToLower(opts.AssistantMessage)
+	mutual := cm.config.MutualEntityWeight > 0 && user != "" && assistant != ""
+
+	weights := make([]float64, len(entities))
+	for i, e := range entities {
+		weights[i] = 0.5
+		text := strings.ToLower(e.Text)
+		if mutual && strings.Contains(user, text) && strings.Contains(assistant, text) {
+			weights[i] = cm.config.MutualEntityWeight
+		}
+	}
+	return weights
+}
+
+// recordSessionMarker stores an episodic marker if sessionID is new for the
+// user and they have earlier memories. Called with cm.mu held, before the
+// session's first memory is inserted.
+func (cm *Engram) recordSessionMarker(userID, sessionID string) {
+	last, seen, err := cm.store.GetLastActivity(userID, sessionID)
+	if err != nil {
+		log.Printf("[engram] Session marker lookup failed: %v", err)
+		return
+	}
+	if seen || last.IsZero() {
+		return
+	}
+	gap := cm.config.Clock.Now().Sub(last)
+	content := fmt.Sprintf("Session started after %s away", formatGap(gap))
+	_, err = cm.store.InsertMemory(Memory{
+		Content:   content,
+		Sector:    SectorEpisodic,
+		Salience:  0.3,
+		UserID:    userID,
+		Summary:   content,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		log.Printf("[engram] Session marker insert failed: %v", err)
+	}
+}
+
+// formatGap renders a duration coarsely for marker text: "14 days",
+// "3 hours", "20 minutes".
+func formatGap(d time.Duration) string {
+	unit, n := "minute", int(d/time.Minute)
+	switch {
+	case d >= 48*time.Hour:
+		unit, n = "day", int(d/(24*time.Hour))
+	case d >= 2*time.Hour:
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
 // SearchWithOptions retrieves memories with temporal and session filters.
 func (cm *Engram) SearchWithOptions(opts SearchOptions) []SearchResult {
 	if opts.UserID == "" {
 		return nil
 	}
+	if err := cm.enter(); err != nil {
+		log.Printf("[engram] Search after close for %s", opts.UserID)
+		return nil
+	}
+	defer cm.leave()
+	return cm.search(context.Background(), opts)
+}
+
+// search is SearchWithOptions for a registered call, embedding under ctx.
+func (cm *Engram) search(ctx context.Context, opts SearchOptions) []SearchResult {
 	if opts.Limit <= 0 {
 		opts.Limit = 5
 	}
+	if opts.Weights == nil {
+		if uc, err := cm.GetUserConfig(opts.UserID); err != nil {
+			log.Printf("[engram] Load user config failed: %v", err)
+		} else if uc != nil {
+			opts.Weights = uc.SectorWeights
+		}
+	}
 	if opts.Weights == nil {
 		opts.Weights = DefaultSectorWeights()
 	}
+	if opts.QuerySectorAffinity > 0 && opts.Query != "" {
+		if sector, confidence := queryClassifier.heuristicClassify(opts.Query); confidence > 0 {
+			opts.querySector = sector
+		}
+	}
 
-	if cm.embedder == nil {
-		log.Printf("[engram] No embedding provider configured")
-		return nil
+	// 0. An empty query has nothing to embed; by default fall back to the
+	// user's most salient memories instead
+	defaultContext := false
+	if opts.QueryVector == nil && strings.TrimSpace(opts.Query) == "" {
+		switch cm.config.OnEmptyQuery {
+		case EmptyQueryNone:
+			return nil
+		case EmptyQueryContext:
+			defaultContext = true
+		}
 	}
-	queryVec, err := cm.embedder.Embed(context.Background(), opts.Query, "RETRIEVAL_QUERY")
-	if err != nil {
-		log.Printf("[engram] Embed query failed: %v", err)
-		return nil
+
+	// 1. Embed the query (unless the caller supplied one). With DegradedFallback,
+	// an unavailable embedder switches to lexical scoring instead of failing.
+	queryVec := opts.QueryVector
+	lexical := false
+	if queryVec != nil {
+		if err := cm.checkDimension(queryVec); err != nil {
+			log.Printf("[engram] Invalid query vector: %v", err)
+			return nil
+		}
+	} else if !defaultContext {
+		var err error
+		if cm.embedder == nil {
+			err = fmt.Errorf("no embedding provider configured")
+		} else {
+			queryVec, err = cm.embed(ctx, opts.Query, cm.config.QueryTaskType)
+		}
+		if err != nil {
+			if !cm.config.DegradedFallback {
+				log.Printf("[engram] Embed query failed: %v", err)
+				return nil
+			}
+			log.Printf("[engram] Embed query failed, falling back to lexical search: %v", err)
+			lexical = true
+		}
 	}
 
-	candidates, err := cm.store.GetMemoriesWithVectors(opts.UserID)
+	// 1b. Embed paraphrases; a memory matching any of them counts as matching
+	var expansionVecs [][]float32
+	if !lexical && !defaultContext && cm.embedder != nil {
+		for _, q := range opts.QueryExpansions {
+			vec, err := cm.embed(ctx, q, cm.config.QueryTaskType)
+			if err != nil {
+				log.Printf("[engram] Embed query expansion failed, skipping: %v", err)
+				continue
+			}
+			expansionVecs = append(expansionVecs, vec)
+		}
+	}
+
+	// 2. Load memories + vectors for this user (all, unless capped)
+	var candidates []memoryWithVector
+	var err error
+	if cm.vectors.warmed(opts.UserID) {
+		candidates, err = cm.store.GetCandidateMemories(opts.UserID, cm.config.SearchCandidateCap)
+		if err == nil {
+			err = cm.vectors.fill(cm.store, opts.UserID, candidates)
+		}
+	} else {
+		candidates, err = cm.store.GetCandidateMemoriesWithVectors(opts.UserID, cm.config.SearchCandidateCap)
+	}
 	if err != nil {
 		log.Printf("[engram] Load memories failed: %v", err)
 		return nil
 	}
 
-	// Apply temporal and sector filters
+	// 2b. A changed embedding model leaves the query unable to match stored vectors
+	if !lexical && !defaultContext && !cm.checkStoredDimension(opts.UserID, len(queryVec), candidates) {
+		return nil
+	}
+
+	excluded := make(map[int64]bool, len(opts.ExcludeIDs))
+	for _, id := range opts.ExcludeIDs {
+		excluded[id] = true
+	}
+
+	// Apply exclusion, temporal and sector filters. Excluded memories never
+	// reach scoring, so the high-salience guarantee can't reinject them either.
 	var filtered []memoryWithVector
 	for _, c := range candidates {
+		if excluded[c.ID] {
+			continue
+		}
 		if opts.After != nil && c.CreatedAt.Before(*opts.After) {
 			continue
 		}
 		if opts.Before != nil && c.CreatedAt.After(*opts.Before) {
 			continue
 		}
+		if opts.ExcludeSince != nil && !c.CreatedAt.Before(*opts.ExcludeSince) {
+			continue
+		}
 		if opts.SessionID != "" && c.SessionID != opts.SessionID {
 			continue
 		}
+		if c.Confidence < opts.MinConfidence {
+			continue
+		}
 		if len(opts.Sectors) > 0 {
 			match := false
 			for _, s := range opts.Sectors {
@@ -340,108 +643,640 @@ func (cm *Engram) SearchWithOptions(opts SearchOptions) []SearchResult {
 	if len(filtered) == 0 {
 		return nil
 	}
+	if defaultContext {
+		return cm.expandContext(defaultContextResults(filtered, opts.Limit), opts.ExpandContext)
+	}
 
+	// 3. Compute similarity for each candidate (stored vectors' norms are
+	// cached, so only the query's are computed)
+	queryNorm := vectorNorm(queryVec)
+	expansionNorms := make([]float64, len(expansionVecs))
+	for i, ev := range expansionVecs {
+		expansionNorms[i] = vectorNorm(ev)
+	}
 	var scoredCandidates []scored
 	for _, c := range filtered {
+		if lexical {
+			sim := LexicalSimilarity(opts.Query, c.Content)
+			for _, q := range opts.QueryExpansions {
+				sim = math.Max(sim, LexicalSimilarity(q, c.Content))
+			}
+			scoredCandidates = append(scoredCandidates, scored{memoryWithVector: c, similarity: sim})
+			continue
+		}
 		if c.Vector == nil {
 			continue
 		}
-		sim := CosineSimilarity(queryVec, c.Vector)
-		scoredCandidates = append(scoredCandidates, scored{c, sim})
+		sim := similarityWithNorms(cm.config.SimilarityMetric, queryVec, queryNorm, c.Vector, c.Norm)
+		for i, ev := range expansionVecs {
+			sim = math.Max(sim, similarityWithNorms(cm.config.SimilarityMetric, ev, expansionNorms[i], c.Vector, c.Norm))
+		}
+		scoredCandidates = append(scoredCandidates, scored{memoryWithVector: c, similarity: sim})
 	}
 
+	// 3b. Memories linked to entities the query names get a similarity boost,
+	// before seeds are chosen so they also anchor waypoint expansion
+	if cm.config.QueryEntityBoost > 0 && opts.Query != "" {
+		cm.applyQueryEntityBoost(scoredCandidates, opts)
+	}
+
+	// Sort by similarity, take top candidates for waypoint expansion
 	sort.Slice(scoredCandidates, func(i, j int) bool {
 		return scoredCandidates[i].similarity > scoredCandidates[j].similarity
 	})
 
+	// Cap candidates for expansion (top 20 by similarity)
 	expandLimit := 20
 	if len(scoredCandidates) < expandLimit {
 		expandLimit = len(scoredCandidates)
 	}
 	topCandidates := scoredCandidates[:expandLimit]
 
+	// 4. Expand via waypoint graph (one-hop)
 	seedMWVs := make([]memoryWithVector, len(topCandidates))
 	for i, sc := range topCandidates {
 		seedMWVs[i] = sc.memoryWithVector
 	}
-	linkWeights := ExpandViaWaypoints(cm.store, seedMWVs, opts.UserID)
+	linkWeights := ExpandViaWaypointsWithWeight(cm.store, seedMWVs, opts.UserID, cm.config.LinkHopWeight)
+	if len(cm.config.ExpansionSectors) > 0 {
+		for _, sc := range scoredCandidates {
+			if !containsSector(cm.config.ExpansionSectors, sc.Sector) {
+				delete(linkWeights, sc.ID)
+			}
+		}
+	}
 
-	sw := cm.config.scoringWeights
+	// 4b. Entity-type boosts: memories linked to boosted entity types
+	if len(cm.config.EntityTypeRecallBoost) > 0 {
+		cm.applyEntityTypeBoosts(scoredCandidates)
+	}
 
+	// 5. Compute composite scores with personality weights
 	var results []SearchResult
 	for _, sc := range scoredCandidates {
-		sectorWeight := opts.Weights[sc.Sector]
-		if sectorWeight == 0 {
-			sectorWeight = 1.0
-		}
-		lw := linkWeights[sc.ID]
-		days := DaysSince(sc.LastAccessedAt)
-		composite := CompositeScore(sc.similarity, sc.DecayScore, days, lw, sectorWeight, sw)
 		results = append(results, SearchResult{
 			Memory:         sc.Memory,
-			CompositeScore: composite,
+			CompositeScore: cm.compositeFor(sc, linkWeights, opts),
 			Similarity:     sc.similarity,
 		})
 	}
 
+	// 6. Sort by composite score, take top-k
 	sort.Slice(results, func(i, j int) bool {
 		return results[i].CompositeScore > results[j].CompositeScore
 	})
+	breakTies(results, opts.TieBreaker, opts.TieEpsilon)
+	var ranked []SearchResult // full ranking, kept for sector balancing
+	if opts.BalanceAcrossSectors && len(opts.Sectors) > 1 {
+		ranked = append(ranked, results...)
+	}
 	if len(results) > opts.Limit {
 		results = results[:opts.Limit]
 	}
 
-	results = cm.guaranteeHighSalience(results, scoredCandidates, opts.Weights, linkWeights, opts.Limit)
+	// 6b. High-salience guarantee
+	results = cm.guaranteeHighSalience(results, scoredCandidates, linkWeights, opts)
+
+	// 6c. Sector balance, last so injected memories can't crowd a sector out
+	if ranked != nil {
+		results = balanceSectors(results, ranked, opts.Sectors, opts.Limit)
+	}
 
+	// 7. Reinforce accessed memories (not the context bundled with them)
 	for _, r := range results {
-		cm.store.ReinforceSalience(r.ID, 0.15)
+		if err := cm.store.ReinforceSalienceWithCooldown(r.ID, 0.15, cm.config.ReinforceCooldown); err != nil {
+			log.Printf("[engram] Reinforce failed for memory %d: %v", r.ID, err)
+		}
 	}
 
+	// 8. Bundle session neighbors
+	return cm.expandContext(results, opts.ExpandContext)
+}
+
+// expandContext sets each result's Context to its session neighborhood of up
+// to n turns either side. Results without a session are left alone.
+func (cm *Engram) expandContext(results []SearchResult, n int) []SearchResult {
+	if n <= 0 {
+		return results
+	}
+	for i := range results {
+		if results[i].SessionID == "" {
+			continue
+		}
+		neighbors, err := cm.store.GetSessionNeighbors(results[i].ID, n)
+		if err != nil {
+			log.Printf("[engram] Load context for memory %d failed: %v", results[i].ID, err)
+			continue
+		}
+		results[i].Context = neighbors
+	}
+	return results
+}
+
+// defaultContextResults answers an empty query with the limit most salient
+// candidates, newer first among equals. CompositeScore is the salience. There
+// is no query to match, so nothing is reinforced: otherwise repeated empty
+// queries would keep boosting the same memories.
+func defaultContextResults(candidates []memoryWithVector, limit int) []SearchResult {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Salience != candidates[j].Salience {
+			return candidates[i].Salience > candidates[j].Salience
+		}
+		return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	results := make([]SearchResult, len(candidates))
+	for i, c := range candidates {
+		results[i] = SearchResult{Memory: c.Memory, CompositeScore: c.Salience}
+	}
 	return results
 }
 
+// SimilaritySearch returns the user's limit memories most similar to query,
+// ordered by similarity alone (cosine unless Config.SimilarityMetric says
+// otherwise). Unlike Search there is no composite scoring, waypoint expansion,
+// high-salience injection, or reinforcement, so it suits analytics ("everything
+// about Tokyo") without changing what the character remembers. CompositeScore
+// equals Similarity.
+func (cm *Engram) SimilaritySearch(ctx context.Context, userID, query string, limit int) ([]SearchResult, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if userID == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+	if cm.embedder == nil {
+		return nil, fmt.Errorf("engram: similarity search: no embedding provider configured")
+	}
+	queryVec, err := cm.embed(ctx, query, cm.config.QueryTaskType)
+	if err != nil {
+		return nil, fmt.Errorf("engram: similarity search: embed query: %w", err)
+	}
+
+	candidates, err := cm.store.GetMemoriesWithVectors(userID)
+	if err != nil {
+		return nil, fmt.Errorf("engram: similarity search: load memories: %w", err)
+	}
+	queryNorm := vectorNorm(queryVec)
+	var results []SearchResult
+	for _, c := range candidates {
+		if c.Vector == nil {
+			continue
+		}
+		sim := similarityWithNorms(cm.config.SimilarityMetric, queryVec, queryNorm, c.Vector, c.Norm)
+		results = append(results, SearchResult{Memory: c.Memory, CompositeScore: sim, Similarity: sim})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Similarity > results[j].Similarity
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// balanceSectors picks limit results from top (the current selection, best
+// first) followed by the rest of ranked, first reserving a slot for the best
+// result of each sector (in sectors order, as limit allows), then filling in
+// that order. The picks keep that order.
+func balanceSectors(top, ranked []SearchResult, sectors []Sector, limit int) []SearchResult {
+	inTop := make(map[int64]bool, len(top))
+	for _, r := range top {
+		inTop[r.ID] = true
+	}
+	pool := append([]SearchResult(nil), top...)
+	for _, r := range ranked {
+		if !inTop[r.ID] {
+			pool = append(pool, r)
+		}
+	}
+	if len(pool) <= limit {
+		return pool
+	}
+
+	chosen := make(map[int]bool, limit)
+	for _, sector := range sectors {
+		if len(chosen) == limit {
+			break
+		}
+		for i, r := range pool {
+			if r.Sector == sector {
+				chosen[i] = true
+				break
+			}
+		}
+	}
+	for i := 0; i < len(pool) && len(chosen) < limit; i++ {
+		chosen[i] = true
+	}
+
+	balanced := make([]SearchResult, 0, limit)
+	for i, r := range pool {
+		if chosen[i] {
+			balanced = append(balanced, r)
+		}
+	}
+	return balanced
+}
+
+// breakTies reorders score-sorted results within relevance bands: runs whose
+// scores lie within epsilon of the run's top score are sorted by the
+// tie-breaker's key instead.
+func breakTies(results []SearchResult, tb TieBreaker, epsilon float64) {
+	var less func(a, b SearchResult) bool
+	switch tb {
+	case TieBreakRecency:
+		less = func(a, b SearchResult) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case TieBreakSalience:
+		less = func(a, b SearchResult) bool { return a.Salience > b.Salience }
+	default:
+		return
+	}
+	if epsilon <= 0 {
+		epsilon = 0.02
+	}
+	for start := 0; start < len(results); {
+		end := start + 1
+		for end < len(results) && results[start].CompositeScore-results[end].CompositeScore <= epsilon {
+			end++
+		}
+		band := results[start:end]
+		sort.SliceStable(band, func(i, j int) bool { return less(band[i], band[j]) })
+		start = end
+	}
+}
+
+// compositeFor computes a candidate's composite score under the given search
+// options: personality sector weight, waypoint link weight, and reflective bias.
+func (cm *Engram) compositeFor(sc scored, linkWeights map[int64]float64, opts SearchOptions) float64 {
+	sectorWeight := opts.Weights[sc.Sector]
+	if sectorWeight == 0 {
+		sectorWeight = 1.0
+	}
+	linkWeight := linkWeights[sc.ID] // 0 if not linked
+	days := DaysBetween(sc.LastAccessedAt, cm.config.Clock.Now())
+	decay := sc.DecayScore
+	if cm.config.DecayOnRead {
+		c := cm.config
+		decay = decayedScore(sc.Memory, days, c.MinDecayScore, c.decayRates, c.SectorMinDecayFloor, c.EmotionalArousalDecay)
+	}
+	similarity := sc.similarity
+	if transform := cm.config.SimilarityTransform; transform != nil {
+		similarity = transform(similarity)
+	}
+	composite := CompositeScore(similarity, decay, days, linkWeight, sectorWeight, cm.config.scoringWeights)
+
+	if sc.Sector == SectorReflective && opts.ReflectiveBias != 0 {
+		composite *= math.Max(0, 1+opts.ReflectiveBias)
+	}
+	if sc.entityBoost > 0 {
+		composite *= sc.entityBoost
+	}
+	if opts.querySector != "" && sc.Sector == opts.querySector {
+		composite *= 1 + opts.QuerySectorAffinity
+	}
+	if opts.WeightByConfidence {
+		composite *= sc.Confidence
+	}
+	return composite
+}
+
+// applyQueryEntityBoost adds Config.QueryEntityBoost to the similarity of
+// candidates linked to an entity extracted from the query.
+func (cm *Engram) applyQueryEntityBoost(candidates []scored, opts SearchOptions) {
+	entities := cm.extractor.Extract(opts.Query)
+	if len(entities) == 0 {
+		return
+	}
+	texts := make([]string, len(entities))
+	for i, e := range entities {
+		texts[i] = e.Text
+	}
+	linked, err := cm.store.GetMemoryIDsByEntities(opts.UserID, texts)
+	if err != nil {
+		log.Printf("[engram] Load query entity links failed: %v", err)
+		return
+	}
+	for i := range candidates {
+		if linked[candidates[i].ID] {
+			candidates[i].similarity = math.Min(1, candidates[i].similarity+cm.config.QueryEntityBoost)
+		}
+	}
+}
+
+// applyEntityTypeBoosts sets each candidate's entityBoost to the largest
+// Config.EntityTypeRecallBoost multiplier among its linked entity types.
+func (cm *Engram) applyEntityTypeBoosts(candidates []scored) {
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	types, err := cm.store.GetMemoryEntityTypes(ids)
+	if err != nil {
+		log.Printf("[engram] Load entity types failed: %v", err)
+		return
+	}
+	for i := range candidates {
+		for _, t := range types[candidates[i].ID] {
+			if b, ok := cm.config.EntityTypeRecallBoost[t]; ok && b > candidates[i].entityBoost {
+				candidates[i].entityBoost = b
+			}
+		}
+	}
+}
+
+// embed embeds text, normalized by Config.ContentNormalizer, with the
+// configured embedder.
+func (cm *Engram) embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	return cm.embedder.Embed(ctx, cm.config.ContentNormalizer(text), taskType)
+}
+
+// NormalizeWhitespace trims text and collapses each run of whitespace to a
+// single space. It is the default Config.ContentNormalizer.
+func NormalizeWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// checkDimension validates a caller-supplied vector against the configured
+// embedder's dimension. A nil vector, or no embedder to compare against, passes.
+func (cm *Engram) checkDimension(vec []float32) error {
+	if vec == nil || cm.embedder == nil {
+		return nil
+	}
+	if dim := cm.embedder.Dimension(); dim > 0 && len(vec) != dim {
+		return fmt.Errorf("engram: vector dimension %d does not match embedder dimension %d", len(vec), dim)
+	}
+	return nil
+}
+
 // GetSession returns all memories from a specific session, in chronological order.
 func (cm *Engram) GetSession(sessionID string) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
 	return cm.store.GetSessionMemories(sessionID)
 }
 
 // GetLastSession returns all memories from the user's most recent session.
 func (cm *Engram) GetLastSession(userID string) ([]Memory, error) {
-	sessionID, err := cm.store.GetLastSessionID(userID)
+	return cm.GetLastSessionWithin(userID, 0)
+}
+
+// GetLastSessionWithin is GetLastSession, but a session with no memory in the
+// last maxAge counts as no recent session (nil), so a character greets the
+// user fresh instead of resuming a months-old conversation. 0 = no limit.
+func (cm *Engram) GetLastSessionWithin(userID string, maxAge time.Duration) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	sessionID, err := cm.store.GetLastSessionIDSince(userID, cm.sessionCutoff(maxAge))
 	if err != nil || sessionID == "" {
 		return nil, err
 	}
 	return cm.store.GetSessionMemories(sessionID)
 }
 
+// sessionCutoff converts a last-session max age into the oldest acceptable
+// activity time (zero = any age).
+func (cm *Engram) sessionCutoff(maxAge time.Duration) time.Time {
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return cm.config.Clock.Now().Add(-maxAge)
+}
+
+// GetThread returns the conversation chain ending at memoryID, following
+// ParentID links back to the root, oldest first. Archived memories are skipped
+// but the chain continues through them.
+func (cm *Engram) GetThread(memoryID int64) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	return cm.store.GetThread(memoryID)
+}
+
+// GetChildren returns the direct replies to memoryID, oldest first. With
+// GetThread it reconstructs branching conversation trees.
+func (cm *Engram) GetChildren(memoryID int64) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if memoryID == 0 {
+		return nil, nil // 0 means "no parent", not a memory
+	}
+	return cm.store.GetChildren(memoryID)
+}
+
+// SessionTranscript formats a session as a readable, chronological transcript:
+//
+//	[2025-01-02 15:04:05] User: ...
+//	[2025-01-02 15:04:05] Assistant: ...
+//
+// Memories stored before structured storage have no separate halves and are
+// written as a single line of their joined content.
+func (cm *Engram) SessionTranscript(sessionID string) (string, error) {
+	if err := cm.enter(); err != nil {
+		return "", err
+	}
+	defer cm.leave()
+	memories, err := cm.store.GetSessionMemories(sessionID)
+	if err != nil {
+		return "", fmt.Errorf("engram: load session: %w", err)
+	}
+	if len(memories) == 0 {
+		return "", fmt.Errorf("engram: session %q not found", sessionID)
+	}
+
+	var b strings.Builder
+	for _, m := range memories {
+		ts := m.CreatedAt.Format("2006-01-02 15:04:05")
+		if m.UserMessage == "" && m.AssistantMessage == "" {
+			fmt.Fprintf(&b, "[%s] %s\n", ts, m.Content)
+			continue
+		}
+		if m.UserMessage != "" {
+			fmt.Fprintf(&b, "[%s] User: %s\n", ts, m.UserMessage)
+		}
+		if m.AssistantMessage != "" {
+			fmt.Fprintf(&b, "[%s] Assistant: %s\n", ts, m.AssistantMessage)
+		}
+	}
+	return b.String(), nil
+}
+
+// ClearUser deletes all of a user's memories (a character reset), including
+// archived ones, their vectors and associations, and any waypoints no longer
+// referenced. Other users are untouched. Returns the number of memories removed.
+func (cm *Engram) ClearUser(userID string) (int, error) {
+	if err := cm.enter(); err != nil {
+		return 0, err
+	}
+	defer cm.leave()
+	if userID == "" {
+		return 0, fmt.Errorf("engram: clear user: empty user ID")
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	n, err := cm.store.DeleteUserMemories(userID)
+	if err != nil {
+		return 0, fmt.Errorf("engram: clear user: %w", err)
+	}
+	cm.vectors.drop(userID)
+	log.Printf("[engram] Cleared %d memories for %s", n, userID)
+	return n, nil
+}
+
+// ActiveUsers returns the sorted IDs of every user with stored memories
+// (archived ones included), for maintenance jobs that walk all users: reflect
+// all, rebuild waypoints, export. The whole list is loaded at once; with
+// millions of users, process it in slices and expect users added meanwhile to
+// be picked up by the next run.
+func (cm *Engram) ActiveUsers() ([]string, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	ids, err := cm.store.GetActiveUserIDs()
+	if err != nil {
+		return nil, fmt.Errorf("engram: active users: %w", err)
+	}
+	return ids, nil
+}
+
+// EffectiveConfig returns the configuration in effect after defaults were
+// applied, e.g. to check that a decay rate override was merged as intended.
+func (cm *Engram) EffectiveConfig() ResolvedConfig {
+	c := cm.config
+	rc := ResolvedConfig{
+		DBPath:                c.DBPath,
+		MaxMemoriesPerUser:    c.MaxMemoriesPerUser,
+		MaxAddsPerMinute:      c.MaxAddsPerMinute,
+		MaxAsyncAdds:          c.MaxAsyncAdds,
+		MaxEntitiesPerMemory:  c.MaxEntitiesPerMemory,
+		SearchCandidateCap:    c.SearchCandidateCap,
+		EmbedDimension:        c.EmbedDimension,
+		ScoringWeights:        c.scoringWeights,
+		SimilarityMetric:      c.SimilarityMetric,
+		LinkHopWeight:         c.LinkHopWeight,
+		QueryTaskType:         c.QueryTaskType,
+		DecayInterval:         c.DecayInterval,
+		DecayRates:            maps.Clone(c.decayRates),
+		SectorMinDecayFloor:   maps.Clone(c.SectorMinDecayFloor),
+		MinDecayScore:         c.MinDecayScore,
+		ReflectionInterval:    c.ReflectionInterval,
+		ReflectEveryNMemories: c.ReflectEveryNMemories,
+		ReflectionConcurrency: c.ReflectionConcurrency,
+		ParentOnDelete:        c.ParentOnDelete,
+		OnDimensionMismatch:   c.OnDimensionMismatch,
+		HighSalienceInjection: c.HighSalienceInjection,
+		OnEmptyQuery:          c.OnEmptyQuery,
+	}
+	if cm.embedder != nil {
+		if dim := cm.embedder.Dimension(); dim > 0 {
+			rc.EmbedDimension = dim
+		}
+	}
+	return rc
+}
+
 // ListRecent returns the N most recent memories for a user, optionally filtered by sector.
 // Intended for inspection and debugging tools (e.g., MCP inspect).
 func (cm *Engram) ListRecent(userID string, limit int, sectors []Sector) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
 	return cm.store.GetRecentMemories(userID, limit, sectors)
 }
 
+// Backup snapshots the whole database to destPath while the engine stays
+// live, for hot backups. Open the copy with Init to restore it.
+func (cm *Engram) Backup(destPath string) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	return cm.store.Backup(destPath)
+}
+
 // Close shuts down workers and closes the database.
+// Cancellation aborts any in-progress reflection; Close waits for the
+// workers and in-flight calls to exit before closing the store. Later calls
+// return ErrClosed (Search returns no results); closing twice is a no-op.
 func (cm *Engram) Close() error {
+	cm.lifecycle.Lock()
+	if !cm.closed.CompareAndSwap(false, true) {
+		cm.lifecycle.Unlock()
+		return nil
+	}
+	cm.lifecycle.Unlock()
+
 	if cm.cancelDecay != nil {
 		cm.cancelDecay()
 	}
 	if cm.cancelReflect != nil {
 		cm.cancelReflect()
 	}
+	// No call or worker can start once closed is set, so both counts only fall
+	cm.calls.Wait()
+	cm.workers.Wait()
 	if lc, ok := cm.classifier.(*LLMClassifier); ok {
 		lc.Close()
 	}
 	return cm.store.Close()
 }
 
+// enter registers a public call so Close waits for it, or returns ErrClosed
+// once Close has been called. Pair a nil return with defer cm.leave().
+func (cm *Engram) enter() error {
+	cm.lifecycle.Lock()
+	defer cm.lifecycle.Unlock()
+	if cm.closed.Load() {
+		return ErrClosed
+	}
+	cm.calls.Add(1)
+	return nil
+}
+
+// leave ends a call registered by enter.
+func (cm *Engram) leave() {
+	cm.calls.Done()
+}
+
+// goWorker runs fn in a background goroutine that Close waits for, unless
+// Close has already been called. Reports whether fn was started.
+func (cm *Engram) goWorker(fn func()) bool {
+	cm.lifecycle.Lock()
+	defer cm.lifecycle.Unlock()
+	if cm.closed.Load() {
+		return false
+	}
+	cm.workers.Add(1)
+	go func() {
+		defer cm.workers.Done()
+		fn()
+	}()
+	return true
+}
+
 // guaranteeHighSalience ensures the user's highest-salience memories appear in
 // results even if their semantic similarity to the current query is low.
-func (cm *Engram) guaranteeHighSalience(results []SearchResult, allScored []scored, weights SectorWeights, linkWeights map[int64]float64, limit int) []SearchResult {
+func (cm *Engram) guaranteeHighSalience(results []SearchResult, allScored []scored, linkWeights map[int64]float64, opts SearchOptions) []SearchResult {
 	const salienceThreshold = 0.6
 	const maxBoosts = 2
 
-	sw := cm.config.scoringWeights
-
 	// Collect IDs already in results
 	inResults := make(map[int64]bool)
 	for _, r := range results {
@@ -454,17 +1289,15 @@ func (cm *Engram) guaranteeHighSalience(results []SearchResult, allScored []scor
 		if inResults[sc.ID] || sc.Salience < salienceThreshold {
 			continue
 		}
-		sectorWeight := weights[sc.Sector]
-		if sectorWeight == 0 {
-			sectorWeight = 1.0
+		// A negative reflective bias opts reflections out of the guarantee
+		if sc.Sector == SectorReflective && opts.ReflectiveBias < 0 {
+			continue
 		}
-		lw := linkWeights[sc.ID]
-		days := DaysSince(sc.LastAccessedAt)
-		composite := CompositeScore(sc.similarity, sc.DecayScore, days, lw, sectorWeight, sw)
 		candidates = append(candidates, SearchResult{
 			Memory:         sc.Memory,
-			CompositeScore: composite,
+			CompositeScore: cm.compositeFor(sc, linkWeights, opts),
 			Similarity:     sc.similarity,
+			Injected:       true,
 		})
 	}
 
@@ -477,19 +1310,20 @@ func (cm *Engram) guaranteeHighSalience(results []SearchResult, allScored []scor
 		return candidates[i].Salience > candidates[j].Salience
 	})
 
-	// Inject top high-salience candidates, replacing the lowest-scored results
-	injected := 0
-	for _, c := range candidates {
-		if injected >= maxBoosts {
-			break
-		}
-		if len(results) >= limit {
-			results[len(results)-1] = c
+	// Inject the top high-salience candidates. Under InjectReplaceLowest they
+	// displace the lowest-scored ranked results (never each other) so the
+	// result count stays within Limit. The top-ranked result is always kept
+	// when Limit leaves room for it.
+	n := min(maxBoosts, len(candidates))
+	if cm.config.HighSalienceInjection == InjectReplaceLowest {
+		if opts.Limit > 1 {
+			n = min(n, opts.Limit-1)
 		} else {
-			results = append(results, c)
+			n = min(n, opts.Limit)
 		}
-		injected++
+		results = results[:min(len(results), opts.Limit-n)]
 	}
+	results = append(results, candidates[:n]...)
 
 	return results
 }
@@ -506,6 +1340,17 @@ func buildSummary(userMessage, assistantMessage string, maxLen int) string {
 	return userPart + " | " + npcPart
 }
 
+// mediaCaptions joins the non-empty captions of refs into searchable text.
+func mediaCaptions(refs []MediaRef) string {
+	var parts []string
+	for _, r := range refs {
+		if r.Caption != "" {
+			parts = append(parts, r.Caption)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
 // truncateSummary returns the first n characters of s, breaking at a word boundary.
 func truncateSummary(s string, n int) string {
 	if len(s) <= n {
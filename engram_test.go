@@ -0,0 +1,1416 @@
+package engram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// testEngramConfig initializes an Engram from cfg with a temp DB and the decay
+// worker effectively disabled unless the test sets DecayInterval.
+func testEngramConfig(t *testing.T, cfg Config) *Engram {
+	t.Helper()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	if cfg.DecayInterval == 0 {
+		cfg.DecayInterval = 999999 * 1e9
+	}
+	cm, err := Init(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cm.Close() })
+	return cm
+}
+
+// countingEmbedder implements EmbeddingProvider and records how often it is called.
+type countingEmbedder struct {
+	vec   []float32
+	calls atomic.Int32
+}
+
+func (c *countingEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	c.calls.Add(1)
+	return c.vec, nil
+}
+
+func (c *countingEmbedder) Dimension() int { return len(c.vec) }
+
+func TestAddAndSearchWithSuppliedVectors(t *testing.T) {
+	embed := &countingEmbedder{vec: []float32{0, 0, 1}}
+	cm := testEngram(t, nil, embed)
+
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID:           "u1",
+		UserMessage:      "I play the cello",
+		AssistantMessage: "Lovely instrument",
+		Vector:           []float32{1, 0, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := cm.SearchWithOptions(SearchOptions{
+		UserID:      "u1",
+		QueryVector: []float32{1, 0, 0},
+	})
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("expected memory %d in results, got %v", id, results)
+	}
+	if results[0].Similarity < 0.99 {
+		t.Errorf("expected similarity ~1.0 from supplied vectors, got %.3f", results[0].Similarity)
+	}
+	if n := embed.calls.Load(); n != 0 {
+		t.Errorf("expected no embedder calls, got %d", n)
+	}
+}
+
+func TestAddRejectsWrongDimensionVector(t *testing.T) {
+	embed := &countingEmbedder{vec: []float32{0, 0, 1}}
+	cm := testEngram(t, nil, embed)
+
+	_, err := cm.AddWithOptions(AddOptions{
+		UserID:      "u1",
+		UserMessage: "hi",
+		Vector:      []float32{1, 0},
+	})
+	if err == nil {
+		t.Error("expected dimension mismatch error")
+	}
+}
+
+func TestSearchReflectiveBiasSuppressesReflections(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	reflID, _ := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "They drink more when work is stressful", SectorHint: SectorReflective,
+		Salience: 0.8, Vector: []float32{1, 0, 0},
+	})
+	cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "Good to see you", SectorHint: SectorEmotional,
+		Vector: []float32{0.8, 0.6, 0},
+	})
+	cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "Ordered a whiskey", SectorHint: SectorEpisodic,
+		Vector: []float32{0.6, 0.8, 0},
+	})
+
+	contains := func(results []SearchResult, id int64) bool {
+		for _, r := range results {
+			if r.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	neutral := cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 2, QueryVector: []float32{1, 0, 0}})
+	if !contains(neutral, reflID) {
+		t.Fatalf("expected reflection %d in neutral top-2, got %v", reflID, neutral)
+	}
+
+	biased := cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 2, QueryVector: []float32{1, 0, 0}, ReflectiveBias: -1})
+	if contains(biased, reflID) {
+		t.Errorf("expected reflection %d suppressed with negative bias, got %v", reflID, biased)
+	}
+	if len(biased) != 2 {
+		t.Errorf("expected 2 results, got %d", len(biased))
+	}
+}
+
+func TestAddCapsExtractedEntitiesKeepingKnown(t *testing.T) {
+	cm := testEngramConfig(t, Config{
+		MaxEntitiesPerMemory: 4,
+		EntityExtractor: &DefaultEntityExtractor{
+			KnownEntities: []KnownEntity{
+				{Text: "Aphex Twin", Type: "music_artist"},
+				{Text: "Boards of Canada", Type: "music_artist"},
+			},
+		},
+	})
+
+	// Brackets, quotes, proper nouns, and known entities: a dozen candidates
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID:           "u1",
+		UserMessage:      `[Alex]: I played "Windowlicker", "Roygbiv", "Xtal", "Olson" and "Dayvan Cowboy" at Harajuku Station near Tokyo Tower`,
+		AssistantMessage: `[Lily]: aphex twin and boards of canada at Club Mutant with Detroit Techno fans`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wpIDs, err := cm.store.GetAssociatedWaypointIDs(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wpIDs) != 4 {
+		t.Fatalf("expected 4 associations after cap, got %d", len(wpIDs))
+	}
+
+	linked := make(map[string]bool)
+	for _, wpID := range wpIDs {
+		var text string
+		cm.store.db.QueryRow(`SELECT entity_text FROM waypoints WHERE id = ?`, wpID).Scan(&text)
+		linked[text] = true
+	}
+	for _, want := range []string{"Aphex Twin", "Boards of Canada", "Alex", "Lily"} {
+		if !linked[want] {
+			t.Errorf("expected %q to survive the cap, got %v", want, linked)
+		}
+	}
+}
+
+// failingEmbedder implements EmbeddingProvider and always fails.
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	return nil, errors.New("embedding service unavailable")
+}
+
+func (failingEmbedder) Dimension() int { return 3 }
+
+func TestSearchDegradedFallbackWhenEmbedderFails(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: failingEmbedder{}, DegradedFallback: true})
+
+	cm.store.InsertMemory(Memory{Content: "They collect old jazz records | Nice", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "jazz"})
+	cm.store.InsertMemory(Memory{Content: "Weather was rainy | Stay dry", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "rain"})
+	cm.store.InsertMemory(Memory{Content: "Ordered a whiskey | Coming up", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "whiskey"})
+
+	results := cm.Search("any good jazz records lately?", "u1", 1, nil)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result from lexical fallback, got %d", len(results))
+	}
+	if results[0].Summary != "jazz" {
+		t.Errorf("expected lexically matching memory first, got %q", results[0].Summary)
+	}
+}
+
+func TestSearchWithoutFallbackReturnsNilWhenEmbedderFails(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: failingEmbedder{}})
+	cm.store.InsertMemory(Memory{Content: "jazz records", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "jazz"})
+
+	if results := cm.Search("jazz", "u1", 5, nil); results != nil {
+		t.Errorf("expected nil without DegradedFallback, got %v", results)
+	}
+}
+
+func TestAddRoundTripsMessageHalvesWithPipe(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	user := "Is it cats | dogs for you?"
+	assistant := "Dogs, always"
+	if _, err := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: user, AssistantMessage: assistant, Vector: []float32{1, 0, 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].UserMessage != user {
+		t.Errorf("expected user message %q, got %q", user, results[0].UserMessage)
+	}
+	if results[0].AssistantMessage != assistant {
+		t.Errorf("expected assistant message %q, got %q", assistant, results[0].AssistantMessage)
+	}
+}
+
+func TestSearchExcludeIDsReturnsNextBest(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	// Six memories at decreasing similarity to the query; the least similar is
+	// highly salient so the guarantee would otherwise inject it.
+	vecs := [][]float32{{1, 0, 0}, {0.95, 0.31, 0}, {0.9, 0.44, 0}, {0.8, 0.6, 0}, {0.6, 0.8, 0}, {0, 1, 0}}
+	ids := make([]int64, len(vecs))
+	for i, v := range vecs {
+		salience := 0.5
+		if i == len(vecs)-1 {
+			salience = 0.9
+		}
+		ids[i], _ = cm.AddWithOptions(AddOptions{
+			UserID: "u1", UserMessage: "memory", SectorHint: SectorSemantic, Salience: salience, Vector: v,
+		})
+	}
+
+	query := []float32{1, 0, 0}
+	first := cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 3, QueryVector: query})
+	if len(first) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(first))
+	}
+
+	var exclude []int64
+	seen := make(map[int64]bool)
+	for _, r := range first {
+		exclude = append(exclude, r.ID)
+		seen[r.ID] = true
+	}
+
+	second := cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 3, QueryVector: query, ExcludeIDs: exclude})
+	if len(second) != 3 {
+		t.Fatalf("expected 3 results on second search, got %d", len(second))
+	}
+	for _, r := range second {
+		if seen[r.ID] {
+			t.Errorf("excluded memory %d returned again", r.ID)
+		}
+	}
+}
+
+func TestAddAsyncDeliversResult(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	res := <-cm.AddAsync(AddOptions{UserID: "u1", UserMessage: "I fish on Sundays", Vector: []float32{1, 0, 0}})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if res.ID == 0 {
+		t.Fatal("expected a memory ID")
+	}
+	if mems, _ := cm.ListRecent("u1", 5, nil); len(mems) != 1 || mems[0].ID != res.ID {
+		t.Errorf("expected stored memory %d, got %v", res.ID, mems)
+	}
+
+	// Errors surface on the channel instead of being dropped
+	res = <-cm.AddAsync(AddOptions{UserID: "u1", UserMessage: "bad", Vector: []float32{1, 0}})
+	if res.Err == nil {
+		t.Error("expected dimension error on the result channel")
+	}
+}
+
+func TestCloseWaitsForAddAsync(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, MaxAsyncAdds: 2})
+
+	var results []<-chan AddResult
+	for i := 0; i < 10; i++ {
+		results = append(results, cm.AddAsync(AddOptions{UserID: "u1", UserMessage: "turn", Vector: []float32{1, 0, 0}}))
+	}
+	cm.Close()
+
+	for _, ch := range results {
+		select {
+		case res := <-ch:
+			if res.Err != nil {
+				t.Errorf("expected pending add to complete before Close, got %v", res.Err)
+			}
+		default:
+			t.Fatal("expected every AddAsync result to be delivered by the time Close returns")
+		}
+	}
+}
+
+func TestNegativeMaxAsyncAddsUsesDefault(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, MaxAsyncAdds: -1})
+	if res := <-cm.AddAsync(AddOptions{UserID: "u1", UserMessage: "turn", Vector: []float32{1, 0, 0}}); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if got := cm.EffectiveConfig().MaxAsyncAdds; got != 4 {
+		t.Errorf("expected default MaxAsyncAdds 4, got %d", got)
+	}
+}
+
+func TestNegativeMaxEntitiesPerMemoryUsesDefault(t *testing.T) {
+	cm := testEngramConfig(t, Config{MaxEntitiesPerMemory: -1})
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "I met Alex and Sam at Tokyo Tower", AssistantMessage: "Say hi to Alex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.EffectiveConfig().MaxEntitiesPerMemory; got != 8 {
+		t.Errorf("expected default MaxEntitiesPerMemory 8, got %d", got)
+	}
+	if wpIDs, _ := cm.store.GetAssociatedWaypointIDs(id); len(wpIDs) == 0 {
+		t.Error("expected extracted entities to be linked")
+	}
+}
+
+func TestEntityTypeRecallBoostOutranksUnlinked(t *testing.T) {
+	cm := testEngramConfig(t, Config{
+		EmbeddingProvider:     &mockEmbedder{dim: 3},
+		EntityTypeRecallBoost: map[string]float64{"quest_objective": 1.5},
+	})
+
+	plain, _ := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "the weather is fine", SectorHint: SectorEpisodic,
+		Vector: []float32{1, 0, 0}, Entities: []Entity{},
+	})
+	quest, _ := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "find the silver key", SectorHint: SectorEpisodic,
+		Vector: []float32{1, 0, 0}, Entities: []Entity{{Text: "silver key", Type: "quest_objective"}},
+	})
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != quest || results[1].ID != plain {
+		t.Errorf("expected boosted quest memory %d first, got %d then %d", quest, results[0].ID, results[1].ID)
+	}
+	if results[0].CompositeScore <= results[1].CompositeScore*1.4 {
+		t.Errorf("expected ~1.5x boost, got %.3f vs %.3f", results[0].CompositeScore, results[1].CompositeScore)
+	}
+}
+
+func TestClearUserLeavesOtherUsers(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	for _, user := range []string{"u1", "u1", "u1", "u2"} {
+		cm.AddWithOptions(AddOptions{
+			UserID: user, UserMessage: "[Alex]: hi", Vector: []float32{1, 0, 0},
+			Entities: []Entity{{Text: "Alex-" + user, Type: "person"}},
+		})
+	}
+	archived, _ := cm.store.InsertMemory(Memory{Content: "old", Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+	cm.Archive(archived)
+
+	n, err := cm.ClearUser("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("expected 4 memories removed, got %d", n)
+	}
+
+	count := func(query string, args ...any) int {
+		var c int
+		cm.store.db.QueryRow(query, args...).Scan(&c)
+		return c
+	}
+	if c := count(`SELECT COUNT(*) FROM memories WHERE user_id = 'u1'`); c != 0 {
+		t.Errorf("expected no u1 memories, got %d", c)
+	}
+	if c := count(`SELECT COUNT(*) FROM waypoints WHERE entity_text = 'Alex-u1'`); c != 0 {
+		t.Error("expected u1's orphaned waypoint to be removed")
+	}
+	if c := count(`SELECT COUNT(*) FROM vectors`); c != 1 {
+		t.Errorf("expected only u2's vector to remain, got %d", c)
+	}
+	if mems, _ := cm.ListRecent("u2", 10, nil); len(mems) != 1 {
+		t.Errorf("expected u2 untouched, got %d memories", len(mems))
+	}
+	if c := count(`SELECT COUNT(*) FROM waypoints WHERE entity_text = 'Alex-u2'`); c != 1 {
+		t.Error("expected u2's waypoint to survive")
+	}
+}
+
+func TestQuerySectorAffinityFavorsMatchingSector(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	semantic, _ := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "Elbow flare is common", SectorHint: SectorSemantic, Vector: []float32{1, 0, 0},
+	})
+	procedural, _ := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "Tuck the elbow, then press", SectorHint: SectorProcedural, Vector: []float32{1, 0, 0},
+	})
+
+	query := "what's the correct method to fix my elbow flare?"
+	search := func(affinity float64) []SearchResult {
+		return cm.SearchWithOptions(SearchOptions{
+			UserID: "u1", Query: query, QueryVector: []float32{1, 0, 0}, QuerySectorAffinity: affinity,
+		})
+	}
+
+	results := search(0.3)
+	if len(results) != 2 || results[0].ID != procedural {
+		t.Fatalf("expected procedural memory %d first with affinity, got %v", procedural, results)
+	}
+	if results[0].CompositeScore <= results[1].CompositeScore {
+		t.Errorf("expected a score gap, got %.3f vs %.3f", results[0].CompositeScore, results[1].CompositeScore)
+	}
+
+	// Without affinity, equally similar memories score equally
+	neutral := search(0)
+	if len(neutral) != 2 || math.Abs(neutral[0].CompositeScore-neutral[1].CompositeScore) > 1e-6 {
+		t.Errorf("expected equal scores without affinity for %d and %d, got %v", semantic, procedural, neutral)
+	}
+}
+
+func TestAddRawContentStoresObservation(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3})
+
+	raw := "I noticed the bar got quiet after Marcus left"
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := cm.Search("bar", "u1", 5, nil)
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("expected the observation to be searchable, got %+v", results)
+	}
+	got := results[0]
+	if got.Content != raw {
+		t.Errorf("expected raw content stored verbatim, got %q", got.Content)
+	}
+	if got.AssistantMessage != "" {
+		t.Errorf("expected no assistant message, got %q", got.AssistantMessage)
+	}
+	if want := cm.classifier.Classify(raw); got.Sector != want {
+		t.Errorf("expected sector %s from classifying the raw content, got %s", want, got.Sector)
+	}
+}
+
+func TestSearchCandidateCapScoresCappedSet(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, SearchCandidateCap: 4})
+
+	// The best match is old and unremarkable, so the cap drops it.
+	stale, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "stale", SectorHint: SectorSemantic, Salience: 0.2, Vector: []float32{1, 0, 0}})
+	for i := 0; i < 8; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "filler", SectorHint: SectorSemantic, Salience: 0.3, Vector: []float32{0, 1, 0}})
+	}
+	near, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "near", SectorHint: SectorSemantic, Salience: 0.3, Vector: []float32{0.9, 0.44, 0}})
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 10, QueryVector: []float32{1, 0, 0}})
+	if len(results) > 4 {
+		t.Fatalf("expected at most 4 candidates scored, got %d", len(results))
+	}
+	if len(results) == 0 || results[0].ID != near {
+		t.Fatalf("expected best match from the capped set first, got %+v", results)
+	}
+	for _, r := range results {
+		if r.ID == stale {
+			t.Error("expected memory outside the cap not to be loaded")
+		}
+	}
+}
+
+func BenchmarkSearchCandidateCap(b *testing.B) {
+	for _, limit := range []int{0, 100} {
+		b.Run(fmt.Sprintf("cap=%d", limit), func(b *testing.B) {
+			cm, err := Init(Config{
+				DBPath:             filepath.Join(b.TempDir(), "bench.db"),
+				DecayInterval:      999999 * 1e9,
+				MaxMemoriesPerUser: 5000,
+				EmbeddingProvider:  &mockEmbedder{dim: 768},
+				SearchCandidateCap: limit,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cm.Close()
+
+			vec := make([]float32, 768)
+			for i := 0; i < 2000; i++ {
+				vec[i%768] = 1
+				cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "memory", SectorHint: SectorSemantic, Vector: append([]float32(nil), vec...)})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cm.SearchWithOptions(SearchOptions{UserID: "u1", Limit: 5, QueryVector: vec})
+			}
+		})
+	}
+}
+
+func TestAddMediaRefCaptionDrivesRetrieval(t *testing.T) {
+	emb := keywordVectors{
+		"a rusty key with a skull engraving": {1, 0, 0},
+		"skull key":                          {0.95, 0.31, 0},
+		"the weather was nice":               {0, 1, 0},
+	}
+	cm := testEngram(t, nil, emb)
+
+	ref := MediaRef{URI: "https://cdn.example/items/key.png", Caption: "a rusty key with a skull engraving"}
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", MediaRefs: []MediaRef{ref}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "the weather was nice"})
+
+	results := cm.Search("skull key", "u1", 1, nil)
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("expected the captioned memory to rank first, got %+v", results)
+	}
+	got := results[0]
+	if got.Content != ref.Caption {
+		t.Errorf("expected caption as searchable content, got %q", got.Content)
+	}
+	if len(got.MediaRefs) != 1 || got.MediaRefs[0] != ref {
+		t.Errorf("expected media ref to round-trip, got %+v", got.MediaRefs)
+	}
+}
+
+func TestAddMediaRefCaptionJoinsExchangeWithSeparator(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID: "u1", UserMessage: "look what I found", AssistantMessage: "ooh, creepy",
+		MediaRefs: []MediaRef{{URI: "https://cdn.example/items/key.png", Caption: "a rusty key"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mems, err := cm.ListRecent("u1", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "look what I found" + exchangeSeparator + "ooh, creepy" + exchangeSeparator + "a rusty key"
+	if len(mems) != 1 || mems[0].ID != id || mems[0].Content != want {
+		t.Errorf("expected memory %d with content %q, got %+v", id, want, mems)
+	}
+}
+
+// keywordVectors is an EmbeddingProvider returning a fixed vector per text.
+type keywordVectors map[string][]float32
+
+func (k keywordVectors) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	return k[text], nil
+}
+
+func (k keywordVectors) Dimension() int { return 3 }
+
+func TestAddIdempotencyKeyDedupsRetries(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+
+	opts := AddOptions{UserID: "u1", UserMessage: "I beat the boss", AssistantMessage: "Congrats!", IdempotencyKey: "req-42"}
+	first, err := cm.AddWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cm.AddWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == 0 || second != first {
+		t.Errorf("expected retry to return memory %d, got %d", first, second)
+	}
+
+	var count int
+	cm.store.db.QueryRow(`SELECT COUNT(*) FROM memories WHERE user_id = 'u1'`).Scan(&count)
+	if count != 1 {
+		t.Errorf("expected a single stored row, got %d", count)
+	}
+
+	// Keys are scoped per user, and memories without a key never collide
+	other, _ := cm.AddWithOptions(AddOptions{UserID: "u2", UserMessage: "hi", IdempotencyKey: "req-42"})
+	if other == 0 || other == first {
+		t.Errorf("expected a separate memory for another user, got %d", other)
+	}
+	a, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "same"})
+	b, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "same"})
+	if a == b {
+		t.Error("expected keyless adds to insert separately")
+	}
+}
+
+func TestSimilaritySearchOrdersByRawSimilarity(t *testing.T) {
+	emb := keywordVectors{
+		"tokyo":               {1, 0, 0},
+		"jazz bar in tokyo":   {0.9, 0.44, 0},
+		"tokyo tower at dusk": {0.7, 0.71, 0},
+		"my cat":              {0, 1, 0},
+	}
+	cm := testEngram(t, nil, emb)
+
+	// The least similar memory is the most salient: Search would favour it
+	for content, salience := range map[string]float64{"jazz bar in tokyo": 0.2, "tokyo tower at dusk": 0.5, "my cat": 1.0} {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, SectorHint: SectorSemantic, Salience: salience})
+	}
+	before, _ := cm.store.GetMemoriesWithVectors("u1")
+
+	results, err := cm.SimilaritySearch(context.Background(), "u1", "tokyo", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"jazz bar in tokyo", "tokyo tower at dusk", "my cat"}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, r := range results {
+		if r.Content != want[i] {
+			t.Errorf("result %d: expected %q, got %q", i, want[i], r.Content)
+		}
+		if sim := CosineSimilarity(emb["tokyo"], emb[r.Content]); math.Abs(r.Similarity-sim) > 1e-9 {
+			t.Errorf("result %d: expected raw cosine %.4f, got %.4f", i, sim, r.Similarity)
+		}
+	}
+
+	after, _ := cm.store.GetMemoriesWithVectors("u1")
+	prior := make(map[int64]memoryWithVector, len(before))
+	for _, m := range before {
+		prior[m.ID] = m
+	}
+	for _, m := range after {
+		if p := prior[m.ID]; m.Salience != p.Salience || m.AccessCount != p.AccessCount {
+			t.Errorf("memory %d was reinforced by SimilaritySearch", m.ID)
+		}
+	}
+}
+
+func TestSearchRecencyTieBreakerPutsNewerFirst(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, Clock: clock})
+
+	older, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "presentation went badly", SectorHint: SectorEpisodic, Vector: []float32{1, 0, 0}})
+	clock.Advance(10 * time.Minute)
+	newer, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "presentation went better", SectorHint: SectorEpisodic, Vector: []float32{0.99, 0.14, 0}})
+
+	query := []float32{1, 0, 0}
+	plain := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: query})
+	if len(plain) != 2 || plain[0].ID != older {
+		t.Fatalf("expected the marginally better older memory first by score, got %+v", plain)
+	}
+
+	byRecency := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: query, TieBreaker: TieBreakRecency})
+	if len(byRecency) != 2 || byRecency[0].ID != newer {
+		t.Fatalf("expected the newer memory first with the recency tie-breaker, got %+v", byRecency)
+	}
+
+	narrow := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: query, TieBreaker: TieBreakRecency, TieEpsilon: 1e-6})
+	if narrow[0].ID != older {
+		t.Error("expected scores outside the epsilon band to keep score order")
+	}
+}
+
+func TestRedactFuncMasksStoredContentAndEntities(t *testing.T) {
+	phone := regexp.MustCompile(`\d{3}-\d{3}-\d{4}`)
+	cm := testEngramConfig(t, Config{
+		RedactFunc: func(s string) string { return phone.ReplaceAllString(s, "[phone]") },
+	})
+
+	// Quoted text is extracted as an entity, so an unredacted number would become a waypoint
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID:           "u1",
+		UserMessage:      `My number is "555-867-5309", ask for Jenny`,
+		AssistantMessage: "Noted, I'll keep 555-867-5309 safe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content, summary, userMsg, assistantMsg string
+	err = cm.store.db.QueryRow(`SELECT content, summary, user_message, assistant_message FROM memories WHERE id = ?`, id).
+		Scan(&content, &summary, &userMsg, &assistantMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{content, summary, userMsg, assistantMsg} {
+		if strings.Contains(s, "867-5309") {
+			t.Errorf("stored text not redacted: %q", s)
+		}
+	}
+	if !strings.Contains(content, "[phone]") {
+		t.Errorf("expected redaction marker in content, got %q", content)
+	}
+
+	var leaked int
+	cm.store.db.QueryRow(`SELECT COUNT(*) FROM waypoints WHERE entity_text LIKE '%867-5309%'`).Scan(&leaked)
+	if leaked != 0 {
+		t.Errorf("expected no entity containing the phone number, found %d", leaked)
+	}
+}
+
+func TestRedactFuncMasksMediaRefs(t *testing.T) {
+	phone := regexp.MustCompile(`\d{3}-\d{3}-\d{4}`)
+	cm := testEngramConfig(t, Config{
+		RedactFunc: func(s string) string { return phone.ReplaceAllString(s, "[phone]") },
+	})
+
+	refs := []MediaRef{{URI: "https://cdn.example/cards/555-867-5309.png", Caption: "business card reading 555-867-5309"}}
+	id, err := cm.AddWithOptions(AddOptions{UserID: "u1", MediaRefs: refs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := cm.store.db.QueryRow(`SELECT media_refs FROM memories WHERE id = ?`, id).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "867-5309") {
+		t.Errorf("media refs not redacted: %s", stored)
+	}
+	if want := "https://cdn.example/cards/[phone].png"; !strings.Contains(stored, want) {
+		t.Errorf("expected redacted URI %q in %s", want, stored)
+	}
+	if refs[0].Caption != "business card reading 555-867-5309" {
+		t.Errorf("expected caller's media refs untouched, got %+v", refs[0])
+	}
+}
+
+func TestSearchQueryExpansionsMaxPoolSimilarity(t *testing.T) {
+	emb := keywordVectors{
+		"back":                      {1, 0, 0},
+		"lower back pain":           {0, 1, 0},
+		"my back hurts":             {0, 0.9, 0.44},
+		"back button on the menu":   {1, 0, 0},
+		"went back to the menu":     {0.7, 0.71, 0},
+		"my lower back aches daily": {0, 1, 0},
+	}
+	// Each search gets a fresh engine so reinforcement from one can't skew the other
+	search := func(expansions []string) []SearchResult {
+		cm := testEngram(t, nil, emb)
+		for _, content := range []string{"back button on the menu", "went back to the menu", "my lower back aches daily"} {
+			cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, SectorHint: SectorSemantic})
+		}
+		return cm.SearchWithOptions(SearchOptions{UserID: "u1", Query: "back", Limit: 2, QueryExpansions: expansions})
+	}
+	hasContent := func(results []SearchResult, content string) bool {
+		for _, r := range results {
+			if r.Content == content {
+				return true
+			}
+		}
+		return false
+	}
+
+	if raw := search(nil); hasContent(raw, "my lower back aches daily") {
+		t.Fatalf("expected raw query to miss the back-pain memory, got %v", raw)
+	}
+
+	expanded := search([]string{"lower back pain", "my back hurts"})
+	if !hasContent(expanded, "my lower back aches daily") {
+		t.Fatalf("expected expansion to surface the back-pain memory, got %v", expanded)
+	}
+	if !hasContent(expanded, "back button on the menu") {
+		t.Errorf("expected the raw query's best match to survive expansion, got %v", expanded)
+	}
+}
+
+func TestBackupSnapshotsLiveStore(t *testing.T) {
+	emb := keywordVectors{
+		"tokyo":               {1, 0, 0},
+		"jazz bar in tokyo":   {0.9, 0.44, 0},
+		"tokyo tower at dusk": {0.7, 0.71, 0},
+		"my cat":              {0, 1, 0},
+	}
+	cm := testEngram(t, nil, emb)
+	for _, content := range []string{"jazz bar in tokyo", "tokyo tower at dusk", "my cat"} {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, SectorHint: SectorSemantic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := cm.Backup(dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Backup(dest); err == nil {
+		t.Error("expected backup over an existing file to fail")
+	}
+
+	restored, err := Init(Config{DBPath: dest, EmbeddingProvider: emb, DecayInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	want := cm.SearchWithOptions(SearchOptions{UserID: "u1", Query: "tokyo", Limit: 3})
+	got := restored.SearchWithOptions(SearchOptions{UserID: "u1", Query: "tokyo", Limit: 3})
+	if len(got) != len(want) || len(want) != 3 {
+		t.Fatalf("expected 3 matching results, got %d from backup vs %d live", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Content != want[i].Content {
+			t.Errorf("result %d: backup has #%d %q, live has #%d %q", i, got[i].ID, got[i].Content, want[i].ID, want[i].Content)
+		}
+	}
+}
+
+func TestExpansionSectorsExcludeEmotionalLinks(t *testing.T) {
+	// Returns the emotional memory's composite score for a technique query.
+	// Expansion only reaches memories outside the top-20 seeds, so the
+	// emotional memory sits below twenty procedural ones sharing its entity.
+	emotionalScore := func(cfg Config) float64 {
+		cm := testEngramConfig(t, cfg)
+		sifu := []Entity{{Text: "Sifu", Type: "person"}}
+		for i := 0; i < 20; i++ {
+			cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: fmt.Sprintf("stance drill %d", i), SectorHint: SectorProcedural, Vector: []float32{1, 0, 0}, Entities: sifu})
+		}
+		id, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "Sifu shouted at me", SectorHint: SectorEmotional, Vector: []float32{0, 1, 0}, Entities: sifu})
+
+		for _, r := range cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 21}) {
+			if r.ID == id {
+				return r.CompositeScore
+			}
+		}
+		t.Fatalf("emotional memory %d missing from results", id)
+		return 0
+	}
+
+	open := emotionalScore(Config{})
+	restricted := emotionalScore(Config{ExpansionSectors: []Sector{SectorProcedural, SectorSemantic}})
+	if open <= restricted {
+		t.Fatalf("expected the shared entity to boost the emotional memory when unrestricted (%.3f vs %.3f)", open, restricted)
+	}
+
+	// With no link term, only salience and recency contribute (similarity is 0)
+	w := DefaultScoringWeights()
+	want := (w.Salience*0.5 + w.Recency) * DefaultSectorWeights()[SectorEmotional]
+	if math.Abs(restricted-want) > 0.01 {
+		t.Errorf("expected restricted score %.3f without a link term, got %.3f", want, restricted)
+	}
+}
+
+func TestSearchBalanceAcrossSectorsReservesSlots(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+	for i := 0; i < 3; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: fmt.Sprintf("dojo visit %d", i), SectorHint: SectorEpisodic, Vector: []float32{1, 0, 0}})
+	}
+	feeling, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "felt proud", SectorHint: SectorEmotional, Vector: []float32{0.2, 0.98, 0}})
+
+	opts := SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 2, Sectors: []Sector{SectorEpisodic, SectorEmotional}}
+	for _, r := range cm.SearchWithOptions(opts) {
+		if r.ID == feeling {
+			t.Fatal("expected the far less similar emotional memory to miss an unbalanced top-2")
+		}
+	}
+
+	opts.BalanceAcrossSectors = true
+	results := cm.SearchWithOptions(opts)
+	if len(results) != 2 || results[0].Sector != SectorEpisodic || results[1].ID != feeling {
+		t.Fatalf("expected the best episodic memory then the emotional one, got %v", results)
+	}
+}
+
+func TestDecayOnReadDemotesUnsweptOldMemory(t *testing.T) {
+	// The old memory is the better match and, per its stale stored score,
+	// undecayed; 300 days without a sweep have really decayed it. (Salience
+	// stays below the high-salience guarantee, which would reinject it.)
+	topResult := func(decayOnRead bool) string {
+		clock := NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		cm := testEngramConfig(t, Config{Clock: clock, DecayOnRead: decayOnRead})
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "old insight", SectorHint: SectorReflective, Salience: 0.55, Vector: []float32{1, 0, 0}})
+		clock.Advance(300 * 24 * time.Hour)
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "fresh insight", SectorHint: SectorReflective, Vector: []float32{0.8, 0.6, 0}})
+
+		results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 1})
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+		return results[0].Content
+	}
+
+	if got := topResult(false); got != "old insight" {
+		t.Fatalf("expected the stale stored score to keep the old memory on top, got %q", got)
+	}
+	if got := topResult(true); got != "fresh insight" {
+		t.Errorf("expected on-read decay to rank the old memory lower, got %q on top", got)
+	}
+}
+
+func TestMutualEntityWeightBoostsAcknowledgedEntities(t *testing.T) {
+	cm := testEngramConfig(t, Config{MutualEntityWeight: 0.8})
+	id, err := cm.AddWithOptions(AddOptions{
+		UserID:           "u1",
+		UserMessage:      "I flew to Tokyo after a week in Kyoto",
+		AssistantMessage: "Tokyo must have been a change of pace!",
+		Entities:         []Entity{{Text: "Tokyo", Type: "place"}, {Text: "Kyoto", Type: "place"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weight := func(entity string) float64 {
+		var w float64
+		err := cm.store.db.QueryRow(`
+			SELECT a.weight FROM associations a JOIN waypoints w ON w.id = a.waypoint_id
+			WHERE a.memory_id = ? AND w.entity_text = ?`, id, entity).Scan(&w)
+		if err != nil {
+			t.Fatalf("association for %s: %v", entity, err)
+		}
+		return w
+	}
+	if mutual, oneSided := weight("Tokyo"), weight("Kyoto"); mutual != 0.8 || oneSided != 0.5 {
+		t.Errorf("expected Tokyo (both sides) 0.8 and Kyoto (user only) 0.5, got %.2f and %.2f", mutual, oneSided)
+	}
+}
+
+func TestUseAfterCloseReturnsErrClosed(t *testing.T) {
+	cm := testEngram(t, nil, keywordVectors{"tea": {1, 0, 0}})
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "tea"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := cm.Search("tea", "u1", 5, nil); results != nil {
+		t.Errorf("expected no results after Close, got %v", results)
+	}
+	if _, err := cm.SimilaritySearch(context.Background(), "u1", "tea", 5); !errors.Is(err, ErrClosed) {
+		t.Errorf("SimilaritySearch: expected ErrClosed, got %v", err)
+	}
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "tea"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("AddWithOptions: expected ErrClosed, got %v", err)
+	}
+	if res := <-cm.AddAsync(AddOptions{UserID: "u1", RawContent: "tea"}); !errors.Is(res.Err, ErrClosed) {
+		t.Errorf("AddAsync: expected ErrClosed, got %v", res.Err)
+	}
+	if _, err := cm.ListRecent("u1", 5, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("ListRecent: expected ErrClosed, got %v", err)
+	}
+	if err := cm.Close(); err != nil {
+		t.Errorf("expected a second Close to be a no-op, got %v", err)
+	}
+}
+
+func TestCloseDuringConcurrentCalls(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, ReflectEveryNMemories: 2, ReflectionProvider: &mockReflector{}})
+
+	// Every call racing Close either completes against an open store or
+	// reports ErrClosed; none may hit the closed database
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "tea", Vector: []float32{1, 0, 0}})
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := cm.ListRecent("u1", 5, nil)
+			errs <- err
+		}()
+	}
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil && !errors.Is(err, ErrClosed) {
+			t.Errorf("expected success or ErrClosed, got %v", err)
+		}
+	}
+}
+
+func TestExchangeUserWeightClassifiesFromUserFraming(t *testing.T) {
+	add := func(cfg Config) Sector {
+		cm := testEngramConfig(t, cfg)
+		_, err := cm.AddWithOptions(AddOptions{
+			UserID:           "u1",
+			UserMessage:      "I feel so sad and nervous about tomorrow's exam",
+			AssistantMessage: "The exam usually starts at 9. Professor Kim is a strict grader who prefers typed answers and likes citations.",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mems, err := cm.ListRecent("u1", 1, nil)
+		if err != nil || len(mems) != 1 {
+			t.Fatalf("expected the stored memory, got %v (err %v)", mems, err)
+		}
+		return mems[0].Sector
+	}
+
+	if sector := add(Config{}); sector != SectorSemantic {
+		t.Errorf("expected joined-content classification to be semantic, got %s", sector)
+	}
+	if sector := add(Config{ExchangeUserWeight: 0.7}); sector != SectorEmotional {
+		t.Errorf("expected user-weighted classification to be emotional, got %s", sector)
+	}
+}
+
+func TestSearchConfidenceFilterAndWeighting(t *testing.T) {
+	// Fresh engine per search: reinforcement from one search would skew the next
+	search := func(opts SearchOptions) []SearchResult {
+		cm := testEngram(t, nil, &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3})
+		add := func(content string, vec []float32, confidence float64) {
+			if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Vector: vec, Confidence: confidence, SectorHint: SectorSemantic}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		add("you mentioned jazz, I think", []float32{1, 0, 0}, 0.3)
+		add("you play the trumpet", []float32{0.9, 0.3, 0}, 0)
+		opts.UserID, opts.QueryVector, opts.Limit = "u1", []float32{1, 0, 0}, 5
+		return cm.SearchWithOptions(opts)
+	}
+
+	plain := search(SearchOptions{})
+	if len(plain) != 2 || plain[0].Content != "you mentioned jazz, I think" {
+		t.Fatalf("expected the closer hazy memory first by default, got %v", plain)
+	}
+	if plain[0].Confidence != 0.3 || plain[1].Confidence != 1 {
+		t.Errorf("expected confidences 0.3 and 1 (default) in results, got %v and %v", plain[0].Confidence, plain[1].Confidence)
+	}
+
+	filtered := search(SearchOptions{MinConfidence: 0.5})
+	if len(filtered) != 1 || filtered[0].Content != "you play the trumpet" {
+		t.Errorf("expected MinConfidence to drop the hazy memory, got %v", filtered)
+	}
+
+	weighted := search(SearchOptions{WeightByConfidence: true})
+	if len(weighted) != 2 || weighted[0].Content != "you play the trumpet" {
+		t.Errorf("expected WeightByConfidence to rank the hazy memory lower, got %v", weighted)
+	}
+
+	cm := testEngram(t, nil, nil)
+	if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "x", Confidence: 1.5}); err == nil {
+		t.Error("expected an error for confidence above 1")
+	}
+}
+
+func TestSearchFlagsInjectedHighSalienceResults(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3})
+	add := func(content string, vec []float32, salience float64) {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Vector: vec, Salience: salience, SectorHint: SectorSemantic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("likes jazz", []float32{1, 0, 0}, 0.5)
+	add("likes blues", []float32{0.9, 0.1, 0}, 0.5)
+	add("is allergic to peanuts", []float32{0, 0, 1}, 0.95)
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 2})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		injected := r.Content == "is allergic to peanuts"
+		if r.Injected != injected {
+			t.Errorf("%q: expected Injected=%v, got %v", r.Content, injected, r.Injected)
+		}
+	}
+	if !results[1].Injected {
+		t.Errorf("expected the unrelated high-salience memory injected in place of the lowest result, got %v", results)
+	}
+}
+
+func TestActiveUsersListsDistinctUsers(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	if ids, err := cm.ActiveUsers(); err != nil || len(ids) != 0 {
+		t.Fatalf("expected no users in an empty store, got %v (err %v)", ids, err)
+	}
+
+	for _, userID := range []string{"lily:p2", "lily:p1", "lily:p2", "sifu:p1"} {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: userID, RawContent: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := cm.ActiveUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "lily:p1,lily:p2,sifu:p1" {
+		t.Errorf("expected each user once, sorted, got %v", ids)
+	}
+}
+
+func TestQueryEntityBoostFavorsNamedEntity(t *testing.T) {
+	search := func(boost float64) map[string]SearchResult {
+		cm := testEngramConfig(t, Config{
+			EmbeddingProvider: &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3},
+			EntityExtractor:   &DefaultEntityExtractor{KnownEntities: []KnownEntity{{Text: "Valdris", Type: "person"}}},
+			QueryEntityBoost:  boost,
+		})
+		add := func(content string, entities []Entity) {
+			_, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Vector: []float32{0.8, 0.6, 0}, Entities: entities, SectorHint: SectorEpisodic})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		add("the archivist lent me a map", []Entity{{Text: "Valdris", Type: "person"}})
+		add("a stranger lent me a map", []Entity{})
+
+		byContent := make(map[string]SearchResult)
+		for _, r := range cm.Search("What did Valdris give me?", "u1", 5, nil) {
+			byContent[r.Content] = r
+		}
+		return byContent
+	}
+
+	plain := search(0)
+	if a, b := plain["the archivist lent me a map"], plain["a stranger lent me a map"]; math.Abs(a.CompositeScore-b.CompositeScore) > 1e-9 {
+		t.Fatalf("expected equal scores without the boost, got %.3f and %.3f", a.CompositeScore, b.CompositeScore)
+	}
+
+	boosted := search(0.2)
+	linked, unlinked := boosted["the archivist lent me a map"], boosted["a stranger lent me a map"]
+	if linked.CompositeScore <= unlinked.CompositeScore {
+		t.Errorf("expected the Valdris-linked memory to outrank the unlinked one, got %.3f vs %.3f", linked.CompositeScore, unlinked.CompositeScore)
+	}
+	if math.Abs(linked.Similarity-1) > 1e-6 || math.Abs(unlinked.Similarity-0.8) > 1e-6 {
+		t.Errorf("expected similarity 0.8+0.2 for the linked memory and 0.8 for the other, got %.3f and %.3f", linked.Similarity, unlinked.Similarity)
+	}
+}
+
+func TestSearchExcludeSinceDropsCurrentTurn(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{Clock: clock, EmbeddingProvider: &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3}})
+	add := func(content string) {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, SectorHint: SectorEpisodic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("we talked about the lighthouse last week")
+	clock.Advance(time.Hour)
+	turnStart := clock.Now()
+	add("you just asked about the lighthouse")
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", Query: "lighthouse", ExcludeSince: &turnStart})
+	if len(results) != 1 || results[0].Content != "we talked about the lighthouse last week" {
+		t.Errorf("expected only the earlier memory, got %v", results)
+	}
+}
+
+func TestSimilarityTransformWidensScoreGap(t *testing.T) {
+	gap := func(transform func(float64) float64) float64 {
+		cm := testEngramConfig(t, Config{SimilarityTransform: transform})
+		for _, vec := range [][]float32{{0.95, 0.3122, 0}, {0.9, 0.4359, 0}} {
+			if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "memory", Vector: vec, SectorHint: SectorSemantic}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+		if math.Abs(results[0].Similarity-0.95) > 1e-3 {
+			t.Errorf("expected the raw similarity reported, got %.3f", results[0].Similarity)
+		}
+		return results[0].CompositeScore - results[1].CompositeScore
+	}
+
+	// Stretch the crowded 0.8-1.0 band across 0-1
+	sharpen := func(sim float64) float64 { return math.Max(0, (sim-0.8)/0.2) }
+	plain, sharpened := gap(nil), gap(sharpen)
+	if plain <= 0 || sharpened < 3*plain {
+		t.Errorf("expected sharpening to widen the gap well beyond %.3f, got %.3f", plain, sharpened)
+	}
+}
+
+func TestEmptyQueryReturnsMostSalientMemories(t *testing.T) {
+	embedder := &countingEmbedder{vec: []float32{1, 0, 0}}
+	cm := testEngramConfig(t, Config{EmbeddingProvider: embedder})
+	for _, m := range []struct {
+		content  string
+		salience float64
+	}{
+		{"minor detail", 0.2},
+		{"the user's sister is named Ada", 0.9},
+		{"small talk about weather", 0.3},
+		{"the user is afraid of heights", 0.7},
+	} {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: m.content, Salience: m.salience, SectorHint: SectorSemantic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adds := embedder.calls.Load()
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", Query: "  ", Limit: 2})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 default-context results, got %d", len(results))
+	}
+	if results[0].Content != "the user's sister is named Ada" || results[1].Content != "the user is afraid of heights" {
+		t.Errorf("expected the two most salient memories, got %q and %q", results[0].Content, results[1].Content)
+	}
+	if calls := embedder.calls.Load(); calls != adds {
+		t.Errorf("expected the empty query not to be embedded, got %d extra calls", calls-adds)
+	}
+
+	none := testEngramConfig(t, Config{EmbeddingProvider: embedder, OnEmptyQuery: EmptyQueryNone})
+	none.AddWithOptions(AddOptions{UserID: "u1", RawContent: "anything", SectorHint: SectorSemantic})
+	if results := none.SearchWithOptions(SearchOptions{UserID: "u1"}); results != nil {
+		t.Errorf("expected EmptyQueryNone to return nothing, got %d results", len(results))
+	}
+}
+
+func TestRecordClassifyConfidence(t *testing.T) {
+	cm := testEngramConfig(t, Config{RecordClassifyConfidence: true})
+	add := func(opts AddOptions) *float64 {
+		t.Helper()
+		opts.UserID = "u1"
+		id, err := cm.AddWithOptions(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mems, _ := cm.ListRecent("u1", 10, nil)
+		for _, m := range mems {
+			if m.ID == id {
+				return m.ClassifyConfidence
+			}
+		}
+		t.Fatalf("memory #%d not found", id)
+		return nil
+	}
+
+	clear := add(AddOptions{RawContent: "I feel so happy and grateful, I love how excited everyone was"})
+	if clear == nil || *clear < 0.9 {
+		t.Errorf("expected high confidence for an emotionally clear memory, got %v", clear)
+	}
+	ambiguous := add(AddOptions{RawContent: "ok, see you around"})
+	if ambiguous == nil || *ambiguous > 0.3 {
+		t.Errorf("expected low confidence for an ambiguous memory, got %v", ambiguous)
+	}
+	if hinted := add(AddOptions{RawContent: "ok", SectorHint: SectorEpisodic}); hinted != nil {
+		t.Errorf("expected no confidence recorded for a hinted sector, got %v", *hinted)
+	}
+
+	// With ExchangeUserWeight the recorded confidence is the weighted one the
+	// classification used, not the joined content's
+	cm = testEngramConfig(t, Config{RecordClassifyConfidence: true, ExchangeUserWeight: 0.5})
+	exchange := add(AddOptions{UserMessage: "I feel so sad and nervous", AssistantMessage: "The exam starts at 9 and lasts three hours"})
+	if _, want := queryClassifier.exchangeClassify("I feel so sad and nervous", "The exam starts at 9 and lasts three hours", 0.5); exchange == nil || *exchange != want || want > 0.5 {
+		t.Errorf("expected the exchange classification's confidence %v, got %v", want, exchange)
+	}
+
+	cm = testEngramConfig(t, Config{RecordClassifyConfidence: true, Classifier: fixedClassifier(SectorProcedural)})
+	if custom := add(AddOptions{RawContent: "I feel so happy"}); custom != nil {
+		t.Errorf("expected no confidence from a classifier that can't report one, got %v", *custom)
+	}
+}
+
+// fixedClassifier files every memory under one sector.
+type fixedClassifier Sector
+
+func (f fixedClassifier) Classify(content string) Sector { return Sector(f) }
+
+func TestSearchExpandContextBundlesSessionNeighbors(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+	var parent int64
+	var ids []int64
+	for _, turn := range []struct {
+		session string
+		content string
+		vec     []float32
+	}{
+		{"s0", "an earlier visit", []float32{0, 0, 1}},
+		{"s1", "I finally sold my old car", []float32{0, 1, 0}},
+		{"s1", "I miss it already", []float32{1, 0, 0}},
+		{"s1", "anyway, what's on tap tonight?", []float32{0, 0.5, 0.5}},
+	} {
+		id, err := cm.AddWithOptions(AddOptions{UserID: "u1", SessionID: turn.session, ParentID: parent, RawContent: turn.content, Vector: turn.vec, Salience: 0.3, SectorHint: SectorEpisodic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent = id
+		ids = append(ids, id)
+	}
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 1, ExpandContext: 2})
+	if len(results) != 1 || results[0].ID != ids[2] {
+		t.Fatalf("expected the missing-it turn, got %v", results)
+	}
+	ctx := results[0].Context
+	if len(ctx) != 3 {
+		t.Fatalf("expected the turn with its session neighbors only, got %d turns", len(ctx))
+	}
+	for i, want := range ids[1:] {
+		if ctx[i].ID != want {
+			t.Errorf("context %d: expected memory #%d, got #%d (%s)", i, want, ctx[i].ID, ctx[i].Content)
+		}
+	}
+
+	if plain := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 1}); plain[0].Context != nil {
+		t.Errorf("expected no context without ExpandContext, got %d turns", len(plain[0].Context))
+	}
+}
+
+func TestHighSalienceInjectionKeepsEveryInjectedMemory(t *testing.T) {
+	search := func(policy InjectionPolicy, limit int) []SearchResult {
+		cm := testEngramConfig(t, Config{HighSalienceInjection: policy})
+		for _, m := range []struct {
+			content  string
+			vec      []float32
+			salience float64
+		}{
+			{"close match", []float32{1, 0, 0}, 0.3},
+			{"decent match", []float32{0.9, 0.44, 0}, 0.3},
+			{"weak match", []float32{0.7, 0.71, 0}, 0.3},
+			{"core memory one", []float32{0, 0, 1}, 0.9},
+			{"core memory two", []float32{0, 0.1, 0.99}, 0.8},
+		} {
+			if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: m.content, Vector: m.vec, Salience: m.salience, SectorHint: SectorSemantic}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: limit})
+	}
+
+	contents := func(results []SearchResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Content)
+		}
+		return out
+	}
+
+	replaced := contents(search(InjectReplaceLowest, 3))
+	if want := []string{"close match", "core memory one", "core memory two"}; !slices.Equal(replaced, want) {
+		t.Errorf("replace_lowest: expected %v, got %v", want, replaced)
+	}
+	// With Limit no larger than the boost count, the top-ranked result survives
+	tight := contents(search(InjectReplaceLowest, 2))
+	if want := []string{"close match", "core memory one"}; !slices.Equal(tight, want) {
+		t.Errorf("replace_lowest limit 2: expected %v, got %v", want, tight)
+	}
+	appended := contents(search(InjectAppend, 3))
+	if want := []string{"close match", "decent match", "weak match", "core memory one", "core memory two"}; !slices.Equal(appended, want) {
+		t.Errorf("append: expected %v, got %v", want, appended)
+	}
+}
+
+func TestEffectiveConfigMergesDecayOverrides(t *testing.T) {
+	cm := testEngramConfig(t, Config{DecayRates: map[Sector]float64{SectorEpisodic: 0.05}, MaxMemoriesPerUser: 100})
+	rc := cm.EffectiveConfig()
+
+	for sector, lambda := range DefaultDecayRates() {
+		want := lambda
+		if sector == SectorEpisodic {
+			want = 0.05
+		}
+		if rc.DecayRates[sector] != want {
+			t.Errorf("%s: expected decay rate %v, got %v", sector, want, rc.DecayRates[sector])
+		}
+	}
+	if rc.ScoringWeights != DefaultScoringWeights() {
+		t.Errorf("expected default scoring weights, got %+v", rc.ScoringWeights)
+	}
+	if rc.MaxMemoriesPerUser != 100 || rc.MaxAsyncAdds != 4 || rc.OnEmptyQuery != EmptyQueryContext {
+		t.Errorf("expected the override and defaults resolved, got %+v", rc)
+	}
+
+	rc.DecayRates[SectorEpisodic] = 1
+	if cm.EffectiveConfig().DecayRates[SectorEpisodic] != 0.05 {
+		t.Error("expected EffectiveConfig to return a copy of the decay rates")
+	}
+}
+
+func TestContentNormalizerUnifiesSurfaceVariants(t *testing.T) {
+	if got := NormalizeWhitespace("  hey \t im\n back  "); got != "hey im back" {
+		t.Errorf("expected whitespace collapsed, got %q", got)
+	}
+
+	strip := func(text string) string {
+		text = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, text)
+		return NormalizeWhitespace(text)
+	}
+	if a, b := strip("Hey, I'm back."), strip("hey  im back"); a != b {
+		t.Fatalf("expected the same embedding input, got %q and %q", a, b)
+	}
+
+	// keywordVectors only knows the normalized forms
+	emb := keywordVectors{"hey im back": {1, 0, 0}, "the weather was nice": {0, 1, 0}}
+	cm := testEngramConfig(t, Config{EmbeddingProvider: emb, ContentNormalizer: strip})
+	for _, content := range []string{"Hey, I'm back.", "The weather was nice!"} {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Salience: 0.3, SectorHint: SectorEpisodic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := cm.Search("hey  im back", "u1", 1, nil)
+	if len(results) != 1 || results[0].Content != "Hey, I'm back." {
+		t.Fatalf("expected the surface variant retrieved with its stored content unchanged, got %v", results)
+	}
+	if results[0].Similarity < 0.99 {
+		t.Errorf("expected a near-exact match, got similarity %.3f", results[0].Similarity)
+	}
+}
@@ -0,0 +1,121 @@
+package engram
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventType names a memory operation recorded in the event log.
+type EventType string
+
+const (
+	EventAdd        EventType = "add"        // A memory was stored
+	EventReinforce  EventType = "reinforce"  // A memory was retrieved and reinforced
+	EventReclassify EventType = "reclassify" // A memory's sector changed
+	EventReflect    EventType = "reflect"    // A reflective memory was stored
+	EventDelete     EventType = "delete"     // A memory was deleted (any cause)
+	EventMerge      EventType = "merge"      // Memories were folded into this one (see MergeMemories)
+)
+
+// Event is one entry of the append-only event log (see Config.EventLog).
+type Event struct {
+	ID        int64 // Monotonic; replay order
+	Type      EventType
+	MemoryID  int64
+	UserID    string
+	Payload   json.RawMessage // Type-specific fields, e.g. sector and salience
+	CreatedAt time.Time
+}
+
+// replayBatch is how many events ReplayEvents reads per query, so fn can use
+// the store between batches without holding the single connection.
+const replayBatch = 500
+
+// ReplayEvents calls fn for each logged event in the order it happened,
+// stopping at (and returning) the first error fn returns.
+func (s *Store) ReplayEvents(fn func(Event) error) error {
+	var after int64
+	for {
+		batch, err := s.getEvents(after, replayBatch)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			if err := fn(e); err != nil {
+				return err
+			}
+			after = e.ID
+		}
+		if len(batch) < replayBatch {
+			return nil
+		}
+	}
+}
+
+// getEvents returns up to limit events with IDs greater than after.
+func (s *Store) getEvents(after int64, limit int) ([]Event, error) {
+	rows, err := s.db.Query(`
+		SELECT id, type, memory_id, user_id, payload, created_at
+		FROM events WHERE id > ? ORDER BY id LIMIT ?`,
+		after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var payload, created string
+		if err := rows.Scan(&e.ID, &e.Type, &e.MemoryID, &e.UserID, &payload, &created); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		e.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ReplayEvents replays the event log (empty unless Config.EventLog is set)
+// in order, e.g. to rebuild an external index deterministically.
+func (cm *Engram) ReplayEvents(fn func(Event) error) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	if err := cm.store.ReplayEvents(fn); err != nil {
+		return fmt.Errorf("engram: replay events: %w", err)
+	}
+	return nil
+}
+
+// logMergeEvent records the survivor of a merge as it will be stored, with the
+// merged memory IDs, if the event log is enabled. The merged memories' own
+// deletes are logged by trigger.
+func logMergeEvent(tx *sql.Tx, keep Memory, mergeIDs []int64, at string) error {
+	merged, err := json.Marshal(mergeIDs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO events (type, memory_id, user_id, payload, created_at)
+		SELECT ?, ?, ?, json_object('merged_ids', json(?), 'salience', ?, 'access_count', ?,
+		                            'content', ?, 'summary', ?), ?
+		WHERE (SELECT value FROM meta WHERE key = 'event_log') = '1'`,
+		string(EventMerge), keep.ID, keep.UserID, string(merged), keep.Salience, keep.AccessCount,
+		keep.Content, keep.Summary, at,
+	)
+	return err
+}
+
+// boolMeta encodes a flag for the meta table, where triggers read it.
+func boolMeta(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
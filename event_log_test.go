@@ -0,0 +1,114 @@
+package engram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEventLogRecordsOperationsInOrder(t *testing.T) {
+	mock := &mockReflector{reflections: []Reflection{{Content: "They train every morning", Salience: 0.8}}}
+	clock := NewFakeClock(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{EventLog: true, ReflectionProvider: mock, Clock: clock})
+
+	id, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "horse stance drill", SectorHint: SectorProcedural, Vector: []float32{1, 0, 0}})
+	for i := 0; i < 4; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "filler", SectorHint: SectorEpisodic})
+	}
+	cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 1})
+	clock.Advance(time.Hour)
+	cm.store.UpdateMemorySector(id, SectorSemantic)
+	if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := cm.store.EnforceMemoryLimit("u1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []Event
+	if err := cm.ReplayEvents(func(e Event) error { events = append(events, e); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventAdd, EventAdd, EventAdd, EventAdd, EventAdd, EventReinforce, EventReclassify, EventReflect,
+		EventDelete, EventDelete, EventDelete, EventDelete, EventDelete, EventDelete}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+
+	reclassify := events[6]
+	var raw map[string]any
+	if err := json.Unmarshal(reclassify.Payload, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if reclassify.MemoryID != id || raw["sector"] != "semantic" || raw["previous_sector"] != "procedural" {
+		t.Errorf("unexpected reclassify event: #%d %s", reclassify.MemoryID, reclassify.Payload)
+	}
+	// Events without a row timestamp still follow the configured clock
+	if want := clock.Now().Add(-time.Hour); !reclassify.CreatedAt.Equal(want) {
+		t.Errorf("expected reclassify at %v, got %v", want, reclassify.CreatedAt)
+	}
+	if last := events[len(events)-1]; !last.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("expected delete at %v, got %v", clock.Now(), last.CreatedAt)
+	}
+
+	// Replay stops at the consumer's first error
+	stop := errors.New("stop")
+	seen := 0
+	err := cm.ReplayEvents(func(Event) error { seen++; return stop })
+	if !errors.Is(err, stop) || seen != 1 {
+		t.Errorf("expected replay to stop after 1 event with the consumer's error, got %d, %v", seen, err)
+	}
+}
+
+func TestEventLogForgetsClearedAndPurgedMemories(t *testing.T) {
+	cm := testEngramConfig(t, Config{EventLog: true})
+
+	purged, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "my old address", SectorHint: SectorSemantic})
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "kept", SectorHint: SectorSemantic})
+	cm.AddWithOptions(AddOptions{UserID: "u2", RawContent: "my phone number", SectorHint: SectorSemantic})
+	if err := cm.store.ArchiveMemory(purged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.store.PurgeArchived(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.ClearUser("u2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	cm.ReplayEvents(func(e Event) error {
+		got = append(got, fmt.Sprintf("%s:%d:%s", e.UserID, e.MemoryID, e.Type))
+		return nil
+	})
+	// The purged memory keeps only its contentless delete; u2 leaves nothing
+	want := []string{"u1:2:add", fmt.Sprintf("u1:%d:delete", purged)}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestEventLogDisabledByDefault(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "not logged"})
+
+	count := 0
+	cm.ReplayEvents(func(Event) error { count++; return nil })
+	if count != 0 {
+		t.Errorf("expected no events without Config.EventLog, got %d", count)
+	}
+}
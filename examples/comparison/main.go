@@ -82,12 +82,13 @@ type flatMemory struct {
 }
 
 type flatRAGStore struct {
-	embedder engram.EmbeddingProvider
-	memories []flatMemory
+	embedder      engram.EmbeddingProvider
+	memories      []flatMemory
+	minSimilarity float64 // memories below this cosine are never returned
 }
 
-func newFlatRAGStore(embedder engram.EmbeddingProvider) *flatRAGStore {
-	return &flatRAGStore{embedder: embedder}
+func newFlatRAGStore(embedder engram.EmbeddingProvider, minSimilarity float64) *flatRAGStore {
+	return &flatRAGStore{embedder: embedder, minSimilarity: minSimilarity}
 }
 
 func (f *flatRAGStore) store(ctx context.Context, content string) error {
@@ -115,6 +116,9 @@ func (f *flatRAGStore) retrieve(ctx context.Context, query string, limit int) ([
 	var results []scored
 	for _, m := range f.memories {
 		sim := engram.CosineSimilarity(queryVec, m.vector)
+		if sim < f.minSimilarity {
+			continue // irrelevant: injecting it would only handicap the baseline
+		}
 		results = append(results, scored{m.content, sim})
 	}
 	sort.Slice(results, func(i, j int) bool {
@@ -249,8 +253,8 @@ func runStateless(ctx context.Context, gemini *geminiClient, sc *Scenario) (map[
 }
 
 // runFlatRAG generates responses using flat vector similarity retrieval.
-func runFlatRAG(ctx context.Context, gemini *geminiClient, embedder engram.EmbeddingProvider, sc *Scenario) (map[int][]string, error) {
-	store := newFlatRAGStore(embedder)
+func runFlatRAG(ctx context.Context, gemini *geminiClient, embedder engram.EmbeddingProvider, sc *Scenario, minSimilarity float64) (map[int][]string, error) {
+	store := newFlatRAGStore(embedder, minSimilarity)
 	results := make(map[int][]string)
 	limit := retrievalLimit(sc)
 
@@ -285,7 +289,7 @@ func runFlatRAG(ctx context.Context, gemini *geminiClient, embedder engram.Embed
 }
 
 // runEngram generates responses using the full geoffreyengram engine.
-func runEngram(ctx context.Context, gemini *geminiClient, apiKey string, sc *Scenario) (map[int][]string, error) {
+func runEngram(ctx context.Context, gemini *geminiClient, apiKey string, embedder engram.EmbeddingProvider, sc *Scenario) (map[int][]string, error) {
 	tmpDir, err := os.MkdirTemp("", "engram-comparison-*")
 	if err != nil {
 		return nil, fmt.Errorf("temp dir: %w", err)
@@ -297,7 +301,8 @@ func runEngram(ctx context.Context, gemini *geminiClient, apiKey string, sc *Sce
 	em, err := engram.Init(engram.Config{
 		DBPath:             dbPath,
 		GeminiAPIKey:       apiKey,
-		EmbedDimension:     768,
+		EmbeddingProvider:  embedder,
+		EmbedDimension:     embedder.Dimension(),
 		DecayInterval:      1 * time.Hour,
 		ReflectionProvider: engram.NewGeminiReflector(apiKey),
 	})
@@ -731,6 +736,7 @@ func interactiveSelect() *Scenario {
 func main() {
 	scenarioFlag := flag.String("scenario", "", "Scenario to run (e.g. lily, sifu, nyx, reeves)")
 	listFlag := flag.Bool("list", false, "List available scenarios and exit")
+	flatMinSim := flag.Float64("flat-min-sim", 0.5, "Cosine floor for flat-rag retrieval (0 = plain top-k)")
 	flag.Parse()
 
 	apiKey := os.Getenv("GEMINI_API_KEY")
@@ -761,7 +767,16 @@ func main() {
 
 	ctx := context.Background()
 	gemini := newGeminiClient(apiKey)
-	embedder := engram.NewGeminiEmbedder(apiKey, 768)
+	// ENGRAM_EMBEDDER picks another embedder for both memory modes (see
+	// engram.ProviderFromEnv); chat and judging always use Gemini
+	embedder, err := engram.ProviderFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if embedder == nil {
+		embedder = engram.NewGeminiEmbedder(apiKey, 768)
+	}
 
 	fmt.Println("╔═══════════════════════════════════════════════════════╗")
 	fmt.Println("║  geoffreyengram Comparison Test                      ║")
@@ -785,7 +800,7 @@ func main() {
 
 	// Mode 2: Flat RAG
 	fmt.Println("[2/4] Running flat-rag mode (embed + cosine top-k)...")
-	flatRAGResults, err := runFlatRAG(ctx, gemini, embedder, sc)
+	flatRAGResults, err := runFlatRAG(ctx, gemini, embedder, sc, *flatMinSim)
 	if err != nil {
 		log.Fatalf("Flat RAG failed: %v", err)
 	}
@@ -794,7 +809,7 @@ func main() {
 
 	// Mode 3: Full Engram
 	fmt.Println("[3/4] Running engram mode (full cognitive memory)...")
-	engramResults, err := runEngram(ctx, gemini, apiKey, sc)
+	engramResults, err := runEngram(ctx, gemini, apiKey, embedder, sc)
 	if err != nil {
 		log.Fatalf("Engram failed: %v", err)
 	}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// keywordEmbedder maps known texts to fixed vectors.
+type keywordEmbedder map[string][]float32
+
+func (k keywordEmbedder) Embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	return k[text], nil
+}
+
+func (k keywordEmbedder) Dimension() int { return 2 }
+
+func TestFlatRAGRetrieveExcludesBelowThreshold(t *testing.T) {
+	embedder := keywordEmbedder{
+		"my cat is named Miso": {1, 0},
+		"it rained yesterday":  {0, 1},
+		"what's my cat called": {0.95, 0.31},
+	}
+	store := newFlatRAGStore(embedder, 0.5)
+	ctx := context.Background()
+	for _, c := range []string{"my cat is named Miso", "it rained yesterday"} {
+		if err := store.store(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.retrieve(ctx, "what's my cat called", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "my cat is named Miso" {
+		t.Errorf("expected only the relevant memory, got %v", got)
+	}
+
+	store.minSimilarity = 0
+	got, _ = store.retrieve(ctx, "what's my cat called", 5)
+	if len(got) != 2 {
+		t.Errorf("expected plain top-k with no floor, got %v", got)
+	}
+}
@@ -0,0 +1,500 @@
+package engram
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// migration upgrades the schema to version. Each runs in its own transaction,
+// and the version is only recorded if up succeeds.
+type migration struct {
+	version int
+	up      func(tx *sql.Tx) error
+}
+
+// migrations is the ordered schema history. Append new versions; never edit
+// or reorder an existing entry once released.
+var migrations = []migration{
+	{1, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS memories (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				content         TEXT    NOT NULL,
+				sector          TEXT    NOT NULL DEFAULT 'semantic',
+				salience        REAL    NOT NULL DEFAULT 0.5,
+				decay_score     REAL    NOT NULL DEFAULT 0.5,
+				last_accessed_at TEXT   NOT NULL DEFAULT (datetime('now')),
+				access_count    INTEGER NOT NULL DEFAULT 0,
+				created_at      TEXT    NOT NULL DEFAULT (datetime('now')),
+				summary         TEXT    NOT NULL DEFAULT '',
+				user_id         TEXT    NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_memories_user_id ON memories(user_id);
+			CREATE INDEX IF NOT EXISTS idx_memories_sector  ON memories(sector);
+
+			CREATE TABLE IF NOT EXISTS vectors (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				memory_id       INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
+				sector          TEXT    NOT NULL,
+				vector          BLOB    NOT NULL,
+				embedding_model TEXT    NOT NULL DEFAULT 'gemini-embedding-001'
+			);
+			CREATE INDEX IF NOT EXISTS idx_vectors_memory_id ON vectors(memory_id);
+
+			CREATE TABLE IF NOT EXISTS waypoints (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				entity_text TEXT NOT NULL UNIQUE,
+				entity_type TEXT NOT NULL DEFAULT 'unknown'
+			);
+			CREATE INDEX IF NOT EXISTS idx_waypoints_entity ON waypoints(entity_text);
+
+			CREATE TABLE IF NOT EXISTS associations (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				memory_id   INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
+				waypoint_id INTEGER NOT NULL REFERENCES waypoints(id) ON DELETE CASCADE,
+				weight      REAL    NOT NULL DEFAULT 0.5,
+				UNIQUE(memory_id, waypoint_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_assoc_memory   ON associations(memory_id);
+			CREATE INDEX IF NOT EXISTS idx_assoc_waypoint ON associations(waypoint_id);
+		`)
+		return err
+	}},
+	{2, func(tx *sql.Tx) error {
+		// Phase 3: temporal columns
+		return execAll(tx,
+			`ALTER TABLE memories ADD COLUMN session_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE memories ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX IF NOT EXISTS idx_memories_session ON memories(session_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_memories_created ON memories(created_at)`,
+		)
+	}},
+	{3, func(tx *sql.Tx) error {
+		// Structured exchange: keep both halves so callers needn't re-split content.
+		// Rows below the recorded watermark may be joined exchanges (see v17);
+		// meta is created here early for it, v4 leaves it as is.
+		return execAll(tx,
+			`ALTER TABLE memories ADD COLUMN user_message TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE memories ADD COLUMN assistant_message TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE IF NOT EXISTS meta (
+				key   TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			)`,
+			`INSERT INTO meta (key, value)
+			SELECT '`+metaStructuredExchangeID+`', COALESCE(MAX(id), 0) + 1 FROM memories`,
+		)
+	}},
+	{4, func(tx *sql.Tx) error {
+		// Key/value settings that must persist across opens (e.g. detected embed dimension)
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS meta (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`)
+		return err
+	}},
+	{5, func(tx *sql.Tx) error {
+		// Emotional valence for trajectory reporting (-1 negative .. 1 positive)
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN valence REAL NOT NULL DEFAULT 0`)
+		return err
+	}},
+	{6, func(tx *sql.Tx) error {
+		// Soft delete: archived memories are hidden from retrieval until purged
+		return execAll(tx,
+			`ALTER TABLE memories ADD COLUMN archived_at TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_memories_archived ON memories(archived_at)`,
+		)
+	}},
+	{7, func(tx *sql.Tx) error {
+		// Per-user persona config, stored as a JSON blob next to the memories
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS user_config (
+			user_id    TEXT PRIMARY KEY,
+			config     TEXT NOT NULL,
+			updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+		)`)
+		return err
+	}},
+	{8, func(tx *sql.Tx) error {
+		// Keep conversation chains intact however a memory is deleted (decay,
+		// cap, prune, purge): children are repointed at the deleted memory's
+		// parent, or detached when meta parent_on_delete = 'detach'.
+		_, err := tx.Exec(`
+			CREATE TRIGGER IF NOT EXISTS memories_parent_on_delete
+			AFTER DELETE ON memories
+			BEGIN
+				UPDATE memories
+				SET parent_id = CASE
+					WHEN (SELECT value FROM meta WHERE key = 'parent_on_delete') = 'detach' THEN 0
+					ELSE OLD.parent_id
+				END
+				WHERE parent_id = OLD.id;
+			END`)
+		return err
+	}},
+	{9, func(tx *sql.Tx) error {
+		// Media references (JSON array of {uri, caption}); '' = none
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN media_refs TEXT NOT NULL DEFAULT ''`)
+		return err
+	}},
+	{10, func(tx *sql.Tx) error {
+		// Exact request dedup for retried Adds, unique per user (NULL = no key)
+		return execAll(tx,
+			`ALTER TABLE memories ADD COLUMN idempotency_key TEXT`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_memories_idempotency
+				ON memories(user_id, idempotency_key) WHERE idempotency_key IS NOT NULL`,
+		)
+	}},
+	{11, func(tx *sql.Tx) error {
+		// Per-memory decay rate overriding the sector default (NULL = sector rate)
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN decay_lambda REAL`)
+		return err
+	}},
+	{12, func(tx *sql.Tx) error {
+		// Child lookups (GetChildren, memories_parent_on_delete) filter on parent_id.
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_parent ON memories(parent_id)`)
+		return err
+	}},
+	{13, func(tx *sql.Tx) error {
+		// Emotional arousal (0 calm .. 1 intense), slows emotional-sector decay
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN arousal REAL NOT NULL DEFAULT 0`)
+		return err
+	}},
+	{14, func(tx *sql.Tx) error {
+		// Cached L2 norm of each vector, so cosine scoring only normalizes the query
+		if _, err := tx.Exec(`ALTER TABLE vectors ADD COLUMN norm REAL`); err != nil {
+			return err
+		}
+		return backfillVectorNorms(tx)
+	}},
+	{15, func(tx *sql.Tx) error {
+		// Append-only log of memory operations, written by triggers so every
+		// path (Add, Reflect, decay, caps, purges) is covered. The triggers
+		// only record while meta event_log = '1' (Config.EventLog).
+		const enabled = `(SELECT value FROM meta WHERE key = 'event_log') = '1'`
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS events (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				type       TEXT    NOT NULL,
+				memory_id  INTEGER NOT NULL,
+				user_id    TEXT    NOT NULL,
+				payload    TEXT    NOT NULL DEFAULT '{}',
+				created_at TEXT    NOT NULL DEFAULT (datetime('now'))
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS events_memory_insert
+			AFTER INSERT ON memories WHEN `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload, created_at)
+				VALUES (CASE WHEN NEW.sector = 'reflective' THEN 'reflect' ELSE 'add' END,
+				        NEW.id, NEW.user_id,
+				        json_object('sector', NEW.sector, 'salience', NEW.salience, 'content', NEW.content,
+				                    'session_id', NEW.session_id, 'parent_id', NEW.parent_id),
+				        NEW.created_at);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS events_memory_reinforce
+			AFTER UPDATE OF access_count ON memories
+			WHEN NEW.access_count > OLD.access_count AND `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload, created_at)
+				VALUES ('reinforce', NEW.id, NEW.user_id,
+				        json_object('salience', NEW.salience, 'previous_salience', OLD.salience),
+				        NEW.last_accessed_at);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS events_memory_reclassify
+			AFTER UPDATE OF sector ON memories
+			WHEN NEW.sector != OLD.sector AND `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload)
+				VALUES ('reclassify', NEW.id, NEW.user_id,
+				        json_object('sector', NEW.sector, 'previous_sector', OLD.sector));
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS events_memory_delete
+			AFTER DELETE ON memories WHEN `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload)
+				VALUES ('delete', OLD.id, OLD.user_id, json_object('sector', OLD.sector));
+			END`,
+		)
+	}},
+	{16, func(tx *sql.Tx) error {
+		// How sure the character is of a memory (0..1), separate from salience
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN confidence REAL NOT NULL DEFAULT 1`)
+		return err
+	}},
+	{17, func(tx *sql.Tx) error {
+		// Best-effort split of exchanges joined before structured storage (v3)
+		// at the first separator, so a pipe inside the assistant's reply stays
+		// in it. Only rows older than v3 qualify: newer ones with " | " and no
+		// halves are raw observations. Reflections and media memories were
+		// never joined exchanges.
+		watermark, err := structuredExchangeID(tx)
+		if err != nil || watermark == 0 {
+			return err
+		}
+		_, err = tx.Exec(`
+			UPDATE memories SET
+				user_message      = substr(content, 1, instr(content, ' | ') - 1),
+				assistant_message = substr(content, instr(content, ' | ') + 3)
+			WHERE id < ? AND instr(content, ' | ') > 0 AND user_message = '' AND assistant_message = ''
+				AND sector != 'reflective' AND media_refs = ''`,
+			watermark,
+		)
+		return err
+	}},
+	{18, func(tx *sql.Tx) error {
+		// The window a reflection summarizes: a caller label ("session",
+		// "weekly") and the span of the memories it drew on
+		return execAll(tx,
+			`ALTER TABLE memories ADD COLUMN reflection_window TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE memories ADD COLUMN window_start TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE memories ADD COLUMN window_end TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_memories_reflection_window ON memories(user_id, reflection_window)`,
+		)
+	}},
+	{19, func(tx *sql.Tx) error {
+		// How sure the heuristic classifier was of the sector it filed a
+		// memory under (NULL = not recorded)
+		_, err := tx.Exec(`ALTER TABLE memories ADD COLUMN classify_confidence REAL`)
+		return err
+	}},
+	{20, func(tx *sql.Tx) error {
+		// Tag untagged legacy vector blobs so DecodeVector never has to guess
+		// the format from a blob's length
+		return tagLegacyVectors(tx)
+	}},
+	{21, func(tx *sql.Tx) error {
+		// Reclassify and delete events have no row timestamp to copy, so take
+		// the store clock's time from meta event_clock (see Store.stampEvents),
+		// falling back to datetime('now') for the system clock
+		const enabled = `(SELECT value FROM meta WHERE key = 'event_log') = '1'`
+		const now = `COALESCE((SELECT value FROM meta WHERE key = 'event_clock'), datetime('now'))`
+		return execAll(tx,
+			`DROP TRIGGER IF EXISTS events_memory_reclassify`,
+			`DROP TRIGGER IF EXISTS events_memory_delete`,
+			`CREATE TRIGGER events_memory_reclassify
+			AFTER UPDATE OF sector ON memories
+			WHEN NEW.sector != OLD.sector AND `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload, created_at)
+				VALUES ('reclassify', NEW.id, NEW.user_id,
+				        json_object('sector', NEW.sector, 'previous_sector', OLD.sector), `+now+`);
+			END`,
+			`CREATE TRIGGER events_memory_delete
+			AFTER DELETE ON memories WHEN `+enabled+`
+			BEGIN
+				INSERT INTO events (type, memory_id, user_id, payload, created_at)
+				VALUES ('delete', OLD.id, OLD.user_id, json_object('sector', OLD.sector), `+now+`);
+			END`,
+		)
+	}},
+}
+
+// metaStructuredExchangeID is the meta key holding the first memory ID
+// stored after migration 3, when exchanges started keeping their halves.
+const metaStructuredExchangeID = "structured_exchange_id"
+
+// structuredExchangeID returns the first memory ID stored with structured
+// exchange columns. Databases migrated past v3 before the watermark was
+// recorded fall back to the first row with either half set; 0 means no row
+// is known to predate v3.
+func structuredExchangeID(tx *sql.Tx) (int64, error) {
+	var watermark int64
+	err := tx.QueryRow(`SELECT CAST(value AS INTEGER) FROM meta WHERE key = ?`, metaStructuredExchangeID).Scan(&watermark)
+	if err != sql.ErrNoRows {
+		return watermark, err
+	}
+	err = tx.QueryRow(`
+		SELECT COALESCE(MIN(id), 0) FROM memories
+		WHERE user_message != '' OR assistant_message != ''`,
+	).Scan(&watermark)
+	return watermark, err
+}
+
+// execAll runs each statement in order, stopping at the first error.
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMu serializes migrations across Stores in this process, so two
+// NewStore calls on the same path can't interleave version checks.
+var migrateMu sync.Mutex
+
+func (s *Store) migrate() error {
+	return s.applyMigrations(migrations)
+}
+
+// applyMigrations runs every migration newer than the recorded schema version,
+// in order, stopping at the first failure.
+func (s *Store) applyMigrations(ms []migration) error {
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+
+	if err := s.ensureVersionTable(); err != nil {
+		return err
+	}
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		if m.version <= version {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("version %d: %w", m.version, err)
+		}
+		version = m.version
+	}
+	return nil
+}
+
+// ensureVersionTable creates the single-row schema_version table, converting
+// the legacy one-row-per-version layout if present.
+func (s *Store) ensureVersionTable() error {
+	var legacy int
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'schema_version'
+		  AND NOT EXISTS (SELECT 1 FROM pragma_table_info('schema_version') WHERE name = 'id')`,
+	).Scan(&legacy); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if legacy > 0 {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DROP TABLE schema_version`); err != nil {
+			return err
+		}
+	}
+
+	if err := execAll(tx,
+		`CREATE TABLE IF NOT EXISTS schema_version (
+			id      INTEGER PRIMARY KEY CHECK (id = 1),
+			version INTEGER NOT NULL
+		)`,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO schema_version (id, version) VALUES (1, ?)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Re-check inside the transaction: another process may have got here first
+	var current int
+	if err := tx.QueryRow(`SELECT version FROM schema_version WHERE id = 1`).Scan(&current); err != nil {
+		return err
+	}
+	if current >= m.version {
+		return nil
+	}
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE schema_version SET version = ? WHERE id = 1`, m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the applied schema version (0 for an empty database).
+func (s *Store) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+	return version, err
+}
+
+// backfillVectorNorms computes the norm column for vectors stored before it
+// existed.
+func backfillVectorNorms(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, vector FROM vectors WHERE norm IS NULL`)
+	if err != nil {
+		return err
+	}
+	norms := make(map[int64]float64)
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return err
+		}
+		norms[id] = vectorNorm(decodeUntaggedVector(blob))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, norm := range norms {
+		if _, err := tx.Exec(`UPDATE vectors SET norm = ? WHERE id = ?`, norm, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeUntaggedVector decodes a blob stored before migration 20, when a
+// length that is a multiple of 4 meant an untagged legacy float32 blob and
+// anything else a vectorFormatFloat32 one. Only valid inside migrations up
+// to 20.
+func decodeUntaggedVector(b []byte) []float32 {
+	if len(b)%4 == 0 {
+		return float32s(b)
+	}
+	return DecodeVector(b)
+}
+
+// tagLegacyVectors prefixes untagged float32 vector blobs with
+// vectorFormatFloat32.
+func tagLegacyVectors(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, vector FROM vectors WHERE length(vector) % 4 = 0`)
+	if err != nil {
+		return err
+	}
+	tagged := make(map[int64][]byte)
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return err
+		}
+		tagged[id] = EncodeVector(float32s(blob))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, blob := range tagged {
+		if _, err := tx.Exec(`UPDATE vectors SET vector = ? WHERE id = ?`, blob, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package engram
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func memoryColumns(t *testing.T, s *Store) map[string]bool {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info('memories')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		cols[name] = true
+	}
+	return cols
+}
+
+func assertLatestSchema(t *testing.T, s *Store) {
+	t.Helper()
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestSchemaVersion() {
+		t.Errorf("expected schema version %d, got %d", latestSchemaVersion(), version)
+	}
+	cols := memoryColumns(t, s)
+	for _, col := range []string{"content", "session_id", "parent_id", "user_message", "assistant_message", "valence", "archived_at", "media_refs", "decay_lambda"} {
+		if !cols[col] {
+			t.Errorf("expected memories.%s to exist", col)
+		}
+	}
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	s := testStore(t)
+	assertLatestSchema(t, s)
+}
+
+func TestMigrateFromV1Database(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v1.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Store{db: db}
+	if err := old.applyMigrations(migrations[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO memories (content, user_id) VALUES ('legacy', 'u1')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	assertLatestSchema(t, s)
+
+	mems, err := s.GetRecentMemories("u1", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mems) != 1 || mems[0].Content != "legacy" {
+		t.Errorf("expected legacy memory to survive migration, got %v", mems)
+	}
+}
+
+func TestMigrateSplitsJoinedExchangesFromV2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v2.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Store{db: db}
+	if err := old.applyMigrations(migrations[:2]); err != nil {
+		t.Fatal(err)
+	}
+	legacy := map[string]string{
+		"plain":      "I like jazz | Great taste!",
+		"piped":      "what's 1 | 2? | It's a bitwise OR | or a pipe",
+		"unjoined":   "the bar opened at noon",
+		"reflective": "pattern | insight",
+		"observed":   "menu: soup | salad | bread",
+	}
+	for key, content := range legacy {
+		sector := "semantic"
+		if key == "reflective" {
+			sector = "reflective"
+		}
+		if key == "observed" {
+			continue
+		}
+		if _, err := db.Exec(`INSERT INTO memories (content, sector, user_id, summary) VALUES (?, ?, 'u1', ?)`, content, sector, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A raw observation stored after v3 is never split, pipe or not
+	if err := old.applyMigrations(migrations[:16]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO memories (content, sector, user_id, summary) VALUES (?, 'semantic', 'u1', 'observed')`, legacy["observed"]); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	assertLatestSchema(t, s)
+
+	want := map[string][2]string{ // user, assistant
+		"plain":      {"I like jazz", "Great taste!"},
+		"piped":      {"what's 1", "2? | It's a bitwise OR | or a pipe"},
+		"unjoined":   {"", ""},
+		"reflective": {"", ""},
+		"observed":   {"", ""},
+	}
+	for key, w := range want {
+		var content, user, assistant string
+		err := s.db.QueryRow(`SELECT content, user_message, assistant_message FROM memories WHERE summary = ?`, key).
+			Scan(&content, &user, &assistant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != legacy[key] {
+			t.Errorf("%s: expected content kept as %q, got %q", key, legacy[key], content)
+		}
+		if got := [2]string{user, assistant}; got != w {
+			t.Errorf("%s: expected split %q, got %q", key, w, got)
+		}
+	}
+}
+
+func TestMigrateFailureDoesNotRecordVersion(t *testing.T) {
+	s := testStore(t)
+	base := latestSchemaVersion()
+
+	failing := append(migrations[:len(migrations):len(migrations)], migration{base + 1, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`ALTER TABLE memories ADD COLUMN half_done TEXT`); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+	if err := s.applyMigrations(failing); err == nil {
+		t.Fatal("expected migration error")
+	}
+
+	if version, _ := s.SchemaVersion(); version != base {
+		t.Errorf("expected version to stay %d, got %d", base, version)
+	}
+	if memoryColumns(t, s)["half_done"] {
+		t.Error("expected failed migration to be rolled back")
+	}
+}
+
+func TestReopenKeepsSingleVersionRow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reopen.db")
+
+	for i := 0; i < 2; i++ {
+		s, err := NewStore(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if i == 0 {
+			s.InsertMemory(Memory{Content: "kept", Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+		}
+		s.Close()
+	}
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	assertLatestSchema(t, s)
+
+	var rows int
+	s.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&rows)
+	if rows != 1 {
+		t.Errorf("expected a single schema_version row, got %d", rows)
+	}
+	if mems, _ := s.GetRecentMemories("u1", 10, nil); len(mems) != 1 {
+		t.Errorf("expected data to survive reopening, got %d memories", len(mems))
+	}
+}
+
+func TestLegacyVersionTableIsConverted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pre-framework layout: one row per applied version
+	old := &Store{db: db}
+	if err := old.applyMigrations(migrations[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := execAllDB(db,
+		`DROP TABLE schema_version`,
+		`CREATE TABLE schema_version (version INTEGER NOT NULL)`,
+		`INSERT INTO schema_version (version) VALUES (1), (2), (2)`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	assertLatestSchema(t, s)
+}
+
+func execAllDB(db *sql.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestReopenedStoreEnforcesForeignKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fk.db")
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A reopened database skips every migration, so the pragma must come
+	// from the connection itself
+	s, err = NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	id, _ := s.InsertMemory(Memory{Content: "kept", Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+	s.InsertVector(id, SectorSemantic, []float32{1, 0, 0})
+	if _, err := s.DeleteUserMemories("u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM vectors WHERE memory_id = ?`, id).Scan(&n)
+	if n != 0 {
+		t.Errorf("expected cascade to delete the vector, found %d", n)
+	}
+}
+
+func TestMigrateTagsLegacyVectorBlobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v19.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &Store{db: db}
+	if err := old.applyMigrations(migrations[:19]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO memories (id, content, user_id) VALUES (1, 'legacy', 'u1'), (2, 'tagged', 'u1')`); err != nil {
+		t.Fatal(err)
+	}
+	// A bare float32 blob from before format tags, next to an already tagged one
+	original := []float32{0.25, -1.5, 3}
+	legacy := make([]byte, len(original)*4)
+	putFloat32s(legacy, original)
+	for id, blob := range map[int][]byte{1: legacy, 2: EncodeVector(original)} {
+		if _, err := db.Exec(`INSERT INTO vectors (memory_id, sector, vector) VALUES (?, 'semantic', ?)`, id, blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	vecs, err := s.GetVectors([]int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, v := range vecs {
+		if !slices.Equal(v, original) {
+			t.Errorf("memory %d: expected %v after migration, got %v", id, original, v)
+		}
+	}
+	if len(vecs) != 2 {
+		t.Errorf("expected both vectors, got %d", len(vecs))
+	}
+}
@@ -15,8 +15,24 @@ type SectorClassifier interface {
 	Classify(content string) Sector
 }
 
+// ExchangeClassifier is an optional SectorClassifier extension that classifies
+// an exchange from its user and assistant halves rather than their joined
+// content, giving the user's framing userWeight (0-1) of the decision. Used
+// when Config.ExchangeUserWeight is set. Built-in: HeuristicClassifier,
+// LLMClassifier.
+type ExchangeClassifier interface {
+	ClassifyExchange(userMessage, assistantMessage string, userWeight float64) Sector
+}
+
 // EntityExtractor pulls entities from memory content for the waypoint graph.
 // Built-in: DefaultEntityExtractor (brackets, quotes, capitalized phrases, known entities).
 type EntityExtractor interface {
 	Extract(content string) []Entity
 }
+
+// ContradictionChecker decides whether a new statement contradicts a stored
+// memory ("I hate jazz" vs "I love jazz"), typically via an LLM. Used by
+// FindContradictions to narrow similar memories to actual conflicts.
+type ContradictionChecker interface {
+	Contradicts(ctx context.Context, statement, memory string) (bool, error)
+}
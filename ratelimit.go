@@ -0,0 +1,118 @@
+package engram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by AddWithOptions when a user exceeds
+// Config.MaxAddsPerMinute.
+var ErrRateLimited = errors.New("engram: add rate limit exceeded")
+
+// addLimiter is a per-user token bucket: each user holds up to perMinute
+// tokens, refilled continuously at perMinute tokens per minute.
+type addLimiter struct {
+	perMinute float64
+	now       func() time.Time // overridable for tests
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newAddLimiter(perMinute int) *addLimiter {
+	return &addLimiter{
+		perMinute: float64(perMinute),
+		now:       time.Now,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// allow consumes a token for userID, reporting false if the bucket is empty.
+func (l *addLimiter) allow(userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &tokenBucket{tokens: l.perMinute, last: now}
+		l.buckets[userID] = b
+	}
+
+	// Refill for elapsed time, capped at one minute's worth
+	b.tokens += now.Sub(b.last).Minutes() * l.perMinute
+	if b.tokens > l.perMinute {
+		b.tokens = l.perMinute
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops buckets idle for a minute or more, at most once a minute.
+// Such a bucket has refilled completely, so forgetting it is equivalent to
+// keeping it and the map stays bounded by recently active users.
+// Caller must hold l.mu.
+func (l *addLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for userID, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// callPacer spaces calls evenly to stay under a per-minute quota shared by
+// every caller. Unlike addLimiter it waits for a slot rather than rejecting.
+type callPacer struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next call
+}
+
+func newCallPacer(perMinute int) *callPacer {
+	return &callPacer{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until the caller's slot, or returns ctx's error if cancelled
+// first (the slot is still consumed).
+func (p *callPacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	slot := p.next
+	if slot.Before(now) {
+		slot = now
+	}
+	p.next = slot.Add(p.interval)
+	p.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
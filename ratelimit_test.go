@@ -0,0 +1,103 @@
+package engram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAddRateLimitPerUser(t *testing.T) {
+	cm := testEngramConfig(t, Config{MaxAddsPerMinute: 3})
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cm.limiter.now = func() time.Time { return now }
+
+	add := func(userID string) error {
+		_, err := cm.AddWithOptions(AddOptions{UserID: userID, UserMessage: "hi", AssistantMessage: "hello"})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := add("u1"); err != nil {
+			t.Fatalf("add %d: unexpected error %v", i+1, err)
+		}
+	}
+	if err := add("u1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited on 4th add, got %v", err)
+	}
+	if err := add("u2"); err != nil {
+		t.Errorf("other users should not be limited, got %v", err)
+	}
+
+	// Still limited within the window
+	now = now.Add(10 * time.Second)
+	if err := add("u1"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited within the window, got %v", err)
+	}
+
+	// Window rolls over
+	now = now.Add(time.Minute)
+	if err := add("u1"); err != nil {
+		t.Errorf("expected add to succeed after window, got %v", err)
+	}
+
+	mems, _ := cm.ListRecent("u1", 10, nil)
+	if len(mems) != 4 {
+		t.Errorf("expected 4 stored memories for u1, got %d", len(mems))
+	}
+}
+
+func TestAddLimiterEvictsIdleBuckets(t *testing.T) {
+	l := newAddLimiter(2)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.allow("u1")
+	l.allow("u2")
+	if len(l.buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(l.buckets))
+	}
+
+	now = now.Add(30 * time.Second)
+	l.allow("u2")
+
+	// u1 has been idle for a minute; u2 has not
+	now = now.Add(30 * time.Second)
+	l.allow("u3")
+	if _, ok := l.buckets["u1"]; ok {
+		t.Error("expected idle bucket for u1 to be evicted")
+	}
+	if _, ok := l.buckets["u2"]; !ok {
+		t.Error("expected recently used bucket for u2 to be kept")
+	}
+
+	// An evicted user starts over with a full bucket
+	if !l.allow("u1") || !l.allow("u1") {
+		t.Error("expected evicted user to get a full bucket")
+	}
+	if l.allow("u1") {
+		t.Error("expected the third add within the minute to be limited")
+	}
+}
+
+func TestCallPacerSpacesCalls(t *testing.T) {
+	p := newCallPacer(1200) // one call per 50ms
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 3 calls to take at least 100ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.wait(ctx) // consumes the next slot
+	if err := p.wait(ctx); err == nil {
+		t.Error("expected a cancelled wait to return the context error")
+	}
+}
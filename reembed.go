@@ -0,0 +1,119 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// storedDimension returns the most common vector length among candidates
+// (0 if none have vectors), ties going to the longer length.
+func storedDimension(candidates []memoryWithVector) int {
+	counts := make(map[int]int)
+	for _, c := range candidates {
+		if len(c.Vector) > 0 {
+			counts[len(c.Vector)]++
+		}
+	}
+	best := 0
+	for dim, n := range counts {
+		if n > counts[best] || (n == counts[best] && dim > best) {
+			best = dim
+		}
+	}
+	return best
+}
+
+// checkStoredDimension compares the query dimension with that of most of the
+// user's stored vectors and applies Config.OnDimensionMismatch. It reports
+// whether Search should go ahead.
+func (cm *Engram) checkStoredDimension(userID string, queryDim int, candidates []memoryWithVector) bool {
+	stored := storedDimension(candidates)
+	if stored == 0 || stored == queryDim {
+		return true
+	}
+	log.Printf("[engram] Query embedding for %s has dimension %d but stored vectors have %d; was the embedding model changed?", userID, queryDim, stored)
+
+	switch cm.config.OnDimensionMismatch {
+	case DimensionMismatchFail:
+		return false
+	case DimensionMismatchReembed:
+		cm.startReembed(userID)
+	}
+	return true
+}
+
+// startReembed re-embeds all of userID's memories with the current embedder
+// in the background, unless a re-embed for the user is already running.
+func (cm *Engram) startReembed(userID string) {
+	if cm.embedder == nil {
+		return
+	}
+	if _, running := cm.reembedding.LoadOrStore(userID, true); running {
+		return
+	}
+
+	started := cm.goWorker(func() {
+		defer cm.reembedding.Delete(userID)
+
+		n, err := cm.reembedUser(context.Background(), userID)
+		if err != nil {
+			log.Printf("[engram] Re-embed for %s failed after %d memories: %v", userID, n, err)
+			return
+		}
+		log.Printf("[engram] Re-embedded %d memories for %s", n, userID)
+	})
+	if !started {
+		cm.reembedding.Delete(userID)
+	}
+}
+
+// reembedUser replaces the vector of each of userID's memories, archived ones
+// included, with a fresh embedding of its content, stopping early if the
+// engine is closed. Once every memory is done the new dimension is recorded
+// as the stored embed dimension. Returns the number of memories re-embedded.
+func (cm *Engram) reembedUser(ctx context.Context, userID string) (int, error) {
+	memories, err := cm.store.GetAllUserMemories(userID)
+	if err != nil {
+		return 0, err
+	}
+	// Cached vectors are stale whatever happens below
+	defer cm.vectors.drop(userID)
+
+	// A dimension detector would reject the new size, so embed through the
+	// provider it wraps and update the detected dimension at the end
+	embedder := cm.embedder
+	detector, detecting := embedder.(*dimensionDetector)
+	if detecting {
+		embedder = detector.EmbeddingProvider
+	}
+
+	n, dim := 0, 0
+	for _, m := range memories {
+		if cm.closed.Load() {
+			return n, ErrClosed
+		}
+		vec, err := embedder.Embed(ctx, cm.config.ContentNormalizer(m.Content), cm.config.documentTaskType(m.Sector))
+		if err != nil {
+			return n, err
+		}
+		if dim == 0 {
+			dim = len(vec)
+		} else if len(vec) != dim {
+			return n, fmt.Errorf("engram: re-embed returned %d-dim vector, expected %d", len(vec), dim)
+		}
+		if err := cm.store.ReplaceVector(m.ID, m.Sector, vec); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if dim == 0 {
+		return n, nil
+	}
+	if detecting {
+		return n, detector.redetect(dim)
+	}
+	return n, cm.store.SetMeta(metaEmbedDimension, strconv.Itoa(dim))
+}
@@ -0,0 +1,129 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// seedStaleVectors stores n memories for u1 with 3-dim vectors, as if written
+// under an earlier embedding model.
+func seedStaleVectors(t *testing.T, cm *Engram, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		id, err := cm.store.InsertMemory(Memory{Content: fmt.Sprintf("old memory %d", i), Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cm.store.InsertVector(id, SectorSemantic, []float32{1, 0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStoredDimension(t *testing.T) {
+	cands := []memoryWithVector{
+		{Vector: []float32{1, 0, 0}},
+		{Vector: []float32{1, 0, 0}},
+		{Vector: []float32{1, 0, 0, 0}},
+		{},
+	}
+	if got := storedDimension(cands); got != 3 {
+		t.Errorf("expected majority dimension 3, got %d", got)
+	}
+	if got := storedDimension(nil); got != 0 {
+		t.Errorf("expected 0 with no vectors, got %d", got)
+	}
+}
+
+func TestDimensionMismatchPolicies(t *testing.T) {
+	embedder := &mockEmbedder{vec: []float32{1, 0, 0, 0}, dim: 4}
+
+	t.Run("warn", func(t *testing.T) {
+		cm := testEngramConfig(t, Config{EmbeddingProvider: embedder})
+		seedStaleVectors(t, cm, 3)
+		if results := cm.Search("old", "u1", 5, nil); len(results) == 0 {
+			t.Error("expected the default policy to search anyway")
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		cm := testEngramConfig(t, Config{EmbeddingProvider: embedder, OnDimensionMismatch: DimensionMismatchFail})
+		seedStaleVectors(t, cm, 3)
+		if results := cm.Search("old", "u1", 5, nil); results != nil {
+			t.Errorf("expected no results on mismatch, got %d", len(results))
+		}
+	})
+
+	t.Run("reembed", func(t *testing.T) {
+		cm := testEngramConfig(t, Config{EmbeddingProvider: embedder, OnDimensionMismatch: DimensionMismatchReembed})
+		seedStaleVectors(t, cm, 3)
+		cm.Search("old", "u1", 5, nil)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mwvs, err := cm.store.GetMemoriesWithVectors("u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := len(mwvs) == 3
+			for _, m := range mwvs {
+				done = done && len(m.Vector) == 4
+			}
+			if done {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("stored vectors were not re-embedded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestReembedUserIncludesArchivedAndRecordsDimension(t *testing.T) {
+	cm := testEngramConfig(t, Config{
+		EmbeddingProvider:   &mockEmbedder{vec: []float32{1, 0, 0, 0}, dim: 4},
+		AutoDetectDimension: true,
+	})
+	detector := cm.embedder.(*dimensionDetector)
+	if err := detector.redetect(3); err != nil {
+		t.Fatal(err)
+	}
+	seedStaleVectors(t, cm, 3)
+	ids, err := cm.store.GetUserMemoryIDs("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.store.ArchiveMemory(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := cm.reembedUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 memories re-embedded, got %d", n)
+	}
+	vecs, err := cm.store.GetVectors(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if len(vecs[id]) != 4 {
+			t.Errorf("memory %d: expected a 4-dim vector, got %d", id, len(vecs[id]))
+		}
+	}
+
+	if stored, _ := cm.store.GetMeta(metaEmbedDimension); stored != "4" {
+		t.Errorf("expected stored embed dimension 4, got %q", stored)
+	}
+	if dim := cm.embedder.Dimension(); dim != 4 {
+		t.Errorf("expected detected dimension 4, got %d", dim)
+	}
+	if _, err := cm.embedder.Embed(context.Background(), "x", "RETRIEVAL_QUERY"); err != nil {
+		t.Errorf("expected new-size embeddings to be accepted after re-embed, got %v", err)
+	}
+}
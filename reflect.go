@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
 )
 
 // Reflection represents a synthesized observation generated from a set of memories.
@@ -27,6 +31,30 @@ type ReflectOptions struct {
 	MemoryWindow     int      // How many recent memories to consider (default: 50)
 	Sectors          []Sector // Which sectors to draw from (default: all)
 	MinMemories      int      // Minimum memories needed before reflecting (default: 5)
+
+	// Incremental only passes memories stored since the user's last successful
+	// reflection, plus Overlap older ones for context. MinMemories then counts
+	// only the new memories, so nothing is sent until enough has happened.
+	Incremental bool
+	Overlap     int // Older memories included for context in incremental mode (default: 3)
+
+	DefaultSalience float64 // Salience for reflections the provider doesn't score (default: 0.7)
+	MinSalience     float64 // Floor applied to every stored reflection's salience (default: none)
+
+	// MaxReflections caps how many reflections one call stores, keeping the
+	// most salient, whatever the provider returns (default: no cap)
+	MaxReflections int
+
+	// WindowLabel names the window being reflected over ("session", "weekly"),
+	// stored on each reflection so ListReflectionsInWindow can retrieve them
+	// apart from reflections over other windows (default: none)
+	WindowLabel string
+
+	// IncludeSalient adds up to this many of the user's most salient memories
+	// from outside the window to the provider's input, so reflections can
+	// connect recent events to foundational facts. They don't count toward
+	// MinMemories or the window's bounds (default: none)
+	IncludeSalient int
 }
 
 // Reflect triggers reflective synthesis for a user.
@@ -34,6 +62,10 @@ type ReflectOptions struct {
 // the resulting observations as high-salience reflective memories.
 // Returns the newly created reflective memories.
 func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
 	if cm.reflector == nil {
 		return nil, fmt.Errorf("engram: no ReflectionProvider configured")
 	}
@@ -45,6 +77,19 @@ func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, e
 	if opts.MinMemories <= 0 {
 		opts.MinMemories = 5
 	}
+	if opts.Overlap <= 0 {
+		opts.Overlap = 3
+	}
+	if opts.DefaultSalience <= 0 {
+		opts.DefaultSalience = 0.7
+	}
+	if opts.CharacterContext == "" {
+		if uc, err := cm.GetUserConfig(opts.UserID); err != nil {
+			log.Printf("[engram] Load user config failed: %v", err)
+		} else if uc != nil {
+			opts.CharacterContext = uc.Persona
+		}
+	}
 
 	// 1. Load recent memories
 	recentMemories, err := cm.store.GetRecentMemories(opts.UserID, opts.MemoryWindow, opts.Sectors)
@@ -62,15 +107,49 @@ func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, e
 			inputMemories = append(inputMemories, m)
 		}
 	}
+
+	// 2b. Incremental mode: keep only memories past the watermark, plus overlap
+	var watermark int64
+	if opts.Incremental {
+		watermark, err = cm.reflectionWatermark(opts.UserID)
+		if err != nil {
+			return nil, err
+		}
+		inputMemories = sinceWatermark(inputMemories, watermark, opts.Overlap, opts.MinMemories)
+	}
 	if len(inputMemories) < opts.MinMemories {
 		return nil, nil
 	}
 
-	// 3. Call the provider
-	reflections, err := cm.reflector.Reflect(ctx, inputMemories, opts.CharacterContext)
+	// 2c. Augmented mode: add older foundational memories the window missed
+	providerInput := inputMemories
+	if opts.IncludeSalient > 0 {
+		providerInput, err = cm.withSalientMemories(inputMemories, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Call the provider (cancelling ctx aborts an in-progress call), paced
+	// to the provider quota shared by every concurrent reflection
+	if cm.reflectPacer != nil {
+		if err := cm.reflectPacer.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reflections, err := cm.reflector.Reflect(ctx, providerInput, opts.CharacterContext)
 	if err != nil {
 		return nil, fmt.Errorf("engram: reflection provider: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err // cancelled while the provider was running; store nothing
+	}
+
+	// The provider has seen these memories; advance the watermark even if every
+	// reflection turns out to be a duplicate, so they aren't re-sent next time.
+	if opts.Incremental {
+		cm.setReflectionWatermark(opts.UserID, inputMemories)
+	}
 	if len(reflections) == 0 {
 		return nil, nil
 	}
@@ -81,24 +160,29 @@ func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, e
 		return nil, nil
 	}
 
-	// 5. Store each reflection as a new Memory
+	// 4b. Keep only the most salient if the provider returned too many
+	if opts.MaxReflections > 0 && len(reflections) > opts.MaxReflections {
+		sort.SliceStable(reflections, func(i, j int) bool {
+			return reflectionSalience(reflections[i], opts) > reflectionSalience(reflections[j], opts)
+		})
+		reflections = reflections[:opts.MaxReflections]
+	}
+
+	// 5. Store each reflection as a new Memory, tagged with the window it covers
+	windowStart, windowEnd := memorySpan(inputMemories)
 	var stored []Memory
 	for _, ref := range reflections {
-		// Clamp salience
-		salience := ref.Salience
-		if salience <= 0 {
-			salience = 0.7
-		}
-		if salience > 1.0 {
-			salience = 1.0
-		}
+		salience := reflectionSalience(ref, opts)
 
 		mem := Memory{
-			Content:  ref.Content,
-			Sector:   SectorReflective,
-			Salience: salience,
-			UserID:   opts.UserID,
-			Summary:  truncateSummary(ref.Content, 200),
+			Content:          ref.Content,
+			Sector:           SectorReflective,
+			Salience:         salience,
+			UserID:           opts.UserID,
+			Summary:          truncateSummary(ref.Content, 200),
+			ReflectionWindow: opts.WindowLabel,
+			WindowStart:      windowStart,
+			WindowEnd:        windowEnd,
 		}
 
 		memID, err := cm.store.InsertMemory(mem)
@@ -110,18 +194,16 @@ func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, e
 
 		// Embed the reflection for future similarity search
 		if cm.embedder != nil {
-			vec, err := cm.embedder.Embed(ctx, ref.Content, "RETRIEVAL_DOCUMENT")
+			vec, err := cm.embed(ctx, ref.Content, cm.config.documentTaskType(SectorReflective))
 			if err == nil && vec != nil {
 				cm.store.InsertVector(memID, SectorReflective, vec)
 			}
 		}
 
 		// Create waypoint associations for entities in the reflection
-		for _, entity := range ref.Entities {
-			wpID, err := cm.store.UpsertWaypoint(entity.Text, entity.Type)
-			if err == nil {
-				cm.store.InsertAssociation(memID, wpID, 0.7) // higher weight for reflective associations
-			}
+		// (higher weight for reflective associations)
+		if err := cm.store.UpsertWaypointsAndAssociate(memID, ref.Entities, 0.7); err != nil {
+			log.Printf("[engram] Link reflection entities failed for memory #%d: %v", memID, err)
 		}
 
 		stored = append(stored, mem)
@@ -134,6 +216,152 @@ func (cm *Engram) Reflect(ctx context.Context, opts ReflectOptions) ([]Memory, e
 	return stored, nil
 }
 
+// withSalientMemories appends to the window's memories up to
+// opts.IncludeSalient of the user's most salient memories not already in it.
+func (cm *Engram) withSalientMemories(window []Memory, opts ReflectOptions) ([]Memory, error) {
+	salient, err := cm.store.GetMostSalientMemories(opts.UserID, opts.IncludeSalient+len(window), opts.Sectors)
+	if err != nil {
+		return nil, fmt.Errorf("engram: load salient memories: %w", err)
+	}
+	inWindow := make(map[int64]bool, len(window))
+	for _, m := range window {
+		inWindow[m.ID] = true
+	}
+	augmented := append([]Memory(nil), window...)
+	added := 0
+	for _, m := range salient {
+		if added == opts.IncludeSalient {
+			break
+		}
+		if !inWindow[m.ID] {
+			augmented = append(augmented, m)
+			added++
+		}
+	}
+	return augmented, nil
+}
+
+// reflectionWatermark returns the highest memory ID already passed to the
+// reflection provider for a user (0 if incremental reflection has never run).
+func (cm *Engram) reflectionWatermark(userID string) (int64, error) {
+	v, err := cm.store.GetMeta("reflect_watermark:" + userID)
+	if err != nil {
+		return 0, fmt.Errorf("engram: load reflection watermark: %w", err)
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+func (cm *Engram) setReflectionWatermark(userID string, memories []Memory) {
+	var maxID int64
+	for _, m := range memories {
+		maxID = max(maxID, m.ID)
+	}
+	if err := cm.store.SetMeta("reflect_watermark:"+userID, strconv.FormatInt(maxID, 10)); err != nil {
+		log.Printf("[engram] Save reflection watermark failed: %v", err)
+	}
+}
+
+// sinceWatermark filters newest-first memories to those with IDs above the
+// watermark, followed by up to overlap older ones. If fewer than minNew
+// memories are new, it returns only the new ones so the caller skips reflecting.
+func sinceWatermark(memories []Memory, watermark int64, overlap, minNew int) []Memory {
+	var fresh, older []Memory
+	for _, m := range memories {
+		if m.ID > watermark {
+			fresh = append(fresh, m)
+		} else if len(older) < overlap {
+			older = append(older, m)
+		}
+	}
+	if len(fresh) < minNew {
+		return fresh
+	}
+	return append(fresh, older...)
+}
+
+// ListReflections returns a user's most recent reflective memories, newest first.
+func (cm *Engram) ListReflections(userID string, limit int) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if limit <= 0 {
+		limit = 20
+	}
+	return cm.store.GetRecentMemories(userID, limit, []Sector{SectorReflective})
+}
+
+// ListReflectionsInWindow returns a user's most recent reflections made with
+// ReflectOptions.WindowLabel set to label, newest first, so e.g. weekly
+// reflections can be read separately from per-session ones.
+func (cm *Engram) ListReflectionsInWindow(userID, label string, limit int) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if limit <= 0 {
+		limit = 20
+	}
+	mems, err := cm.store.GetReflectionsByWindow(userID, label, limit)
+	if err != nil {
+		return nil, fmt.Errorf("engram: list reflections in window: %w", err)
+	}
+	return mems, nil
+}
+
+// memorySpan returns the creation times of the oldest and newest memories.
+func memorySpan(memories []Memory) (start, end time.Time) {
+	for _, m := range memories {
+		if start.IsZero() || m.CreatedAt.Before(start) {
+			start = m.CreatedAt
+		}
+		if m.CreatedAt.After(end) {
+			end = m.CreatedAt
+		}
+	}
+	return start, end
+}
+
+// PruneReflections deletes a user's reflective memories created before olderThan,
+// clearing out stale observations. Returns the number of reflections removed.
+func (cm *Engram) PruneReflections(userID string, olderThan time.Time) (int, error) {
+	if err := cm.enter(); err != nil {
+		return 0, err
+	}
+	defer cm.leave()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	n, err := cm.store.DeleteSectorMemoriesBefore(userID, SectorReflective, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("engram: prune reflections: %w", err)
+	}
+	if n > 0 {
+		log.Printf("[engram] Pruned %d reflections for %s", n, userID)
+		if err := cm.vectors.prune(cm.store, userID); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// reflectionSalience is the salience a reflection is stored with: the
+// provider's score, or opts.DefaultSalience if unscored, clamped to
+// [opts.MinSalience, 1].
+func reflectionSalience(ref Reflection, opts ReflectOptions) float64 {
+	salience := ref.Salience
+	if salience <= 0 {
+		salience = opts.DefaultSalience
+	}
+	if salience < opts.MinSalience {
+		salience = opts.MinSalience
+	}
+	return math.Min(salience, 1.0)
+}
+
 // deduplicateReflections checks if similar reflections already exist for this user.
 // Uses embedding similarity to avoid storing near-duplicate observations.
 func (cm *Engram) deduplicateReflections(ctx context.Context, userID string, reflections []Reflection) []Reflection {
@@ -163,7 +391,7 @@ func (cm *Engram) deduplicateReflections(ctx context.Context, userID string, ref
 
 	var unique []Reflection
 	for _, ref := range reflections {
-		refVec, err := cm.embedder.Embed(ctx, ref.Content, "RETRIEVAL_DOCUMENT")
+		refVec, err := cm.embed(ctx, ref.Content, cm.config.documentTaskType(SectorReflective))
 		if err != nil {
 			unique = append(unique, ref) // keep if we can't check
 			continue
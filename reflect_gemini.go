@@ -14,18 +14,56 @@ import (
 // GeminiReflector generates reflections using the Gemini API.
 // Implements ReflectionProvider.
 type GeminiReflector struct {
-	apiKey string
-	model  string
-	client *http.Client
+	apiKey         string
+	model          string
+	baseURL        string // Gemini API models URL (overridable for tests)
+	promptTemplate string // "" = built-in prompt
+	client         *http.Client
+}
+
+// GeminiReflectorOption configures a GeminiReflector.
+type GeminiReflectorOption func(*GeminiReflector)
+
+// WithReflectionPromptTemplate replaces the built-in reflection prompt.
+// The template may use two placeholders:
+//
+//	{{character_context}} — the characterContext passed to Reflect
+//	{{memories}}          — the numbered memory list, newest first
+//
+// The template must still ask for the JSON array format parseReflections expects.
+func WithReflectionPromptTemplate(tmpl string) GeminiReflectorOption {
+	return func(r *GeminiReflector) { r.promptTemplate = tmpl }
+}
+
+// WithReflectorModel sets the Gemini model (default: gemini-2.5-flash-lite).
+func WithReflectorModel(model string) GeminiReflectorOption {
+	return func(r *GeminiReflector) { r.model = model }
+}
+
+// WithReflectorBaseURL sets the Gemini models endpoint
+// (default: https://generativelanguage.googleapis.com/v1beta/models/).
+func WithReflectorBaseURL(baseURL string) GeminiReflectorOption {
+	return func(r *GeminiReflector) { r.baseURL = baseURL }
 }
 
 // NewGeminiReflector creates a reflection provider using Gemini.
-func NewGeminiReflector(apiKey string) *GeminiReflector {
-	return &GeminiReflector{
-		apiKey: apiKey,
-		model:  "gemini-2.5-flash-lite",
-		client: &http.Client{Timeout: 30 * time.Second},
+func NewGeminiReflector(apiKey string, opts ...GeminiReflectorOption) *GeminiReflector {
+	r := &GeminiReflector{
+		apiKey:  apiKey,
+		model:   "gemini-2.5-flash-lite",
+		baseURL: "https://generativelanguage.googleapis.com/v1beta/models/",
+		client:  &http.Client{Timeout: 30 * time.Second},
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// SetPromptTemplate replaces the reflection prompt after construction
+// (see WithReflectionPromptTemplate). An empty template restores the built-in one.
+func (r *GeminiReflector) SetPromptTemplate(tmpl string) {
+	r.promptTemplate = tmpl
 }
 
 // Reflect analyzes recent memories and generates reflective observations.
@@ -35,8 +73,11 @@ func (r *GeminiReflector) Reflect(ctx context.Context, memories []Memory, charac
 	}
 
 	prompt := buildReflectionPrompt(memories, characterContext)
+	if r.promptTemplate != "" {
+		prompt = renderReflectionTemplate(r.promptTemplate, memories, characterContext)
+	}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/" + r.model + ":generateContent?key=" + r.apiKey
+	url := r.baseURL + r.model + ":generateContent?key=" + r.apiKey
 
 	reqBody := map[string]any{
 		"contents": []map[string]any{
@@ -105,14 +146,7 @@ func buildReflectionPrompt(memories []Memory, characterContext string) string {
 	}
 
 	b.WriteString("Here are recent memories (newest first):\n\n")
-	for i, m := range memories {
-		fmt.Fprintf(&b, "%d. [%s] (%s) %q\n",
-			i+1,
-			m.CreatedAt.Format("2006-01-02"),
-			m.Sector,
-			m.Summary,
-		)
-	}
+	b.WriteString(formatReflectionMemories(memories))
 
 	b.WriteString(`
 Based on these memories, identify 1-3 meaningful patterns, connections, or observations
@@ -129,6 +163,28 @@ Only include genuinely insightful observations. If there are no clear patterns,
 	return b.String()
 }
 
+// renderReflectionTemplate fills a custom prompt template's placeholders.
+func renderReflectionTemplate(tmpl string, memories []Memory, characterContext string) string {
+	return strings.NewReplacer(
+		"{{character_context}}", characterContext,
+		"{{memories}}", formatReflectionMemories(memories),
+	).Replace(tmpl)
+}
+
+// formatReflectionMemories renders memories as a numbered prompt list.
+func formatReflectionMemories(memories []Memory) string {
+	var b strings.Builder
+	for i, m := range memories {
+		fmt.Fprintf(&b, "%d. [%s] (%s) %q\n",
+			i+1,
+			m.CreatedAt.Format("2006-01-02"),
+			m.Sector,
+			m.Summary,
+		)
+	}
+	return b.String()
+}
+
 // parseReflections parses the JSON response into Reflection structs.
 func parseReflections(text string) ([]Reflection, error) {
 	// Try to extract JSON array from the response
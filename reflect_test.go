@@ -2,8 +2,14 @@ package engram
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // mockReflector implements ReflectionProvider for testing.
@@ -11,10 +17,12 @@ type mockReflector struct {
 	reflections []Reflection
 	err         error
 	calledWith  []Memory // records what memories were passed
+	charCtx     string   // records the character context passed
 }
 
 func (m *mockReflector) Reflect(ctx context.Context, memories []Memory, charCtx string) ([]Reflection, error) {
 	m.calledWith = memories
+	m.charCtx = charCtx
 	return m.reflections, m.err
 }
 
@@ -251,6 +259,139 @@ func TestReflectEmptyResult(t *testing.T) {
 	}
 }
 
+func TestPruneReflectionsByAge(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+
+	insert := func(content string, sector Sector, created string) {
+		cm.store.db.Exec(`INSERT INTO memories (content, sector, salience, decay_score, summary, user_id, created_at, session_id, parent_id)
+			VALUES (?, ?, 0.7, 0.7, ?, 'u1', ?, '', 0)`, content, string(sector), content, created)
+	}
+	insert("nostalgic about Japan", SectorReflective, "2024-01-01 12:00:00")
+	insert("always orders jazz", SectorReflective, "2024-06-01 12:00:00")
+	insert("old episode", SectorEpisodic, "2024-01-01 12:00:00")
+
+	refs, err := cm.ListReflections("u1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 reflections, got %d", len(refs))
+	}
+
+	n, err := cm.PruneReflections("u1", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 pruned, got %d", n)
+	}
+
+	refs, _ = cm.ListReflections("u1", 10)
+	if len(refs) != 1 || refs[0].Content != "always orders jazz" {
+		t.Errorf("expected only the newer reflection to remain, got %v", refs)
+	}
+	all, _ := cm.ListRecent("u1", 10, nil)
+	if len(all) != 2 {
+		t.Errorf("expected non-reflective memory to be untouched, got %d memories", len(all))
+	}
+}
+
+// slowReflector blocks until its context is cancelled, signalling when called.
+type slowReflector struct {
+	started chan struct{}
+}
+
+func (s *slowReflector) Reflect(ctx context.Context, memories []Memory, charCtx string) ([]Reflection, error) {
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(30 * time.Second):
+		return []Reflection{{Content: "too late", Salience: 0.7}}, nil
+	}
+}
+
+func TestCloseCancelsInProgressReflection(t *testing.T) {
+	slow := &slowReflector{started: make(chan struct{}, 1)}
+	cm, err := Init(Config{
+		DBPath:             filepath.Join(t.TempDir(), "test.db"),
+		ReflectionProvider: slow,
+		ReflectionInterval: 10 * time.Millisecond,
+		DecayInterval:      999999 * 1e9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		cm.store.InsertMemory(Memory{Content: "m", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "m"})
+	}
+
+	select {
+	case <-slow.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reflection cycle never reached the provider")
+	}
+
+	start := time.Now()
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v; expected prompt return after cancelling reflection", elapsed)
+	}
+}
+
+func TestReflectIncrementalOnlySendsNewMemories(t *testing.T) {
+	mock := &mockReflector{}
+	cm := testEngram(t, mock, nil)
+
+	insert := func(n int) map[int64]bool {
+		ids := make(map[int64]bool)
+		for i := 0; i < n; i++ {
+			id, _ := cm.store.InsertMemory(Memory{Content: "memory", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1"})
+			ids[id] = true
+		}
+		return ids
+	}
+	opts := ReflectOptions{UserID: "u1", Incremental: true, Overlap: 2}
+
+	first := insert(6)
+	if _, err := cm.Reflect(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.calledWith) != 6 {
+		t.Fatalf("expected first reflect to see all 6 memories, got %d", len(mock.calledWith))
+	}
+
+	second := insert(5)
+	mock.calledWith = nil
+	if _, err := cm.Reflect(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	var fresh, overlap int
+	for _, m := range mock.calledWith {
+		switch {
+		case second[m.ID]:
+			fresh++
+		case first[m.ID]:
+			overlap++
+		}
+	}
+	if fresh != 5 || overlap != 2 {
+		t.Errorf("expected 5 new + 2 overlap memories, got %d new + %d overlap", fresh, overlap)
+	}
+
+	// Nothing new since: the provider isn't called
+	mock.calledWith = nil
+	cm.Reflect(context.Background(), opts)
+	if mock.calledWith != nil {
+		t.Errorf("expected no provider call without new memories, got %d memories", len(mock.calledWith))
+	}
+}
+
 func TestParseReflections(t *testing.T) {
 	input := `[{"content":"They mention music often","salience":0.8,"entities":[{"text":"music","type":"topic"}]},{"content":"Empty","salience":0.5,"entities":[]}]`
 
@@ -292,3 +433,166 @@ func TestParseReflectionsCodeBlock(t *testing.T) {
 		t.Errorf("unexpected content: %s", refs[0].Content)
 	}
 }
+
+func TestGeminiReflectorCustomPromptTemplate(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(geminiClassifyResponse("[]")))
+	}))
+	defer server.Close()
+
+	r := NewGeminiReflector("test-key",
+		WithReflectorBaseURL(server.URL+"/"),
+		WithReflectionPromptTemplate("SIFU-PROGRESSION-REVIEW as {{character_context}}:\n{{memories}}"),
+	)
+	memories := []Memory{{Summary: "practiced horse stance", Sector: SectorProcedural}}
+	if _, err := r.Reflect(context.Background(), memories, "the old master"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"SIFU-PROGRESSION-REVIEW as the old master", "practiced horse stance"} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("expected request to contain %q, got %s", want, sent)
+		}
+	}
+	if strings.Contains(sent, "mentions music") {
+		t.Error("expected built-in prompt text to be replaced")
+	}
+}
+
+func TestReflectConfiguredDefaultAndMinSalience(t *testing.T) {
+	mock := &mockReflector{
+		reflections: []Reflection{
+			{Content: "unscored", Salience: 0},
+			{Content: "faint", Salience: 0.1},
+		},
+	}
+	cm := testEngram(t, mock, nil)
+
+	for i := 0; i < 6; i++ {
+		cm.store.InsertMemory(Memory{Content: "m", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "m"})
+	}
+
+	if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", DefaultSalience: 0.4, MinSalience: 0.25}); err != nil {
+		t.Fatal(err)
+	}
+
+	mems, _ := cm.store.GetRecentMemories("u1", 100, []Sector{SectorReflective})
+	saliences := make(map[string]float64)
+	for _, m := range mems {
+		saliences[m.Content] = m.Salience
+	}
+	if s := saliences["unscored"]; s != 0.4 {
+		t.Errorf("expected unscored reflection at configured default 0.4, got %.2f", s)
+	}
+	if s := saliences["faint"]; s != 0.25 {
+		t.Errorf("expected faint reflection raised to floor 0.25, got %.2f", s)
+	}
+}
+
+func TestReflectMaxReflectionsKeepsMostSalient(t *testing.T) {
+	var flood []Reflection
+	for i := 1; i <= 10; i++ {
+		flood = append(flood, Reflection{Content: fmt.Sprintf("observation %d", i), Salience: float64(i) / 10})
+	}
+	cm := testEngram(t, &mockReflector{reflections: flood}, nil)
+	for i := 0; i < 6; i++ {
+		cm.store.InsertMemory(Memory{Content: "memory", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "m"})
+	}
+
+	results, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", MaxReflections: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 reflections stored, got %d", len(results))
+	}
+	for i, want := range []string{"observation 10", "observation 9", "observation 8"} {
+		if results[i].Content != want {
+			t.Errorf("reflection %d: expected %q, got %q (salience %.1f)", i, want, results[i].Content, results[i].Salience)
+		}
+	}
+
+	var count int
+	cm.store.db.QueryRow(`SELECT COUNT(*) FROM memories WHERE sector = 'reflective'`).Scan(&count)
+	if count != 3 {
+		t.Errorf("expected 3 reflective rows, got %d", count)
+	}
+}
+
+func TestReflectTagsReflectionsWithWindow(t *testing.T) {
+	reflector := &mockReflector{reflections: []Reflection{{Content: "the user had a rough evening", Salience: 0.8}}}
+	cm := testEngram(t, reflector, nil)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	for day := 0; day < 6; day++ {
+		id, _ := cm.store.InsertMemory(Memory{Content: fmt.Sprintf("day %d", day), Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "m"})
+		cm.store.db.Exec(`UPDATE memories SET created_at = ? WHERE id = ?`, start.AddDate(0, 0, day).Format("2006-01-02 15:04:05"), id)
+	}
+
+	if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", MemoryWindow: 2, MinMemories: 2, WindowLabel: "session"}); err != nil {
+		t.Fatal(err)
+	}
+	reflector.reflections = []Reflection{{Content: "the user's week was steady overall", Salience: 0.8}}
+	if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", MinMemories: 2, WindowLabel: "weekly"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		label, content string
+		windowStart    time.Time
+	}{
+		{"session", "the user had a rough evening", start.AddDate(0, 0, 4)},
+		{"weekly", "the user's week was steady overall", start},
+	} {
+		refs, err := cm.ListReflectionsInWindow("u1", tt.label, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(refs) != 1 || refs[0].Content != tt.content {
+			t.Fatalf("%s: expected only %q, got %v", tt.label, tt.content, refs)
+		}
+		if refs[0].ReflectionWindow != tt.label {
+			t.Errorf("%s: expected the label stored, got %q", tt.label, refs[0].ReflectionWindow)
+		}
+		if !refs[0].WindowStart.Equal(tt.windowStart) || !refs[0].WindowEnd.Equal(start.AddDate(0, 0, 5)) {
+			t.Errorf("%s: expected window %v – %v, got %v – %v", tt.label, tt.windowStart, start.AddDate(0, 0, 5), refs[0].WindowStart, refs[0].WindowEnd)
+		}
+	}
+}
+
+func TestReflectIncludeSalientAddsOlderMemories(t *testing.T) {
+	reflect := func(includeSalient int) []Memory {
+		reflector := &mockReflector{reflections: []Reflection{{Content: "insight", Salience: 0.8}}}
+		cm := testEngram(t, reflector, nil)
+		old := time.Now().UTC().AddDate(0, -6, 0).Format("2006-01-02 15:04:05")
+		for _, m := range []Memory{
+			{Content: "the user's father died last spring", Sector: SectorEmotional, Salience: 0.95},
+			{Content: "trivia from long ago", Sector: SectorEpisodic, Salience: 0.2},
+		} {
+			m.UserID, m.Summary = "u1", "m"
+			id, _ := cm.store.InsertMemory(m)
+			cm.store.db.Exec(`UPDATE memories SET created_at = ? WHERE id = ?`, old, id)
+		}
+		for i := 0; i < 5; i++ {
+			cm.store.InsertMemory(Memory{Content: fmt.Sprintf("recent chat %d", i), Sector: SectorEpisodic, Salience: 0.4, UserID: "u1", Summary: "m"})
+		}
+		if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", MemoryWindow: 5, IncludeSalient: includeSalient}); err != nil {
+			t.Fatal(err)
+		}
+		return reflector.calledWith
+	}
+
+	if input := reflect(0); len(input) != 5 {
+		t.Fatalf("expected only the 5 recent memories by default, got %d", len(input))
+	}
+	input := reflect(1)
+	if len(input) != 6 {
+		t.Fatalf("expected the window plus 1 salient memory, got %d", len(input))
+	}
+	if got := input[5].Content; got != "the user's father died last spring" {
+		t.Errorf("expected the high-salience old memory appended, got %q", got)
+	}
+}
@@ -2,7 +2,9 @@ package engram
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -12,14 +14,16 @@ func (cm *Engram) startReflectionWorker(interval time.Duration) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cm.cancelReflect = cancel
 
+	cm.workers.Add(1)
 	go func() {
+		defer cm.workers.Done()
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				cm.runReflectionCycle(ctx)
+				cm.runWorkerCycle(WorkerReflection, func() error { return cm.runReflectionCycle(ctx) })
 			case <-ctx.Done():
 				return
 			}
@@ -27,30 +31,92 @@ func (cm *Engram) startReflectionWorker(interval time.Duration) {
 	}()
 }
 
-// runReflectionCycle finds users with stored memories and triggers synthesis.
-func (cm *Engram) runReflectionCycle(ctx context.Context) {
+// runReflectionCycle finds users with stored memories and triggers synthesis,
+// reflecting up to Config.ReflectionConcurrency users at once. A failure for
+// one user doesn't stop the cycle; the last one is returned.
+func (cm *Engram) runReflectionCycle(ctx context.Context) error {
 	userIDs, err := cm.store.GetActiveUserIDs()
 	if err != nil {
 		log.Printf("[engram] Reflection cycle: get users failed: %v", err)
-		return
+		return fmt.Errorf("engram: reflection cycle: %w", err)
 	}
 
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		cycleErr error
+	)
+	slots := make(chan struct{}, cm.config.ReflectionConcurrency)
 	for _, userID := range userIDs {
 		select {
 		case <-ctx.Done():
-			return
-		default:
+			wg.Wait()
+			return nil
+		case slots <- struct{}{}:
 		}
 
-		results, err := cm.Reflect(ctx, ReflectOptions{
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results, err := cm.Reflect(ctx, ReflectOptions{
+				UserID:       userID,
+				MemoryWindow: 50,
+				MinMemories:  5,
+				Incremental:  true, // don't re-send memories earlier cycles already covered
+			})
+			if ctx.Err() != nil {
+				return // shutting down mid-reflection
+			}
+			if err != nil {
+				log.Printf("[engram] Reflection for %s failed: %v", userID, err)
+				mu.Lock()
+				cycleErr = fmt.Errorf("engram: reflection for %s: %w", userID, err)
+				mu.Unlock()
+			} else if len(results) > 0 {
+				log.Printf("[engram] Generated %d reflections for %s", len(results), userID)
+			}
+		}(userID)
+	}
+	wg.Wait()
+	return cycleErr
+}
+
+// countTowardReflection counts a newly added memory toward the user's
+// Config.ReflectEveryNMemories threshold and, on reaching it, reflects the
+// user in the background. While one is still in flight the count is kept, so
+// the next add after it finishes reflects instead. Called with cm.mu held.
+func (cm *Engram) countTowardReflection(userID string, sector Sector) {
+	n := cm.config.ReflectEveryNMemories
+	if n <= 0 || cm.reflector == nil || sector == SectorReflective {
+		return
+	}
+	cm.sinceReflect[userID]++
+	if cm.sinceReflect[userID] < n {
+		return
+	}
+	if _, running := cm.reflecting.LoadOrStore(userID, true); running {
+		return
+	}
+
+	started := cm.goWorker(func() {
+		defer cm.reflecting.Delete(userID)
+		results, err := cm.Reflect(context.Background(), ReflectOptions{
 			UserID:       userID,
-			MemoryWindow: 50,
-			MinMemories:  5,
+			MemoryWindow: max(50, n),
+			MinMemories:  n,
+			Incremental:  true,
 		})
 		if err != nil {
-			log.Printf("[engram] Reflection for %s failed: %v", userID, err)
+			log.Printf("[engram] Triggered reflection for %s failed: %v", userID, err)
 		} else if len(results) > 0 {
-			log.Printf("[engram] Generated %d reflections for %s", len(results), userID)
+			log.Printf("[engram] Generated %d reflections for %s after %d new memories", len(results), userID, n)
 		}
+	})
+	if !started {
+		cm.reflecting.Delete(userID)
+		return
 	}
+	cm.sinceReflect[userID] = 0
 }
@@ -0,0 +1,143 @@
+package engram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyReflector records which users it reflected and the most calls
+// ever in flight at once.
+type concurrencyReflector struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	users       map[string]int
+	failFor     string
+}
+
+func (r *concurrencyReflector) Reflect(ctx context.Context, memories []Memory, characterContext string) ([]Reflection, error) {
+	userID := memories[0].UserID
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	r.users[userID]++
+	r.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	if userID == r.failFor {
+		return nil, fmt.Errorf("provider exploded")
+	}
+	return []Reflection{{Content: "pattern for " + userID, Salience: 0.8}}, nil
+}
+
+func TestReflectionCycleBoundedConcurrency(t *testing.T) {
+	mock := &concurrencyReflector{users: make(map[string]int), failFor: "user-2"}
+	cm := testEngramConfig(t, Config{ReflectionProvider: mock, ReflectionConcurrency: 3})
+
+	const users = 8
+	for u := 0; u < users; u++ {
+		for i := 0; i < 6; i++ {
+			cm.store.InsertMemory(Memory{Content: "m", Sector: SectorEpisodic, Salience: 0.5, UserID: fmt.Sprintf("user-%d", u), Summary: "m"})
+		}
+	}
+
+	err := cm.runReflectionCycle(context.Background())
+	if err == nil {
+		t.Error("expected the failing user's error to be reported")
+	}
+
+	if len(mock.users) != users {
+		t.Errorf("expected all %d users reflected despite one failure, got %d", users, len(mock.users))
+	}
+	if mock.maxInFlight > 3 {
+		t.Errorf("expected at most 3 concurrent reflections, saw %d", mock.maxInFlight)
+	}
+	if mock.maxInFlight < 2 {
+		t.Errorf("expected reflections to overlap, saw max %d in flight", mock.maxInFlight)
+	}
+	if mems, _ := cm.store.GetRecentMemories("user-5", 10, []Sector{SectorReflective}); len(mems) != 1 {
+		t.Errorf("expected user-5's reflection stored, got %d", len(mems))
+	}
+}
+
+// signalReflector reports each call's memory count on calls, then waits for
+// release (nil = return at once).
+type signalReflector struct {
+	calls   chan int
+	release chan struct{}
+}
+
+func (r *signalReflector) Reflect(ctx context.Context, memories []Memory, characterContext string) ([]Reflection, error) {
+	r.calls <- len(memories)
+	if r.release != nil {
+		<-r.release
+	}
+	return nil, nil
+}
+
+func TestReflectEveryNMemories(t *testing.T) {
+	mock := &signalReflector{calls: make(chan int, 8)}
+	cm := testEngramConfig(t, Config{ReflectionProvider: mock, ReflectEveryNMemories: 3})
+
+	for i := 1; i <= 6; i++ {
+		if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: fmt.Sprintf("memory %d", i), SectorHint: SectorEpisodic}); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 != 0 {
+			continue
+		}
+		select {
+		case n := <-mock.calls:
+			if n < 3 {
+				t.Errorf("memory %d: expected the 3 new memories sent, got %d", i, n)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("reflection did not fire after memory %d", i)
+		}
+	}
+
+	// Other users keep their own counts
+	for i := 1; i <= 2; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u2", RawContent: fmt.Sprintf("other %d", i), SectorHint: SectorEpisodic})
+	}
+
+	// Close waits for triggered reflections, so any early one is counted now
+	cm.Close()
+	if extra := len(mock.calls); extra != 0 {
+		t.Errorf("expected reflections only at u1's thresholds, got %d more", extra)
+	}
+}
+
+func TestReflectEveryNMemoriesSkipsWhileInFlight(t *testing.T) {
+	mock := &signalReflector{calls: make(chan int, 8), release: make(chan struct{})}
+	cm := testEngramConfig(t, Config{ReflectionProvider: mock, ReflectEveryNMemories: 3})
+
+	for i := 1; i <= 3; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: fmt.Sprintf("memory %d", i), SectorHint: SectorEpisodic})
+	}
+	select {
+	case <-mock.calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reflection did not fire at the threshold")
+	}
+
+	// The first reflection is still blocked, so reaching the threshold again
+	// must not start a second one for the same user
+	for i := 4; i <= 6; i++ {
+		cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: fmt.Sprintf("memory %d", i), SectorHint: SectorEpisodic})
+	}
+	close(mock.release)
+	cm.Close()
+	if extra := len(mock.calls); extra != 0 {
+		t.Errorf("expected no reflection while one was in flight, got %d", extra)
+	}
+}
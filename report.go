@@ -0,0 +1,162 @@
+package engram
+
+import (
+	"fmt"
+	"time"
+)
+
+// EntityAffinities returns the entity pairs a character most strongly
+// associates for a user, ranked by how many memories mention both.
+// Intended for narrative analysis and writer tooling.
+func (cm *Engram) EntityAffinities(userID string, topN int) ([]EntityPair, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if topN <= 0 {
+		topN = 10
+	}
+	return cm.store.GetEntityCooccurrences(userID, topN, 5)
+}
+
+// EntityTimeline returns every memory associated with an entity for a user,
+// across sessions, in chronological order — "everything involving Valdris over
+// time" — so operators can check cross-session connections actually formed.
+func (cm *Engram) EntityTimeline(userID, entityText string) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if userID == "" || entityText == "" {
+		return nil, nil
+	}
+	mems, err := cm.store.GetEntityTimeline(userID, entityText)
+	if err != nil {
+		return nil, fmt.Errorf("engram: entity timeline: %w", err)
+	}
+	return mems, nil
+}
+
+// MostAccessed returns the user's most recalled memories — its "signature"
+// memories, the ones that dominate its behavior — ordered by access count.
+// Memories never recalled are omitted. limit <= 0 means 10.
+func (cm *Engram) MostAccessed(userID string, limit int) ([]Memory, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if userID == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	mems, err := cm.store.GetMostAccessedMemories(userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("engram: most accessed: %w", err)
+	}
+	return mems, nil
+}
+
+// EmotionalTrajectory reports how a user's emotional memories trend over time:
+// per bucket, how many emotional memories formed and their average valence.
+// A therapist character might use a rising valence as a sign of improvement.
+func (cm *Engram) EmotionalTrajectory(userID string, bucket time.Duration) ([]EmotionBucket, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	if bucket < time.Second {
+		return nil, fmt.Errorf("engram: trajectory bucket must be at least 1s, got %v", bucket)
+	}
+	return cm.store.GetEmotionBuckets(userID, bucket)
+}
+
+// SectorDistribution returns the fraction of a user's memories in each sector
+// (summing to 1; empty if the user has none). A heavily skewed distribution
+// means equal sector weights effectively bury the minority sectors.
+func (cm *Engram) SectorDistribution(userID string) (map[Sector]float64, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	counts, err := cm.store.GetSectorCounts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("engram: sector distribution: %w", err)
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	dist := make(map[Sector]float64, len(counts))
+	for sector, n := range counts {
+		dist[sector] = float64(n) / float64(total)
+	}
+	return dist, nil
+}
+
+// StorageFootprint reports how much a user's memories occupy: row counts,
+// embedding bytes, and an estimated total for quota and billing purposes.
+func (cm *Engram) StorageFootprint(userID string) (Footprint, error) {
+	if err := cm.enter(); err != nil {
+		return Footprint{}, err
+	}
+	defer cm.leave()
+	f, err := cm.store.GetStorageFootprint(userID)
+	if err != nil {
+		return Footprint{}, fmt.Errorf("engram: storage footprint: %w", err)
+	}
+	return f, nil
+}
+
+// SectorNormalization suggests inverse-frequency weights for a distribution
+// from SectorDistribution: each present sector gets 1/(k·fraction) for k
+// present sectors, so a perfectly balanced mix yields all 1.0. Absent sectors
+// get 1.0, leaving them unweighted. Multiply these into SectorWeights to
+// offset a skewed memory mix.
+func SectorNormalization(dist map[Sector]float64) SectorWeights {
+	var present int
+	for _, frac := range dist {
+		if frac > 0 {
+			present++
+		}
+	}
+	weights := DefaultSectorWeights()
+	for sector, frac := range dist {
+		if frac > 0 {
+			weights[sector] = 1 / (float64(present) * frac)
+		}
+	}
+	return weights
+}
+
+// SimulateDecay runs the decay sweep's math forward days more days, assuming
+// none of the user's memories is accessed meanwhile, and reports how many
+// would survive and how many the sweep would prune. Nothing is modified. The
+// survivor count at a long horizon is the steady state the current salience
+// distribution supports, a guide for sizing MaxMemoriesPerUser.
+func (cm *Engram) SimulateDecay(userID string, days float64) (survivors, pruned int, err error) {
+	if err := cm.enter(); err != nil {
+		return 0, 0, err
+	}
+	defer cm.leave()
+	if days < 0 {
+		return 0, 0, fmt.Errorf("engram: simulate decay: days must not be negative, got %v", days)
+	}
+	memories, err := cm.store.GetRecentMemories(userID, -1, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("engram: simulate decay: %w", err)
+	}
+
+	c := cm.config
+	now := c.Clock.Now()
+	for _, m := range memories {
+		elapsed := DaysBetween(m.LastAccessedAt, now) + days
+		if decayedScore(m, elapsed, c.MinDecayScore, c.decayRates, c.SectorMinDecayFloor, c.EmotionalArousalDecay) < c.MinDecayScore {
+			pruned++
+		} else {
+			survivors++
+		}
+	}
+	return survivors, pruned, nil
+}
@@ -0,0 +1,276 @@
+package engram
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEntityAffinitiesRanksStrongestPair(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	s := cm.store
+
+	jazz, _ := s.UpsertWaypoint("Jazz", "topic")
+	tokyo, _ := s.UpsertWaypoint("Tokyo", "place")
+	dad, _ := s.UpsertWaypoint("Dad", "person")
+
+	link := func(content string, wps ...int64) {
+		id, _ := s.InsertMemory(Memory{Content: content, Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: content})
+		for _, wp := range wps {
+			s.InsertAssociation(id, wp, 0.5)
+		}
+	}
+	link("jazz bar in tokyo", jazz, tokyo)
+	link("tokyo jazz festival", jazz, tokyo)
+	link("dad loved jazz", jazz, dad)
+
+	pairs, err := cm.EntityAffinities("u1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 pairs, got %d: %v", len(pairs), pairs)
+	}
+	top := pairs[0]
+	names := map[string]bool{top.A.Text: true, top.B.Text: true}
+	if !names["Jazz"] || !names["Tokyo"] || top.Count != 2 {
+		t.Errorf("expected Jazz/Tokyo x2 first, got %s/%s x%d", top.A.Text, top.B.Text, top.Count)
+	}
+	if len(top.MemoryIDs) != 2 {
+		t.Errorf("expected 2 example memory IDs, got %v", top.MemoryIDs)
+	}
+	if pairs[1].Count != 1 {
+		t.Errorf("expected weaker pair count 1, got %d", pairs[1].Count)
+	}
+}
+
+func TestEntityTimelineSpansSessions(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	s := cm.store
+
+	valdris, _ := s.UpsertWaypoint("Valdris", "person")
+	start := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	add := func(content, session string, at time.Time, userID string, linked bool) int64 {
+		id, _ := s.InsertMemory(Memory{Content: content, Sector: SectorEpisodic, Salience: 0.5, UserID: userID, Summary: content, SessionID: session})
+		s.db.Exec(`UPDATE memories SET created_at = ? WHERE id = ?`, at.Format("2006-01-02 15:04:05"), id)
+		if linked {
+			s.InsertAssociation(id, valdris, 0.5)
+		}
+		return id
+	}
+	// Inserted out of order so the result order comes from created_at
+	third := add("Valdris returned the map", "s3", start.Add(48*time.Hour), "u1", true)
+	first := add("met Valdris at the archive", "s1", start, "u1", true)
+	add("unrelated chat", "s2", start.Add(24*time.Hour), "u1", false)
+	second := add("asked about Valdris's past", "s2", start.Add(25*time.Hour), "u1", true)
+	add("another user's Valdris", "s9", start, "u2", true)
+
+	// A second spelling of the same entity links first again
+	lower, _ := s.UpsertWaypoint("valdris", "person")
+	s.InsertAssociation(first, lower, 0.5)
+
+	timeline, err := cm.EntityTimeline("u1", "valdris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline) != 3 {
+		t.Fatalf("expected 3 memories, got %d", len(timeline))
+	}
+	for i, want := range []int64{first, second, third} {
+		if timeline[i].ID != want {
+			t.Errorf("position %d: expected memory #%d, got #%d (%s)", i, want, timeline[i].ID, timeline[i].Content)
+		}
+	}
+
+	if none, err := cm.EntityTimeline("u1", "Nobody"); err != nil || len(none) != 0 {
+		t.Errorf("expected no memories for an unknown entity, got %v (err %v)", none, err)
+	}
+}
+
+func TestMostAccessedOrdersByRecallCount(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	s := cm.store
+
+	add := func(content string, recalls int) int64 {
+		id, _ := s.InsertMemory(Memory{Content: content, Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: content})
+		for i := 0; i < recalls; i++ {
+			s.ReinforceSalience(id, 0.01)
+		}
+		return id
+	}
+	add("never recalled", 0)
+	sometimes := add("recalled twice", 2)
+	signature := add("recalled constantly", 5)
+	once := add("recalled once", 1)
+
+	mems, err := cm.MostAccessed("u1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mems) != 3 {
+		t.Fatalf("expected 3 recalled memories, got %d", len(mems))
+	}
+	for i, want := range []int64{signature, sometimes, once} {
+		if mems[i].ID != want {
+			t.Errorf("position %d: expected memory #%d, got #%d (%s)", i, want, mems[i].ID, mems[i].Content)
+		}
+	}
+	if mems[0].AccessCount != 5 {
+		t.Errorf("expected access count 5, got %d", mems[0].AccessCount)
+	}
+
+	if top, _ := cm.MostAccessed("u1", 1); len(top) != 1 || top[0].ID != signature {
+		t.Errorf("expected limit 1 to return only the signature memory, got %v", top)
+	}
+}
+
+func TestEmotionalTrajectoryAveragesPerBucket(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	s := cm.store
+
+	day := 24 * time.Hour
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	add := func(at time.Time, sector Sector, valence float64) {
+		id, _ := s.InsertMemory(Memory{Content: "feeling", Sector: sector, Salience: 0.5, UserID: "u1", Valence: valence})
+		s.db.Exec(`UPDATE memories SET created_at = ? WHERE id = ?`, at.Format("2006-01-02 15:04:05"), id)
+	}
+	add(start.Add(2*time.Hour), SectorEmotional, -0.8)
+	add(start.Add(5*time.Hour), SectorEmotional, -0.4)
+	add(start.Add(6*time.Hour), SectorEpisodic, 1.0) // not emotional: ignored
+	add(start.Add(2*day+time.Hour), SectorEmotional, 0.6)
+
+	buckets, err := cm.EmotionalTrajectory("u1", day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 non-empty buckets, got %d: %v", len(buckets), buckets)
+	}
+	if !buckets[0].Start.Equal(start) || buckets[0].Count != 2 || math.Abs(buckets[0].AvgValence+0.6) > 1e-9 {
+		t.Errorf("unexpected first bucket: %+v", buckets[0])
+	}
+	if !buckets[1].Start.Equal(start.Add(2*day)) || buckets[1].Count != 1 || math.Abs(buckets[1].AvgValence-0.6) > 1e-9 {
+		t.Errorf("unexpected second bucket: %+v", buckets[1])
+	}
+
+	if _, err := cm.EmotionalTrajectory("u1", 0); err == nil {
+		t.Error("expected error for zero bucket width")
+	}
+}
+
+func TestSectorDistributionReflectsMix(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+	mix := map[Sector]int{SectorSemantic: 8, SectorEmotional: 1, SectorEpisodic: 1}
+	for sector, n := range mix {
+		for i := 0; i < n; i++ {
+			cm.store.InsertMemory(Memory{Content: "m", Sector: sector, Salience: 0.5, UserID: "u1", Summary: "m"})
+		}
+	}
+	cm.store.InsertMemory(Memory{Content: "other", Sector: SectorProcedural, Salience: 0.5, UserID: "u2", Summary: "o"})
+
+	dist, err := cm.SectorDistribution("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, frac := range dist {
+		sum += frac
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("expected fractions to sum to 1, got %v", sum)
+	}
+	if math.Abs(dist[SectorSemantic]-0.8) > 1e-9 || math.Abs(dist[SectorEmotional]-0.1) > 1e-9 {
+		t.Errorf("expected 0.8 semantic / 0.1 emotional, got %v", dist)
+	}
+	if _, ok := dist[SectorProcedural]; ok {
+		t.Error("expected another user's sectors to be excluded")
+	}
+
+	norm := SectorNormalization(dist)
+	if norm[SectorEmotional] <= norm[SectorSemantic] {
+		t.Errorf("expected minority sector to get the larger weight, got %v", norm)
+	}
+	if math.Abs(norm[SectorSemantic]*dist[SectorSemantic]-norm[SectorEmotional]*dist[SectorEmotional]) > 1e-9 {
+		t.Errorf("expected normalized contributions to balance, got %v", norm)
+	}
+	if norm[SectorProcedural] != 1 || norm[SectorReflective] != 1 {
+		t.Errorf("expected absent sectors to keep weight 1.0, got %v", norm)
+	}
+}
+
+func TestStorageFootprintCountsVectorBytes(t *testing.T) {
+	const dim = 16
+	cm := testEngram(t, nil, nil)
+	vec := make([]float32, dim)
+	for i := 0; i < 4; i++ {
+		_, err := cm.AddWithOptions(AddOptions{
+			UserID:           "u1",
+			UserMessage:      "went to Tokyo",
+			AssistantMessage: "nice",
+			Vector:           vec,
+			Entities:         []Entity{{Text: "Tokyo", Type: "place"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cm.AddWithOptions(AddOptions{UserID: "u2", Vector: vec, UserMessage: "someone else", AssistantMessage: "ok"})
+
+	f, err := cm.StorageFootprint("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Memories != 4 || f.Vectors != 4 {
+		t.Errorf("expected 4 memories and vectors, got %d and %d", f.Memories, f.Vectors)
+	}
+	if want := int64((1 + dim*4) * 4); f.VectorBytes != want {
+		t.Errorf("expected %d vector bytes ((tag+dim×4)×count), got %d", want, f.VectorBytes)
+	}
+	if f.Associations != 4 || f.Waypoints != 1 {
+		t.Errorf("expected 4 associations to 1 waypoint, got %d to %d", f.Associations, f.Waypoints)
+	}
+	if f.TextBytes <= 0 || f.EstimatedBytes <= f.TextBytes+f.VectorBytes {
+		t.Errorf("expected text bytes and a per-row overhead in the estimate, got %+v", f)
+	}
+
+	if empty, _ := cm.StorageFootprint("nobody"); empty != (Footprint{}) {
+		t.Errorf("expected zero footprint for an unknown user, got %+v", empty)
+	}
+}
+
+func TestSimulateDecayMatchesManualCalculation(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{Clock: clock})
+
+	saliences := []float64{0.05, 0.1, 0.3, 0.5, 0.7, 0.9}
+	for _, s := range saliences {
+		cm.store.InsertMemory(Memory{Content: "fact", Sector: SectorSemantic, Salience: s, UserID: "u1", Summary: "fact"})
+	}
+
+	// Semantic decay: salience × exp(-0.02 × 90 / (salience + 0.1)), pruned below 0.01
+	want := 0
+	for _, s := range saliences {
+		if s*math.Exp(-0.02*90/(s+0.1)) >= 0.01 {
+			want++
+		}
+	}
+
+	survivors, pruned, err := cm.SimulateDecay("u1", 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if survivors != want || pruned != len(saliences)-want {
+		t.Errorf("expected %d survivors and %d pruned, got %d and %d", want, len(saliences)-want, survivors, pruned)
+	}
+	if want == 0 || want == len(saliences) {
+		t.Fatalf("distribution should split at 90 days, got %d survivors", want)
+	}
+
+	// Simulating is read-only
+	if mems, _ := cm.ListRecent("u1", 10, nil); len(mems) != len(saliences) {
+		t.Errorf("expected all %d memories untouched, got %d", len(saliences), len(mems))
+	}
+	if _, _, err := cm.SimulateDecay("u1", -1); err == nil {
+		t.Error("expected an error for negative days")
+	}
+}
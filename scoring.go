@@ -2,7 +2,9 @@ package engram
 
 import (
 	"math"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // --- Composite scoring ---
@@ -16,6 +18,35 @@ func CompositeScore(similarity, salience, daysSinceAccess, linkWeight, sectorWei
 	return raw * sectorWeight
 }
 
+// --- Presentation scales ---
+
+// NormalizeToScale maps results' composite scores onto [0, max] relative to
+// the best result, which maps to max. Composite scores can exceed 1 (sector
+// weights and boosts multiply them), so they are scaled by the top score
+// rather than clamped. Negative scores map to 0; if no score is positive,
+// every value is 0. The returned slice parallels results.
+func NormalizeToScale(results []SearchResult, max float64) []float64 {
+	top := 0.0
+	for _, r := range results {
+		top = math.Max(top, r.CompositeScore)
+	}
+	scaled := make([]float64, len(results))
+	if top <= 0 {
+		return scaled
+	}
+	for i, r := range results {
+		scaled[i] = math.Max(0, r.CompositeScore) / top * max
+	}
+	return scaled
+}
+
+// RelevanceBand rates the result 0-5 on its own, e.g. for a dashboard or the
+// comparison harness's rubric: the composite score, clamped to [0, 1] (it can
+// exceed 1 with sector weights and boosts), times 5, rounded.
+func (r SearchResult) RelevanceBand() int {
+	return int(math.Round(5 * math.Min(1, math.Max(0, r.CompositeScore))))
+}
+
 // --- Cosine similarity ---
 
 // CosineSimilarity computes the cosine similarity between two float32 vectors.
@@ -38,6 +69,134 @@ func CosineSimilarity(a, b []float32) float64 {
 	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
+// vectorNorm returns the L2 norm of v.
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+// cosineWithNorms is CosineSimilarity with both norms precomputed, so scoring
+// many stored vectors against one query costs a dot product each.
+func cosineWithNorms(a []float32, normA float64, b []float32, normB float64) float64 {
+	if len(a) != len(b) || len(a) == 0 || normA == 0 || normB == 0 {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot / (normA * normB)
+}
+
+// --- Similarity metrics ---
+
+// SimilarityMetric selects how query and memory vectors are compared.
+type SimilarityMetric string
+
+const (
+	MetricCosine    SimilarityMetric = "cosine"    // Angle only (default)
+	MetricDot       SimilarityMetric = "dot"       // Un-normalized dot product
+	MetricEuclidean SimilarityMetric = "euclidean" // Negative L2 distance
+)
+
+// DotProduct returns the raw dot product of two vectors (0 on length mismatch).
+func DotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// NegativeEuclidean returns the negated L2 distance between two vectors, so
+// that closer vectors score higher. Returns -Inf on length mismatch.
+func NegativeEuclidean(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return math.Inf(-1)
+	}
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return -math.Sqrt(sum)
+}
+
+// Similarity compares two vectors with the given metric, mapped onto a range
+// comparable to cosine so CompositeScore's similarity weight means the same
+// thing for every metric. Ranking order is preserved:
+//
+//	cosine    → [-1, 1] as-is
+//	dot       → tanh(dot), in (-1, 1)
+//	euclidean → 1 / (1 + distance), in (0, 1]
+func Similarity(metric SimilarityMetric, a, b []float32) float64 {
+	switch metric {
+	case MetricDot:
+		if len(a) != len(b) || len(a) == 0 {
+			return 0
+		}
+		return math.Tanh(DotProduct(a, b))
+	case MetricEuclidean:
+		if len(a) != len(b) || len(a) == 0 {
+			return 0
+		}
+		return 1 / (1 - NegativeEuclidean(a, b))
+	default:
+		return CosineSimilarity(a, b)
+	}
+}
+
+// similarityWithNorms is Similarity with the vectors' norms supplied, which
+// cosine uses to skip recomputing them. Other metrics ignore the norms.
+func similarityWithNorms(metric SimilarityMetric, a []float32, normA float64, b []float32, normB float64) float64 {
+	switch metric {
+	case MetricDot, MetricEuclidean:
+		return Similarity(metric, a, b)
+	default:
+		return cosineWithNorms(a, normA, b, normB)
+	}
+}
+
+// --- Lexical similarity ---
+
+// LexicalSimilarity scores keyword overlap as the fraction of the query's
+// distinct words (3+ characters, case-insensitive) that appear in the content.
+// Used as a degraded stand-in for cosine similarity when embeddings are unavailable.
+func LexicalSimilarity(query, content string) float64 {
+	queryWords := wordSet(query)
+	if len(queryWords) == 0 {
+		return 0
+	}
+	contentWords := wordSet(content)
+	matched := 0
+	for w := range queryWords {
+		if contentWords[w] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(queryWords))
+}
+
+// wordSet splits text into a set of lowercase words of 3+ characters.
+func wordSet(text string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		if len(w) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
 // --- Decay ---
 
 // DecayFactor computes the exponential decay multiplier for a memory.
@@ -49,7 +208,16 @@ func DecayFactor(lambda, daysSinceAccess, salience float64) float64 {
 	return math.Exp(-lambda * daysSinceAccess / (salience + 0.1))
 }
 
-// DaysSince computes fractional days between a past time and now.
+// DaysSince computes fractional days between a past time and the wall-clock
+// now. It ignores Config.Clock, so the engine itself always uses DaysBetween
+// with the configured clock's time.
 func DaysSince(t time.Time) float64 {
-	return time.Since(t).Hours() / 24.0
+	return DaysBetween(t, time.Now())
+}
+
+// DaysBetween computes fractional days from t to now, for callers with their
+// own notion of the current time (see Clock). Every recency and decay
+// calculation in the engine goes through it.
+func DaysBetween(t, now time.Time) float64 {
+	return now.Sub(t).Hours() / 24.0
 }
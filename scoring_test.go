@@ -2,6 +2,8 @@ package engram
 
 import (
 	"math"
+	"math/rand"
+	"sort"
 	"testing"
 	"time"
 )
@@ -130,3 +132,171 @@ func TestDaysSince(t *testing.T) {
 		t.Errorf("expected ~2.0 days, got %.3f", days)
 	}
 }
+
+func TestLexicalSimilarity(t *testing.T) {
+	if s := LexicalSimilarity("Jazz records", "She collects old jazz records"); s != 1.0 {
+		t.Errorf("expected full overlap 1.0, got %.2f", s)
+	}
+	if s := LexicalSimilarity("jazz festival", "jazz bar"); s != 0.5 {
+		t.Errorf("expected half overlap 0.5, got %.2f", s)
+	}
+	if s := LexicalSimilarity("a an", "anything"); s != 0 {
+		t.Errorf("expected 0 for query with no scorable words, got %.2f", s)
+	}
+}
+
+func TestSimilarityMetricRankings(t *testing.T) {
+	query := []float32{1, 0}
+	candidates := map[string][]float32{
+		"large": {3, 3},   // long vector, 45° off
+		"near":  {1, 0.1}, // closest point, slightly off-axis
+		"short": {0.5, 0}, // exact direction, short
+	}
+
+	rank := func(metric SimilarityMetric) []string {
+		names := []string{"large", "near", "short"}
+		sort.Slice(names, func(i, j int) bool {
+			return Similarity(metric, query, candidates[names[i]]) > Similarity(metric, query, candidates[names[j]])
+		})
+		return names
+	}
+
+	tests := []struct {
+		metric SimilarityMetric
+		want   []string
+	}{
+		{MetricCosine, []string{"short", "near", "large"}},
+		{MetricDot, []string{"large", "near", "short"}},
+		{MetricEuclidean, []string{"near", "short", "large"}},
+	}
+	for _, tt := range tests {
+		got := rank(tt.metric)
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected ranking %v, got %v", tt.metric, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSimilarityMetricRanges(t *testing.T) {
+	a := []float32{40, 30}
+	b := []float32{-40, -30}
+	for _, metric := range []SimilarityMetric{MetricCosine, MetricDot, MetricEuclidean} {
+		for _, pair := range [][2][]float32{{a, a}, {a, b}} {
+			s := Similarity(metric, pair[0], pair[1])
+			if s < -1 || s > 1 || math.IsNaN(s) {
+				t.Errorf("%s: expected score in [-1, 1], got %f", metric, s)
+			}
+		}
+	}
+	if s := Similarity(MetricEuclidean, a, a); s != 1 {
+		t.Errorf("identical vectors should score 1 under euclidean, got %f", s)
+	}
+	if s := Similarity(MetricDot, []float32{1}, []float32{1, 2}); s != 0 {
+		t.Errorf("length mismatch should score 0, got %f", s)
+	}
+}
+
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	r := rand.New(rand.NewSource(seed))
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		vecs[i] = make([]float32, dim)
+		for j := range vecs[i] {
+			vecs[i][j] = float32(r.NormFloat64())
+		}
+	}
+	return vecs
+}
+
+func TestCosineWithNormsMatchesCosineSimilarity(t *testing.T) {
+	vecs := randomVectors(50, 768, 1)
+	query := vecs[0]
+	queryNorm := vectorNorm(query)
+	for i, v := range vecs {
+		want := CosineSimilarity(query, v)
+		got := similarityWithNorms(MetricCosine, query, queryNorm, v, vectorNorm(v))
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("vector %d: expected %.12f, got %.12f", i, want, got)
+		}
+	}
+	if got := cosineWithNorms([]float32{0, 0}, 0, []float32{1, 0}, 1); got != 0 {
+		t.Errorf("expected 0 for a zero vector, got %v", got)
+	}
+}
+
+func TestStoredVectorNormMatchesSearch(t *testing.T) {
+	s := testStore(t)
+	vec := []float32{3, 4, 0}
+	id, _ := s.InsertMemory(Memory{Content: "m", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "m"})
+	s.InsertVector(id, SectorSemantic, vec)
+
+	mwvs, err := s.GetMemoriesWithVectors("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mwvs) != 1 || mwvs[0].Norm != 5 {
+		t.Fatalf("expected cached norm 5, got %v", mwvs)
+	}
+
+	// Vectors stored before the norm column get it computed on load
+	s.db.Exec(`UPDATE vectors SET norm = NULL`)
+	mwvs, _ = s.GetMemoriesWithVectors("u1")
+	if mwvs[0].Norm != 5 {
+		t.Errorf("expected norm computed for a NULL column, got %v", mwvs[0].Norm)
+	}
+}
+
+func BenchmarkCosineSimilarity(b *testing.B) {
+	vecs := randomVectors(500, 768, 1)
+	query := vecs[0]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range vecs {
+			CosineSimilarity(query, v)
+		}
+	}
+}
+
+func BenchmarkCosineWithNorms(b *testing.B) {
+	vecs := randomVectors(500, 768, 1)
+	norms := make([]float64, len(vecs))
+	for i, v := range vecs {
+		norms[i] = vectorNorm(v)
+	}
+	query := vecs[0]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		queryNorm := vectorNorm(query)
+		for j, v := range vecs {
+			cosineWithNorms(query, queryNorm, v, norms[j])
+		}
+	}
+}
+
+func TestNormalizeToScale(t *testing.T) {
+	results := []SearchResult{{CompositeScore: 1.5}, {CompositeScore: 0.75}, {CompositeScore: 0}}
+	scaled := NormalizeToScale(results, 5)
+	for i, want := range []float64{5, 2.5, 0} {
+		if math.Abs(scaled[i]-want) > 1e-9 {
+			t.Errorf("result %d: expected %.2f, got %.2f", i, want, scaled[i])
+		}
+	}
+
+	if zero := NormalizeToScale([]SearchResult{{CompositeScore: 0}, {CompositeScore: -0.2}}, 5); zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("expected all zeros without a positive score, got %v", zero)
+	}
+}
+
+func TestRelevanceBand(t *testing.T) {
+	for _, tc := range []struct {
+		composite float64
+		want      int
+	}{{1.5, 5}, {1.0, 5}, {0.5, 3}, {0.29, 1}, {0, 0}, {-0.3, 0}} {
+		if got := (SearchResult{CompositeScore: tc.composite}).RelevanceBand(); got != tc.want {
+			t.Errorf("composite %.2f: expected band %d, got %d", tc.composite, tc.want, got)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package engram
 import (
 	"database/sql"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,7 +17,13 @@ import (
 
 // Store wraps a SQLite connection for cognitive memory persistence.
 type Store struct {
-	db *sql.DB
+	db    *sql.DB
+	rdb   *sql.DB // read-only replica connection for search reads (nil = use db)
+	path  string
+	clock Clock // time source for stored timestamps and decay (default: system clock)
+
+	arousalDecay float64 // Config.EmotionalArousalDecay, applied by the decay sweep
+	deleteBatch  int     // Config.DecayDeleteBatch, rows per sweep DELETE (0 = default)
 }
 
 // NewStore opens (or creates) the SQLite database and runs migrations.
@@ -25,7 +33,9 @@ func NewStore(path string) (*Store, error) {
 		return nil, fmt.Errorf("engram: mkdir %s: %w", filepath.Dir(path), err)
 	}
 
-	db, err := sql.Open("sqlite", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	// foreign_keys is per connection, so set it in the DSN for every
+	// connection the pool opens rather than once after migrating
+	db, err := sql.Open("sqlite", path+"?_journal_mode=WAL&_busy_timeout=5000&_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, fmt.Errorf("engram: open db: %w", err)
 	}
@@ -33,7 +43,7 @@ func NewStore(path string) (*Store, error) {
 	// Single connection avoids write contention for our scale
 	db.SetMaxOpenConns(1)
 
-	s := &Store{db: db}
+	s := &Store{db: db, path: path, clock: realClock{}}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("engram: migrate: %w", err)
@@ -41,88 +51,93 @@ func NewStore(path string) (*Store, error) {
 	return s, nil
 }
 
-func (s *Store) migrate() error {
-	// Version tracking
-	s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`)
-
-	var version int
-	s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
-
-	if version < 1 {
-		if _, err := s.db.Exec(`
-			CREATE TABLE IF NOT EXISTS memories (
-				id              INTEGER PRIMARY KEY AUTOINCREMENT,
-				content         TEXT    NOT NULL,
-				sector          TEXT    NOT NULL DEFAULT 'semantic',
-				salience        REAL    NOT NULL DEFAULT 0.5,
-				decay_score     REAL    NOT NULL DEFAULT 0.5,
-				last_accessed_at TEXT   NOT NULL DEFAULT (datetime('now')),
-				access_count    INTEGER NOT NULL DEFAULT 0,
-				created_at      TEXT    NOT NULL DEFAULT (datetime('now')),
-				summary         TEXT    NOT NULL DEFAULT '',
-				user_id         TEXT    NOT NULL
-			);
-			CREATE INDEX IF NOT EXISTS idx_memories_user_id ON memories(user_id);
-			CREATE INDEX IF NOT EXISTS idx_memories_sector  ON memories(sector);
-
-			CREATE TABLE IF NOT EXISTS vectors (
-				id              INTEGER PRIMARY KEY AUTOINCREMENT,
-				memory_id       INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
-				sector          TEXT    NOT NULL,
-				vector          BLOB    NOT NULL,
-				embedding_model TEXT    NOT NULL DEFAULT 'gemini-embedding-001'
-			);
-			CREATE INDEX IF NOT EXISTS idx_vectors_memory_id ON vectors(memory_id);
-
-			CREATE TABLE IF NOT EXISTS waypoints (
-				id          INTEGER PRIMARY KEY AUTOINCREMENT,
-				entity_text TEXT NOT NULL UNIQUE,
-				entity_type TEXT NOT NULL DEFAULT 'unknown'
-			);
-			CREATE INDEX IF NOT EXISTS idx_waypoints_entity ON waypoints(entity_text);
-
-			CREATE TABLE IF NOT EXISTS associations (
-				id          INTEGER PRIMARY KEY AUTOINCREMENT,
-				memory_id   INTEGER NOT NULL REFERENCES memories(id) ON DELETE CASCADE,
-				waypoint_id INTEGER NOT NULL REFERENCES waypoints(id) ON DELETE CASCADE,
-				weight      REAL    NOT NULL DEFAULT 0.5,
-				UNIQUE(memory_id, waypoint_id)
-			);
-			CREATE INDEX IF NOT EXISTS idx_assoc_memory   ON associations(memory_id);
-			CREATE INDEX IF NOT EXISTS idx_assoc_waypoint ON associations(waypoint_id);
-
-			PRAGMA foreign_keys = ON;
-		`); err != nil {
-			return err
-		}
-		s.db.Exec(`INSERT INTO schema_version (version) VALUES (1)`)
-	}
-
-	if version < 2 {
-		// Phase 3: temporal columns
-		s.db.Exec(`ALTER TABLE memories ADD COLUMN session_id TEXT NOT NULL DEFAULT ''`)
-		s.db.Exec(`ALTER TABLE memories ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0`)
-		s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_session ON memories(session_id)`)
-		s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_created ON memories(created_at)`)
-		s.db.Exec(`INSERT INTO schema_version (version) VALUES (2)`)
+// OpenReadReplica opens a second, read-only connection to the database for
+// the reads behind Search (candidates, vectors, waypoint and entity links), so
+// they don't queue behind writes on the single writer connection. It switches
+// the database to WAL mode, which lets readers proceed while a write is held.
+// Writes, reinforcement included, still go through the writer.
+func (s *Store) OpenReadReplica() error {
+	if s.rdb != nil {
+		return nil
+	}
+	var mode string
+	if err := s.db.QueryRow(`PRAGMA journal_mode = WAL`).Scan(&mode); err != nil {
+		return fmt.Errorf("engram: enable WAL: %w", err)
+	}
+	if mode != "wal" {
+		return fmt.Errorf("engram: read replica needs WAL mode, database is in %s mode", mode)
+	}
+	if _, err := s.db.Exec(`PRAGMA busy_timeout = 5000`); err != nil {
+		return fmt.Errorf("engram: set busy timeout: %w", err)
 	}
 
+	rdb, err := sql.Open("sqlite", "file:"+s.path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return fmt.Errorf("engram: open read replica: %w", err)
+	}
+	rdb.SetMaxOpenConns(1)
+	if err := rdb.Ping(); err != nil {
+		rdb.Close()
+		return fmt.Errorf("engram: open read replica: %w", err)
+	}
+	s.rdb = rdb
 	return nil
 }
 
+// reader returns the connection for search reads: the read replica if open,
+// else the writer.
+func (s *Store) reader() *sql.DB {
+	if s.rdb != nil {
+		return s.rdb
+	}
+	return s.db
+}
+
+// timestamp formats the store clock's current time the way SQLite's
+// datetime('now') does, so stored values compare correctly as strings.
+func (s *Store) timestamp() string {
+	return s.clock.Now().UTC().Format("2006-01-02 15:04:05")
+}
+
 // --- Vector encoding ---
 
-// EncodeVector converts a float32 slice to a little-endian byte blob.
+// Vector blobs start with a one-byte format tag so new encodings (float16,
+// quantized, compressed) can coexist with stored data. Blobs written before
+// tagging were bare little-endian float32; migration 20 prefixes them with
+// vectorFormatFloat32, so every stored blob carries a tag.
+const (
+	vectorFormatFloat32 byte = 1 // little-endian float32 per element
+)
+
+// EncodeVector converts a float32 slice to a tagged little-endian byte blob.
 func EncodeVector(v []float32) []byte {
-	buf := make([]byte, len(v)*4)
+	buf := make([]byte, 1+len(v)*4)
+	buf[0] = vectorFormatFloat32
+	putFloat32s(buf[1:], v)
+	return buf
+}
+
+// DecodeVector converts a blob from EncodeVector back to a float32 slice. A
+// blob with an unknown format tag decodes to nil.
+func DecodeVector(b []byte) []float32 {
+	if len(b) == 0 {
+		return nil
+	}
+	switch b[0] {
+	case vectorFormatFloat32:
+		return float32s(b[1:])
+	default:
+		return nil
+	}
+}
+
+func putFloat32s(buf []byte, v []float32) {
 	for i, f := range v {
 		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
 	}
-	return buf
 }
 
-// DecodeVector converts a little-endian byte blob back to a float32 slice.
-func DecodeVector(b []byte) []float32 {
+func float32s(b []byte) []float32 {
 	v := make([]float32, len(b)/4)
 	for i := range v {
 		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
@@ -132,12 +147,20 @@ func DecodeVector(b []byte) []float32 {
 
 // --- Memory CRUD ---
 
-// InsertMemory stores a new memory row and returns its ID.
+// InsertMemory stores a new memory row and returns its ID. A zero Confidence
+// is stored as 1 (certain).
 func (s *Store) InsertMemory(m Memory) (int64, error) {
+	now := s.timestamp()
 	res, err := s.db.Exec(`
-		INSERT INTO memories (content, sector, salience, decay_score, summary, user_id, session_id, parent_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO memories (content, sector, salience, decay_score, summary, user_id, session_id, parent_id,
+		                      user_message, assistant_message, valence, media_refs, idempotency_key,
+		                      decay_lambda, arousal, confidence, reflection_window, window_start, window_end,
+		                      classify_confidence, created_at, last_accessed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, 0), ?, COALESCE(NULLIF(?, 0), 1), ?, ?, ?, ?, ?, ?)`,
 		m.Content, string(m.Sector), m.Salience, m.Salience, m.Summary, m.UserID, m.SessionID, m.ParentID,
+		m.UserMessage, m.AssistantMessage, m.Valence, encodeMediaRefs(m.MediaRefs), m.IdempotencyKey,
+		m.DecayLambda, m.Arousal, m.Confidence, m.ReflectionWindow, formatOptionalTime(m.WindowStart), formatOptionalTime(m.WindowEnd),
+		m.ClassifyConfidence, now, now,
 	)
 	if err != nil {
 		return 0, err
@@ -145,58 +168,204 @@ func (s *Store) InsertMemory(m Memory) (int64, error) {
 	return res.LastInsertId()
 }
 
+// formatOptionalTime formats t as a column timestamp, or "" if t is zero.
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// GetMemoryIDByIdempotencyKey returns the ID of the user's memory stored with
+// key, or 0 if there is none.
+func (s *Store) GetMemoryIDByIdempotencyKey(userID, key string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		SELECT id FROM memories WHERE user_id = ? AND idempotency_key = ?`,
+		userID, key,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
 // InsertVector stores an embedding blob linked to a memory.
 func (s *Store) InsertVector(memoryID int64, sector Sector, vec []float32) error {
 	_, err := s.db.Exec(`
-		INSERT INTO vectors (memory_id, sector, vector) VALUES (?, ?, ?)`,
-		memoryID, string(sector), EncodeVector(vec),
+		INSERT INTO vectors (memory_id, sector, vector, norm) VALUES (?, ?, ?, ?)`,
+		memoryID, string(sector), EncodeVector(vec), vectorNorm(vec),
 	)
 	return err
 }
 
+// ReplaceVector swaps a memory's stored vector(s) for vec, e.g. after
+// re-embedding with a different model.
+func (s *Store) ReplaceVector(memoryID int64, sector Sector, vec []float32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM vectors WHERE memory_id = ?`, memoryID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO vectors (memory_id, sector, vector, norm) VALUES (?, ?, ?, ?)`,
+		memoryID, string(sector), EncodeVector(vec), vectorNorm(vec),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // memoryWithVector pairs a Memory with its embedding for scoring.
 type memoryWithVector struct {
 	Memory
 	Vector []float32
+	Norm   float64 // L2 norm of Vector, stored alongside it (0 = none loaded)
 }
 
-// scanMemory scans a memory row including temporal columns.
-func scanMemory(rows *sql.Rows, vecBlob *[]byte) (memoryWithVector, error) {
-	var mwv memoryWithVector
-	var lastAccessed, created string
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	if err := rows.Scan(
-		&mwv.ID, &mwv.Content, &mwv.Sector, &mwv.Salience, &mwv.DecayScore,
-		&lastAccessed, &mwv.AccessCount, &created, &mwv.Summary, &mwv.UserID,
-		&mwv.SessionID, &mwv.ParentID,
-		vecBlob,
-	); err != nil {
-		return mwv, err
+// scanMemoryInto scans the memorySelectCols columns into m, followed by any
+// extra destinations for columns selected after them.
+func scanMemoryInto(row rowScanner, m *Memory, extra ...any) error {
+	var lastAccessed, created, mediaRefs, windowStart, windowEnd string
+	var classifyConfidence sql.NullFloat64
+	dest := []any{
+		&m.ID, &m.Content, &m.Sector, &m.Salience, &m.DecayScore,
+		&lastAccessed, &m.AccessCount, &created, &m.Summary, &m.UserID,
+		&m.SessionID, &m.ParentID, &m.UserMessage, &m.AssistantMessage, &m.Valence,
+		&mediaRefs, &m.IdempotencyKey, &m.DecayLambda, &m.Arousal,
+		&m.Confidence, &m.ReflectionWindow, &windowStart, &windowEnd, &classifyConfidence,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
 	}
+	m.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
+	m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
+	m.WindowStart, _ = time.Parse("2006-01-02 15:04:05", windowStart)
+	m.WindowEnd, _ = time.Parse("2006-01-02 15:04:05", windowEnd)
+	if classifyConfidence.Valid {
+		m.ClassifyConfidence = &classifyConfidence.Float64
+	}
+	if mediaRefs != "" {
+		if err := json.Unmarshal([]byte(mediaRefs), &m.MediaRefs); err != nil {
+			return fmt.Errorf("engram: decode media refs for memory #%d: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// encodeMediaRefs serializes refs for the media_refs column ("" = none).
+func encodeMediaRefs(refs []MediaRef) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(refs) // plain string fields: cannot fail
+	return string(data)
+}
 
-	mwv.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
-	mwv.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
-	if *vecBlob != nil {
-		mwv.Vector = DecodeVector(*vecBlob)
+// scanMemory scans a memory row followed by its vector blob.
+func scanMemory(rows *sql.Rows) (memoryWithVector, error) {
+	var mwv memoryWithVector
+	var vecBlob []byte
+	var norm sql.NullFloat64
+	if err := scanMemoryInto(rows, &mwv.Memory, &vecBlob, &norm); err != nil {
+		return mwv, err
+	}
+	if vecBlob != nil {
+		mwv.Vector = DecodeVector(vecBlob)
+		mwv.Norm = norm.Float64
+		if !norm.Valid {
+			mwv.Norm = vectorNorm(mwv.Vector)
+		}
 	}
 	return mwv, nil
 }
 
+// scanMemories collects memorySelectCols rows into a slice, closing rows.
+func scanMemories(rows *sql.Rows) ([]Memory, error) {
+	defer rows.Close()
+
+	var results []Memory
+	for rows.Next() {
+		var m Memory
+		if err := scanMemoryInto(rows, &m); err != nil {
+			return nil, err
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
 const memorySelectCols = `m.id, m.content, m.sector, m.salience, m.decay_score,
 	m.last_accessed_at, m.access_count, m.created_at, m.summary, m.user_id,
-	m.session_id, m.parent_id`
+	m.session_id, m.parent_id, m.user_message, m.assistant_message, m.valence,
+	m.media_refs, COALESCE(m.idempotency_key, ''), COALESCE(m.decay_lambda, 0), m.arousal,
+	m.confidence, m.reflection_window, m.window_start, m.window_end, m.classify_confidence`
 
 // GetMemoriesWithVectors loads all memories (with vectors) for a given user.
 // At NPC scale (~50-500 per user) this is fast enough to score in Go.
 func (s *Store) GetMemoriesWithVectors(userID string) ([]memoryWithVector, error) {
-	rows, err := s.db.Query(`
-		SELECT `+memorySelectCols+`, v.vector
-		FROM memories m
-		LEFT JOIN vectors v ON v.memory_id = m.id
-		WHERE m.user_id = ?
-		ORDER BY m.created_at DESC`,
-		userID,
-	)
+	return s.loadCandidates(userID, 0, true)
+}
+
+// GetCandidateMemoriesWithVectors is GetMemoriesWithVectors bounded to at
+// most limit rows: the most recent limit-limit/2 plus the limit/2 most
+// salient of the rest, so old but important memories stay reachable.
+// limit <= 0 loads all.
+func (s *Store) GetCandidateMemoriesWithVectors(userID string, limit int) ([]memoryWithVector, error) {
+	return s.loadCandidates(userID, limit, true)
+}
+
+// GetCandidateMemories is GetCandidateMemoriesWithVectors without the vector
+// blobs (Vector is nil), for callers that already hold the vectors.
+func (s *Store) GetCandidateMemories(userID string, limit int) ([]memoryWithVector, error) {
+	return s.loadCandidates(userID, limit, false)
+}
+
+func (s *Store) loadCandidates(userID string, limit int, withVectors bool) ([]memoryWithVector, error) {
+	vectorCol, join := "NULL, NULL", ""
+	if withVectors {
+		vectorCol, join = "v.vector, v.norm", "LEFT JOIN vectors v ON v.memory_id = m.id"
+	}
+
+	var rows *sql.Rows
+	var err error
+	if limit <= 0 {
+		rows, err = s.reader().Query(`
+			SELECT `+memorySelectCols+`, `+vectorCol+`
+			FROM memories m
+			`+join+`
+			WHERE m.user_id = ? AND m.archived_at IS NULL
+			ORDER BY m.created_at DESC`,
+			userID,
+		)
+	} else {
+		rows, err = s.reader().Query(`
+			WITH recent AS (
+				SELECT id FROM memories
+				WHERE user_id = ? AND archived_at IS NULL
+				ORDER BY created_at DESC, id DESC LIMIT ?
+			), salient AS (
+				SELECT id FROM memories
+				WHERE user_id = ? AND archived_at IS NULL AND id NOT IN (SELECT id FROM recent)
+				ORDER BY salience DESC, id DESC LIMIT ?
+			)
+			SELECT `+memorySelectCols+`, `+vectorCol+`
+			FROM memories m
+			`+join+`
+			WHERE m.id IN (SELECT id FROM recent UNION ALL SELECT id FROM salient)
+			ORDER BY m.created_at DESC`,
+			userID, limit-limit/2, userID, limit/2,
+		)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -204,8 +373,7 @@ func (s *Store) GetMemoriesWithVectors(userID string) ([]memoryWithVector, error
 
 	var results []memoryWithVector
 	for rows.Next() {
-		var vecBlob []byte
-		mwv, err := scanMemory(rows, &vecBlob)
+		mwv, err := scanMemory(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -214,6 +382,39 @@ func (s *Store) GetMemoriesWithVectors(userID string) ([]memoryWithVector, error
 	return results, rows.Err()
 }
 
+// GetVectors returns the stored vectors for the given memory IDs. IDs with
+// no vector are absent from the result.
+func (s *Store) GetVectors(ids []int64) (map[int64][]float32, error) {
+	vecs := make(map[int64][]float32, len(ids))
+	if len(ids) == 0 {
+		return vecs, nil
+	}
+	placeholders := strings.Repeat("?,", len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.reader().Query(`
+		SELECT memory_id, vector FROM vectors
+		WHERE memory_id IN (`+placeholders[:len(placeholders)-1]+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		vecs[id] = DecodeVector(blob)
+	}
+	return vecs, rows.Err()
+}
+
 // --- Temporal queries ---
 
 // GetSessionMemories returns all memories for a session, ordered by creation time.
@@ -221,31 +422,86 @@ func (s *Store) GetSessionMemories(sessionID string) ([]Memory, error) {
 	rows, err := s.db.Query(`
 		SELECT `+memorySelectCols+`
 		FROM memories m
-		WHERE m.session_id = ?
-		ORDER BY m.created_at ASC`,
+		WHERE m.session_id = ? AND m.archived_at IS NULL
+		ORDER BY m.created_at ASC, m.id ASC`,
 		sessionID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanMemories(rows)
+}
 
-	var results []Memory
-	for rows.Next() {
-		var m Memory
-		var lastAccessed, created string
-		if err := rows.Scan(
-			&m.ID, &m.Content, &m.Sector, &m.Salience, &m.DecayScore,
-			&lastAccessed, &m.AccessCount, &created, &m.Summary, &m.UserID,
-			&m.SessionID, &m.ParentID,
-		); err != nil {
-			return nil, err
-		}
-		m.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
-		m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
-		results = append(results, m)
+// GetThread walks ParentID links from a memory back to its root and returns
+// the chain oldest first, omitting archived memories.
+func (s *Store) GetThread(memoryID int64) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		WITH RECURSIVE chain(id, depth) AS (
+			SELECT id, 0 FROM memories WHERE id = ?
+			UNION ALL
+			SELECT m.parent_id, c.depth + 1
+			FROM memories m JOIN chain c ON m.id = c.id
+			WHERE m.parent_id != 0 AND c.depth < 10000
+		)
+		SELECT `+memorySelectCols+`
+		FROM chain
+		JOIN memories m ON m.id = chain.id
+		WHERE m.archived_at IS NULL
+		ORDER BY chain.depth DESC`,
+		memoryID,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return results, rows.Err()
+	return scanMemories(rows)
+}
+
+// GetSessionNeighbors returns a memory together with up to n turns before it
+// (following ParentID) and n after it (following its earliest reply at each
+// step) that share its session, oldest first, omitting archived memories.
+func (s *Store) GetSessionNeighbors(memoryID int64, n int) ([]Memory, error) {
+	rows, err := s.reader().Query(`
+		WITH RECURSIVE back(id, depth) AS (
+			SELECT id, 0 FROM memories WHERE id = ?
+			UNION ALL
+			SELECT m.parent_id, b.depth + 1
+			FROM memories m JOIN back b ON m.id = b.id
+			WHERE m.parent_id != 0 AND b.depth < ?
+		), fwd(id, depth) AS (
+			SELECT id, 0 FROM memories WHERE id = ?
+			UNION ALL
+			SELECT (SELECT MIN(c.id) FROM memories c WHERE c.parent_id = f.id), f.depth + 1
+			FROM fwd f
+			WHERE f.depth < ? AND EXISTS (SELECT 1 FROM memories c WHERE c.parent_id = f.id)
+		)
+		SELECT `+memorySelectCols+`
+		FROM memories m
+		WHERE m.id IN (SELECT id FROM back UNION SELECT id FROM fwd)
+		  AND m.archived_at IS NULL
+		  AND m.session_id = (SELECT session_id FROM memories WHERE id = ?)
+		ORDER BY m.created_at ASC, m.id ASC`,
+		memoryID, n, memoryID, n, memoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemories(rows)
+}
+
+// GetChildren returns the direct replies to a memory (those whose ParentID is
+// parentID), oldest first, omitting archived memories.
+func (s *Store) GetChildren(parentID int64) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		SELECT `+memorySelectCols+`
+		FROM memories m
+		WHERE m.parent_id = ? AND m.archived_at IS NULL
+		ORDER BY m.created_at ASC, m.id ASC`,
+		parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemories(rows)
 }
 
 // GetMemoriesInTimeWindow returns memories for a user within a time range.
@@ -253,7 +509,8 @@ func (s *Store) GetMemoriesInTimeWindow(userID string, after, before time.Time)
 	rows, err := s.db.Query(`
 		SELECT `+memorySelectCols+`
 		FROM memories m
-		WHERE m.user_id = ? AND m.created_at >= ? AND m.created_at <= ?
+		WHERE m.user_id = ? AND m.archived_at IS NULL
+		  AND m.created_at >= ? AND m.created_at <= ?
 		ORDER BY m.created_at DESC`,
 		userID,
 		after.Format("2006-01-02 15:04:05"),
@@ -262,29 +519,12 @@ func (s *Store) GetMemoriesInTimeWindow(userID string, after, before time.Time)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var results []Memory
-	for rows.Next() {
-		var m Memory
-		var lastAccessed, created string
-		if err := rows.Scan(
-			&m.ID, &m.Content, &m.Sector, &m.Salience, &m.DecayScore,
-			&lastAccessed, &m.AccessCount, &created, &m.Summary, &m.UserID,
-			&m.SessionID, &m.ParentID,
-		); err != nil {
-			return nil, err
-		}
-		m.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
-		m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
-		results = append(results, m)
-	}
-	return results, rows.Err()
+	return scanMemories(rows)
 }
 
 // GetRecentMemories returns the N most recent memories for a user, optionally filtered by sectors.
 func (s *Store) GetRecentMemories(userID string, limit int, sectors []Sector) ([]Memory, error) {
-	query := `SELECT ` + memorySelectCols + ` FROM memories m WHERE m.user_id = ?`
+	query := `SELECT ` + memorySelectCols + ` FROM memories m WHERE m.user_id = ? AND m.archived_at IS NULL`
 	args := []any{userID}
 
 	if len(sectors) > 0 {
@@ -303,135 +543,536 @@ func (s *Store) GetRecentMemories(userID string, limit int, sectors []Sector) ([
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanMemories(rows)
+}
 
-	var results []Memory
-	for rows.Next() {
-		var m Memory
-		var lastAccessed, created string
-		if err := rows.Scan(
-			&m.ID, &m.Content, &m.Sector, &m.Salience, &m.DecayScore,
-			&lastAccessed, &m.AccessCount, &created, &m.Summary, &m.UserID,
-			&m.SessionID, &m.ParentID,
-		); err != nil {
-			return nil, err
+// GetMostSalientMemories returns up to limit of a user's non-reflective
+// memories, most salient first (newest first among equals), optionally
+// filtered by sectors, omitting archived memories.
+func (s *Store) GetMostSalientMemories(userID string, limit int, sectors []Sector) ([]Memory, error) {
+	query := `SELECT ` + memorySelectCols + ` FROM memories m
+		WHERE m.user_id = ? AND m.archived_at IS NULL AND m.sector != ?`
+	args := []any{userID, string(SectorReflective)}
+
+	if len(sectors) > 0 {
+		placeholders := make([]string, len(sectors))
+		for i, sec := range sectors {
+			placeholders[i] = "?"
+			args = append(args, string(sec))
 		}
-		m.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
-		m.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
-		results = append(results, m)
+		query += ` AND m.sector IN (` + strings.Join(placeholders, ",") + `)`
 	}
-	return results, rows.Err()
-}
 
-// GetLastSessionID returns the most recent session_id for a user.
-func (s *Store) GetLastSessionID(userID string) (string, error) {
-	var sessionID string
-	err := s.db.QueryRow(`
-		SELECT session_id FROM memories
-		WHERE user_id = ? AND session_id != ''
-		ORDER BY created_at DESC LIMIT 1`,
-		userID,
-	).Scan(&sessionID)
-	if err == sql.ErrNoRows {
-		return "", nil
-	}
-	return sessionID, err
-}
+	query += ` ORDER BY m.salience DESC, m.created_at DESC, m.id DESC LIMIT ?`
+	args = append(args, limit)
 
-// GetActiveUserIDs returns all distinct user IDs with stored memories.
-func (s *Store) GetActiveUserIDs() ([]string, error) {
-	rows, err := s.db.Query(`SELECT DISTINCT user_id FROM memories`)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanMemories(rows)
+}
 
-	var ids []string
-	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
-			return nil, err
-		}
-		ids = append(ids, id)
+// GetMostAccessedMemories returns up to limit of a user's memories that have
+// been recalled at least once, most recalled first, omitting archived memories.
+func (s *Store) GetMostAccessedMemories(userID string, limit int) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		SELECT `+memorySelectCols+`
+		FROM memories m
+		WHERE m.user_id = ? AND m.archived_at IS NULL AND m.access_count > 0
+		ORDER BY m.access_count DESC, m.last_accessed_at DESC, m.id ASC
+		LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return ids, rows.Err()
+	return scanMemories(rows)
 }
 
-// --- Waypoint CRUD ---
-
-// UpsertWaypoint inserts or finds a waypoint by entity text, returns its ID.
-func (s *Store) UpsertWaypoint(text, entityType string) (int64, error) {
-	_, err := s.db.Exec(`
-		INSERT INTO waypoints (entity_text, entity_type) VALUES (?, ?)
-		ON CONFLICT(entity_text) DO UPDATE SET entity_type = excluded.entity_type`,
-		text, entityType,
+// GetReflectionsByWindow returns a user's reflective memories stored with the
+// given window label, newest first, omitting archived memories.
+func (s *Store) GetReflectionsByWindow(userID, label string, limit int) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		SELECT `+memorySelectCols+`
+		FROM memories m
+		WHERE m.user_id = ? AND m.sector = ? AND m.reflection_window = ? AND m.archived_at IS NULL
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ?`,
+		userID, string(SectorReflective), label, limit,
 	)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	var id int64
-	err = s.db.QueryRow(`SELECT id FROM waypoints WHERE entity_text = ?`, text).Scan(&id)
-	return id, err
+	return scanMemories(rows)
 }
 
-// InsertAssociation links a memory to a waypoint with a weight.
-func (s *Store) InsertAssociation(memoryID, waypointID int64, weight float64) error {
-	_, err := s.db.Exec(`
-		INSERT INTO associations (memory_id, waypoint_id, weight) VALUES (?, ?, ?)
-		ON CONFLICT(memory_id, waypoint_id) DO UPDATE SET weight = MAX(weight, excluded.weight)`,
-		memoryID, waypointID, weight,
+// DeleteSectorMemoriesBefore deletes a user's memories in one sector created
+// before the cutoff. Returns the number of memories deleted.
+func (s *Store) DeleteSectorMemoriesBefore(userID string, sector Sector, before time.Time) (int, error) {
+	if err := s.stampEvents(s.db); err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(`
+		DELETE FROM memories
+		WHERE user_id = ? AND sector = ? AND created_at < ?`,
+		userID, string(sector), before.UTC().Format("2006-01-02 15:04:05"),
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
 }
 
-// GetAssociatedWaypointIDs returns waypoint IDs linked to a memory.
-func (s *Store) GetAssociatedWaypointIDs(memoryID int64) ([]int64, error) {
-	rows, err := s.db.Query(`SELECT waypoint_id FROM associations WHERE memory_id = ?`, memoryID)
+// MergeMemories folds the mergeIDs memories into keepID in one transaction:
+// the survivor takes the highest salience and decay score, the summed access
+// count and the latest access time, and the union of their waypoint
+// associations at the highest weight; with appendContent, each distinct merged
+// content is appended to its own and its summary to the survivor's. The merged
+// memories are then deleted with their vectors and associations. All must
+// belong to one user. The event log records one merge event rather than a
+// reinforce. Returns the survivor as stored.
+func (s *Store) MergeMemories(keepID int64, mergeIDs []int64, appendContent bool) (Memory, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return Memory{}, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var ids []int64
-	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			return nil, err
-		}
-		ids = append(ids, id)
+	all := append([]int64{keepID}, mergeIDs...)
+	args := make([]any, len(all))
+	for i, id := range all {
+		args[i] = id
 	}
-	return ids, rows.Err()
-}
+	in := `(` + strings.TrimSuffix(strings.Repeat("?,", len(all)), ",") + `)`
 
-// GetMemoriesByWaypoint returns memories linked to a waypoint, excluding a set of IDs.
-func (s *Store) GetMemoriesByWaypoint(waypointID int64, userID string, excludeIDs map[int64]bool) ([]memoryWithVector, error) {
-	rows, err := s.db.Query(`
-		SELECT `+memorySelectCols+`, v.vector, a.weight
-		FROM associations a
-		JOIN memories m ON m.id = a.memory_id
-		LEFT JOIN vectors v ON v.memory_id = m.id
-		WHERE a.waypoint_id = ? AND m.user_id = ?`,
-		waypointID, userID,
-	)
+	rows, err := tx.Query(`SELECT `+memorySelectCols+` FROM memories m WHERE m.id IN `+in, args...)
 	if err != nil {
-		return nil, err
+		return Memory{}, err
 	}
-	defer rows.Close()
+	mems, err := scanMemories(rows)
+	if err != nil {
+		return Memory{}, err
+	}
+	byID := make(map[int64]Memory, len(mems))
+	for _, m := range mems {
+		byID[m.ID] = m
+	}
+	keep, ok := byID[keepID]
+	if !ok {
+		return Memory{}, fmt.Errorf("memory %d not found", keepID)
+	}
+	for _, id := range mergeIDs {
+		if id == keepID {
+			return Memory{}, fmt.Errorf("memory %d cannot be merged into itself", id)
+		}
+		m, ok := byID[id]
+		if !ok {
+			return Memory{}, fmt.Errorf("memory %d not found", id)
+		}
+		if m.UserID != keep.UserID {
+			return Memory{}, fmt.Errorf("memory %d belongs to another user than memory %d", id, keepID)
+		}
+	}
+
+	content, summary := keep.Content, keep.Summary
+	if appendContent {
+		seen := map[string]bool{strings.ToLower(NormalizeWhitespace(content)): true}
+		for _, id := range mergeIDs {
+			m := byID[id]
+			if key := strings.ToLower(NormalizeWhitespace(m.Content)); !seen[key] {
+				seen[key] = true
+				content += "\n" + m.Content
+				summary += " | " + m.Summary
+			}
+		}
+	}
+
+	for _, id := range mergeIDs {
+		m := byID[id]
+		keep.Salience = max(keep.Salience, m.Salience)
+		keep.DecayScore = max(keep.DecayScore, m.DecayScore)
+		keep.AccessCount += m.AccessCount
+		if m.LastAccessedAt.After(keep.LastAccessedAt) {
+			keep.LastAccessedAt = m.LastAccessedAt
+		}
+	}
+	keep.Content = content
+	keep.Summary = truncateSummary(summary, 200)
+
+	if err := logMergeEvent(tx, keep, mergeIDs, s.timestamp()); err != nil {
+		return Memory{}, err
+	}
+	// The summed access count isn't a retrieval, so keep the reinforce
+	// trigger quiet while it is written
+	res, err := tx.Exec(`UPDATE meta SET value = '0' WHERE key = 'event_log' AND value = '1'`)
+	if err != nil {
+		return Memory{}, err
+	}
+	paused, err := res.RowsAffected()
+	if err != nil {
+		return Memory{}, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE memories
+		SET salience = ?, decay_score = ?, access_count = ?, last_accessed_at = ?, content = ?, summary = ?
+		WHERE id = ?`,
+		keep.Salience, keep.DecayScore, keep.AccessCount, keep.LastAccessedAt.Format("2006-01-02 15:04:05"), keep.Content, keep.Summary, keepID,
+	); err != nil {
+		return Memory{}, err
+	}
+	if paused > 0 {
+		if _, err := tx.Exec(`UPDATE meta SET value = '1' WHERE key = 'event_log'`); err != nil {
+			return Memory{}, err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO associations (memory_id, waypoint_id, weight)
+		SELECT ?, waypoint_id, MAX(weight) FROM associations
+		WHERE memory_id IN `+in+`
+		GROUP BY waypoint_id
+		ON CONFLICT(memory_id, waypoint_id) DO UPDATE SET weight = MAX(weight, excluded.weight)`,
+		append([]any{keepID}, args...)...,
+	); err != nil {
+		return Memory{}, err
+	}
+
+	if err := s.stampEvents(tx); err != nil {
+		return Memory{}, err
+	}
+	if _, err := deleteMemoriesByID(tx, mergeIDs, s.deleteBatch); err != nil {
+		return Memory{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Memory{}, err
+	}
+	return keep, nil
+}
+
+// DeleteUserMemories deletes every memory for a user, archived or not, with
+// their vectors, associations and logged events, then removes waypoints left
+// unreferenced. Returns the number of memories deleted.
+func (s *Store) DeleteUserMemories(userID string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM memories WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM events WHERE user_id = ?`, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM waypoints WHERE id NOT IN (SELECT DISTINCT waypoint_id FROM associations)`); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// GetLastSessionID returns the most recent session_id for a user.
+func (s *Store) GetLastSessionID(userID string) (string, error) {
+	return s.GetLastSessionIDSince(userID, time.Time{})
+}
+
+// GetLastSessionIDSince is GetLastSessionID ignoring sessions whose latest
+// memory is older than since, returning "" if there are none.
+func (s *Store) GetLastSessionIDSince(userID string, since time.Time) (string, error) {
+	var sessionID string
+	err := s.db.QueryRow(`
+		SELECT session_id FROM memories
+		WHERE user_id = ? AND session_id != '' AND archived_at IS NULL AND created_at >= ?
+		ORDER BY created_at DESC LIMIT 1`,
+		userID, since.UTC().Format("2006-01-02 15:04:05"),
+	).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return sessionID, err
+}
+
+// GetLastActivity returns when the user's latest memory was created (zero if
+// they have none) and whether any memory already belongs to sessionID.
+func (s *Store) GetLastActivity(userID, sessionID string) (last time.Time, sessionSeen bool, err error) {
+	var lastAt sql.NullString
+	var seen int
+	err = s.db.QueryRow(`
+		SELECT MAX(created_at), COALESCE(MAX(session_id = ?), 0)
+		FROM memories WHERE user_id = ?`,
+		sessionID, userID,
+	).Scan(&lastAt, &seen)
+	if err != nil || !lastAt.Valid {
+		return time.Time{}, false, err
+	}
+	last, _ = time.Parse("2006-01-02 15:04:05", lastAt.String)
+	return last, seen == 1, nil
+}
+
+// GetUserMemoryIDs returns the IDs of all of a user's memories, archived or
+// not.
+func (s *Store) GetUserMemoryIDs(userID string) ([]int64, error) {
+	rows, err := s.db.Query(`SELECT id FROM memories WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetAllUserMemories returns all of a user's memories, archived or not,
+// oldest first.
+func (s *Store) GetAllUserMemories(userID string) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		SELECT `+memorySelectCols+`
+		FROM memories m
+		WHERE m.user_id = ?
+		ORDER BY m.created_at ASC, m.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemories(rows)
+}
+
+// GetActiveUserIDs returns all distinct user IDs with stored memories, sorted.
+func (s *Store) GetActiveUserIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT user_id FROM memories ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetEmotionBuckets groups a user's emotional-sector memories into fixed-width
+// time buckets (aligned to the Unix epoch), oldest first. Empty buckets are omitted.
+func (s *Store) GetEmotionBuckets(userID string, bucket time.Duration) ([]EmotionBucket, error) {
+	width := int64(bucket / time.Second)
+	rows, err := s.db.Query(`
+		SELECT CAST(strftime('%s', created_at) AS INTEGER) / ? AS b, COUNT(*), AVG(valence)
+		FROM memories
+		WHERE user_id = ? AND sector = ? AND archived_at IS NULL
+		GROUP BY b
+		ORDER BY b ASC`,
+		width, userID, string(SectorEmotional),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []EmotionBucket
+	for rows.Next() {
+		var b int64
+		var eb EmotionBucket
+		if err := rows.Scan(&b, &eb.Count, &eb.AvgValence); err != nil {
+			return nil, err
+		}
+		eb.Start = time.Unix(b*width, 0).UTC()
+		buckets = append(buckets, eb)
+	}
+	return buckets, rows.Err()
+}
+
+// GetSectorCounts returns how many live memories a user has in each sector.
+// Sectors with no memories are absent.
+func (s *Store) GetSectorCounts(userID string) (map[Sector]int, error) {
+	rows, err := s.db.Query(`
+		SELECT sector, COUNT(*) FROM memories
+		WHERE user_id = ? AND archived_at IS NULL
+		GROUP BY sector`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[Sector]int)
+	for rows.Next() {
+		var sector string
+		var n int
+		if err := rows.Scan(&sector, &n); err != nil {
+			return nil, err
+		}
+		counts[Sector(sector)] = n
+	}
+	return counts, rows.Err()
+}
+
+// footprintRowOverhead approximates SQLite's per-row cost (header, rowid,
+// index entries) for Footprint.EstimatedBytes.
+const footprintRowOverhead = 64
+
+// GetStorageFootprint measures the rows and bytes stored for a user.
+func (s *Store) GetStorageFootprint(userID string) (Footprint, error) {
+	var f Footprint
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(LENGTH(CAST(content AS BLOB)) + LENGTH(CAST(summary AS BLOB)) +
+				LENGTH(CAST(user_message AS BLOB)) + LENGTH(CAST(assistant_message AS BLOB))), 0),
+			(SELECT COUNT(*) FROM vectors v JOIN memories m ON m.id = v.memory_id WHERE m.user_id = ?1),
+			(SELECT COALESCE(SUM(LENGTH(v.vector)), 0) FROM vectors v JOIN memories m ON m.id = v.memory_id WHERE m.user_id = ?1),
+			(SELECT COUNT(*) FROM associations a JOIN memories m ON m.id = a.memory_id WHERE m.user_id = ?1),
+			(SELECT COUNT(DISTINCT a.waypoint_id) FROM associations a JOIN memories m ON m.id = a.memory_id WHERE m.user_id = ?1)
+		FROM memories WHERE user_id = ?1`,
+		userID,
+	).Scan(&f.Memories, &f.TextBytes, &f.Vectors, &f.VectorBytes, &f.Associations, &f.Waypoints)
+	if err != nil {
+		return Footprint{}, err
+	}
+	rows := int64(f.Memories + f.Vectors + f.Associations)
+	f.EstimatedBytes = f.TextBytes + f.VectorBytes + rows*footprintRowOverhead
+	return f, nil
+}
+
+// --- Waypoint CRUD ---
+
+// UpsertWaypoint inserts or finds a waypoint by entity text, returns its ID.
+func (s *Store) UpsertWaypoint(text, entityType string) (int64, error) {
+	_, err := s.db.Exec(`
+		INSERT INTO waypoints (entity_text, entity_type) VALUES (?, ?)
+		ON CONFLICT(entity_text) DO UPDATE SET entity_type = excluded.entity_type`,
+		text, entityType,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = s.db.QueryRow(`SELECT id FROM waypoints WHERE entity_text = ?`, text).Scan(&id)
+	return id, err
+}
+
+// InsertAssociation links a memory to a waypoint with a weight.
+func (s *Store) InsertAssociation(memoryID, waypointID int64, weight float64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO associations (memory_id, waypoint_id, weight) VALUES (?, ?, ?)
+		ON CONFLICT(memory_id, waypoint_id) DO UPDATE SET weight = MAX(weight, excluded.weight)`,
+		memoryID, waypointID, weight,
+	)
+	return err
+}
+
+// UpsertWaypointsAndAssociate upserts a waypoint for each entity and links it
+// to memoryID with weight, all in one transaction: either every entity is
+// linked or none are.
+func (s *Store) UpsertWaypointsAndAssociate(memoryID int64, entities []Entity, weight float64) error {
+	weights := make([]float64, len(entities))
+	for i := range weights {
+		weights[i] = weight
+	}
+	return s.upsertWaypointsAndAssociate(memoryID, entities, weights)
+}
+
+// upsertWaypointsAndAssociate is UpsertWaypointsAndAssociate with a weight
+// per entity (weights[i] for entities[i]).
+func (s *Store) upsertWaypointsAndAssociate(memoryID int64, entities []Entity, weights []float64) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.Prepare(`
+		INSERT INTO waypoints (entity_text, entity_type) VALUES (?, ?)
+		ON CONFLICT(entity_text) DO UPDATE SET entity_type = excluded.entity_type
+		RETURNING id`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	associate, err := tx.Prepare(`
+		INSERT INTO associations (memory_id, waypoint_id, weight) VALUES (?, ?, ?)
+		ON CONFLICT(memory_id, waypoint_id) DO UPDATE SET weight = MAX(weight, excluded.weight)`)
+	if err != nil {
+		return err
+	}
+	defer associate.Close()
+
+	for i, e := range entities {
+		var wpID int64
+		if err := upsert.QueryRow(e.Text, e.Type).Scan(&wpID); err != nil {
+			return fmt.Errorf("engram: upsert waypoint %q: %w", e.Text, err)
+		}
+		if _, err := associate.Exec(memoryID, wpID, weights[i]); err != nil {
+			return fmt.Errorf("engram: associate waypoint %q: %w", e.Text, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAssociatedWaypointIDs returns waypoint IDs linked to a memory.
+func (s *Store) GetAssociatedWaypointIDs(memoryID int64) ([]int64, error) {
+	rows, err := s.reader().Query(`SELECT waypoint_id FROM associations WHERE memory_id = ?`, memoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetMemoriesByWaypoint returns memories linked to a waypoint, excluding a set of IDs.
+func (s *Store) GetMemoriesByWaypoint(waypointID int64, userID string, excludeIDs map[int64]bool) ([]memoryWithVector, error) {
+	rows, err := s.reader().Query(`
+		SELECT `+memorySelectCols+`, v.vector, v.norm, a.weight
+		FROM associations a
+		JOIN memories m ON m.id = a.memory_id
+		LEFT JOIN vectors v ON v.memory_id = m.id
+		WHERE a.waypoint_id = ? AND m.user_id = ? AND m.archived_at IS NULL`,
+		waypointID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	var results []memoryWithVector
 	for rows.Next() {
 		var mwv memoryWithVector
-		var lastAccessed, created string
 		var vecBlob []byte
+		var norm sql.NullFloat64
 		var linkWeight float64
 
-		if err := rows.Scan(
-			&mwv.ID, &mwv.Content, &mwv.Sector, &mwv.Salience, &mwv.DecayScore,
-			&lastAccessed, &mwv.AccessCount, &created, &mwv.Summary, &mwv.UserID,
-			&mwv.SessionID, &mwv.ParentID,
-			&vecBlob, &linkWeight,
-		); err != nil {
+		if err := scanMemoryInto(rows, &mwv.Memory, &vecBlob, &norm, &linkWeight); err != nil {
 			return nil, err
 		}
 
@@ -439,28 +1080,176 @@ func (s *Store) GetMemoriesByWaypoint(waypointID int64, userID string, excludeID
 			continue
 		}
 
-		mwv.LastAccessedAt, _ = time.Parse("2006-01-02 15:04:05", lastAccessed)
-		mwv.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", created)
 		if vecBlob != nil {
 			mwv.Vector = DecodeVector(vecBlob)
+			mwv.Norm = norm.Float64
+			if !norm.Valid {
+				mwv.Norm = vectorNorm(mwv.Vector)
+			}
 		}
 		results = append(results, mwv)
 	}
 	return results, rows.Err()
 }
 
+// GetMemoryIDsByEntities returns the IDs of a user's live memories linked to
+// a waypoint whose text matches one of texts (case-insensitively).
+func (s *Store) GetMemoryIDsByEntities(userID string, texts []string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	if len(texts) == 0 {
+		return ids, nil
+	}
+	args := []any{userID}
+	for _, t := range texts {
+		args = append(args, t)
+	}
+	placeholders := strings.Repeat("?,", len(texts))
+	rows, err := s.reader().Query(`
+		SELECT DISTINCT a.memory_id
+		FROM associations a
+		JOIN waypoints w ON w.id = a.waypoint_id
+		JOIN memories m ON m.id = a.memory_id
+		WHERE m.user_id = ? AND m.archived_at IS NULL
+		  AND w.entity_text COLLATE NOCASE IN (`+placeholders[:len(placeholders)-1]+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// GetMemoryEntityTypes returns the distinct entity types linked to each of
+// the given memories, keyed by memory ID. Memories without links are absent.
+func (s *Store) GetMemoryEntityTypes(ids []int64) (map[int64][]string, error) {
+	types := make(map[int64][]string)
+	for start := 0; start < len(ids); start += sqliteMaxVariables {
+		chunk := ids[start:min(start+sqliteMaxVariables, len(ids))]
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.Repeat("?,", len(chunk))
+		rows, err := s.reader().Query(`
+			SELECT DISTINCT a.memory_id, w.entity_type
+			FROM associations a
+			JOIN waypoints w ON w.id = a.waypoint_id
+			WHERE a.memory_id IN (`+placeholders[:len(placeholders)-1]+`)`,
+			args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var t string
+			if err := rows.Scan(&id, &t); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			types[id] = append(types[id], t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+// GetEntityCooccurrences returns entity pairs that share memories for a user,
+// ordered by shared-memory count (strongest first). Each pair carries up to
+// maxExamples example memory IDs.
+func (s *Store) GetEntityCooccurrences(userID string, limit, maxExamples int) ([]EntityPair, error) {
+	rows, err := s.db.Query(`
+		SELECT w1.entity_text, w1.entity_type, w2.entity_text, w2.entity_type,
+		       COUNT(*) AS shared, GROUP_CONCAT(a1.memory_id)
+		FROM associations a1
+		JOIN associations a2 ON a2.memory_id = a1.memory_id AND a2.waypoint_id > a1.waypoint_id
+		JOIN memories m ON m.id = a1.memory_id
+		JOIN waypoints w1 ON w1.id = a1.waypoint_id
+		JOIN waypoints w2 ON w2.id = a2.waypoint_id
+		WHERE m.user_id = ? AND m.archived_at IS NULL
+		GROUP BY a1.waypoint_id, a2.waypoint_id
+		ORDER BY shared DESC, w1.entity_text ASC, w2.entity_text ASC
+		LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []EntityPair
+	for rows.Next() {
+		var p EntityPair
+		var ids string
+		if err := rows.Scan(&p.A.Text, &p.A.Type, &p.B.Text, &p.B.Type, &p.Count, &ids); err != nil {
+			return nil, err
+		}
+		for _, part := range strings.Split(ids, ",") {
+			if len(p.MemoryIDs) >= maxExamples {
+				break
+			}
+			if id, err := strconv.ParseInt(part, 10, 64); err == nil {
+				p.MemoryIDs = append(p.MemoryIDs, id)
+			}
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// GetEntityTimeline returns a user's memories associated with the waypoint
+// whose text matches entityText (case-insensitively), oldest first, across all
+// sessions, omitting archived memories. A memory linked to several matching
+// waypoints (say "Valdris" and "valdris") appears once.
+func (s *Store) GetEntityTimeline(userID, entityText string) ([]Memory, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT `+memorySelectCols+`
+		FROM associations a
+		JOIN waypoints w ON w.id = a.waypoint_id
+		JOIN memories m ON m.id = a.memory_id
+		WHERE w.entity_text = ? COLLATE NOCASE AND m.user_id = ? AND m.archived_at IS NULL
+		ORDER BY m.created_at ASC, m.id ASC`,
+		entityText, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemories(rows)
+}
+
 // --- Reinforcement ---
 
 // ReinforceSalience boosts a memory's salience and updates its access timestamp.
 func (s *Store) ReinforceSalience(memoryID int64, boost float64) error {
+	return s.ReinforceSalienceWithCooldown(memoryID, boost, 0)
+}
+
+// ReinforceSalienceWithCooldown is ReinforceSalience, except that with
+// cooldown > 0 the call is a no-op when the memory was already reinforced
+// within the cooldown window (a never-accessed memory can always be reinforced).
+func (s *Store) ReinforceSalienceWithCooldown(memoryID int64, boost float64, cooldown time.Duration) error {
+	now := s.clock.Now().UTC()
+	cutoff := now.Add(-cooldown).Format("2006-01-02 15:04:05")
 	_, err := s.db.Exec(`
 		UPDATE memories
 		SET salience = MIN(salience + ?, 1.0),
 		    decay_score = MIN(decay_score + ?, 1.0),
-		    last_accessed_at = datetime('now'),
+		    last_accessed_at = ?,
 		    access_count = access_count + 1
-		WHERE id = ?`,
-		boost, boost, memoryID,
+		WHERE id = ? AND (? <= 0 OR access_count = 0 OR last_accessed_at <= ?)`,
+		boost, boost, now.Format("2006-01-02 15:04:05"), memoryID, int64(cooldown), cutoff,
 	)
 	return err
 }
@@ -468,6 +1257,9 @@ func (s *Store) ReinforceSalience(memoryID int64, boost float64) error {
 // UpdateMemorySector updates the sector for a memory in both the memories
 // and vectors tables. Used by the async LLM reclassification worker.
 func (s *Store) UpdateMemorySector(memoryID int64, sector Sector) error {
+	if err := s.stampEvents(s.db); err != nil {
+		return err
+	}
 	_, err := s.db.Exec(`UPDATE memories SET sector = ? WHERE id = ?`, string(sector), memoryID)
 	if err != nil {
 		return err
@@ -480,16 +1272,87 @@ func (s *Store) UpdateMemorySector(memoryID int64, sector Sector) error {
 
 // RunDecaySweep applies exponential decay to all memories and prunes dead ones.
 // Returns count of memories updated and deleted.
+//
+// The whole sweep runs in one transaction; see RunUserDecaySweep for a variant
+// that lets other queries interleave.
 func (s *Store) RunDecaySweep(minScore float64, decayRates map[Sector]float64) (updated int, deleted int, err error) {
+	return s.RunDecaySweepWithFloors(minScore, decayRates, nil)
+}
+
+// RunDecaySweepWithFloors is RunDecaySweep with per-sector floors: sectors
+// present in floors never decay below max(floor, minScore), so they are never
+// pruned by the sweep.
+func (s *Store) RunDecaySweepWithFloors(minScore float64, decayRates, floors map[Sector]float64) (updated int, deleted int, err error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return 0, 0, err
 	}
 	defer tx.Rollback()
 
-	// Load all memories for decay calculation
-	rows, err := tx.Query(`
-		SELECT id, sector, salience, last_accessed_at FROM memories`)
+	if err := s.stampEvents(tx); err != nil {
+		return 0, 0, err
+	}
+	updated, deleted, err = decayMemories(tx, "", s.clock.Now(), minScore, decayRates, floors, s.arousalDecay, s.deleteBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	decayAssociations(tx)
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return updated, deleted, nil
+}
+
+// RunUserDecaySweep applies decay to one user's memories in its own short
+// transaction. Association decay is left to DecayAssociations.
+func (s *Store) RunUserDecaySweep(userID string, minScore float64, decayRates, floors map[Sector]float64) (updated int, deleted int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := s.stampEvents(tx); err != nil {
+		return 0, 0, err
+	}
+	updated, deleted, err = decayMemories(tx, userID, s.clock.Now(), minScore, decayRates, floors, s.arousalDecay, s.deleteBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return updated, deleted, nil
+}
+
+// DecayAssociations weakens all association weights, drops dead associations,
+// and removes waypoints left without any.
+func (s *Store) DecayAssociations() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	decayAssociations(tx)
+	return tx.Commit()
+}
+
+// decayMemories recomputes decay scores for live memories (one user's, or all
+// if userID is empty) as of now, and deletes those that fall below minScore.
+// arousalDecay slows emotional memories by arousal (see Config.EmotionalArousalDecay);
+// deleteBatch is the rows per DELETE statement (see deleteMemoriesByID).
+func decayMemories(tx *sql.Tx, userID string, now time.Time, minScore float64, decayRates, floors map[Sector]float64, arousalDecay float64, deleteBatch int) (updated int, deleted int, err error) {
+	// Load all live memories for decay calculation (archived ones wait for purge)
+	query := `SELECT id, sector, salience, last_accessed_at, COALESCE(decay_lambda, 0), arousal
+		FROM memories WHERE archived_at IS NULL`
+	var args []any
+	if userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -501,27 +1364,23 @@ func (s *Store) RunDecaySweep(minScore float64, decayRates map[Sector]float64) (
 	var updates []decayUpdate
 	var toDelete []int64
 
-	now := time.Now()
 	for rows.Next() {
 		var id int64
 		var sector string
 		var salience float64
 		var lastAccessed string
+		var ownLambda, arousal float64
 
-		if err := rows.Scan(&id, &sector, &salience, &lastAccessed); err != nil {
+		if err := rows.Scan(&id, &sector, &salience, &lastAccessed, &ownLambda, &arousal); err != nil {
 			rows.Close()
 			return 0, 0, err
 		}
 
 		accessTime, _ := time.Parse("2006-01-02 15:04:05", lastAccessed)
-		days := now.Sub(accessTime).Hours() / 24.0
-
-		lambda := decayRates[Sector(sector)]
-		if lambda == 0 {
-			lambda = 0.02 // default warm
-		}
+		days := DaysBetween(accessTime, now)
 
-		newScore := salience * math.Exp(-lambda*days/(salience+0.1))
+		m := Memory{Sector: Sector(sector), Salience: salience, DecayLambda: ownLambda, Arousal: arousal}
+		newScore := decayedScore(m, days, minScore, decayRates, floors, arousalDecay)
 
 		if newScore < minScore {
 			toDelete = append(toDelete, id)
@@ -542,49 +1401,342 @@ func (s *Store) RunDecaySweep(minScore float64, decayRates map[Sector]float64) (
 	stmt.Close()
 
 	// Delete dead memories (cascades to vectors + associations)
-	for _, id := range toDelete {
-		tx.Exec(`DELETE FROM memories WHERE id = ?`, id)
+	if _, err := deleteMemoriesByID(tx, toDelete, deleteBatch); err != nil {
+		return 0, 0, err
 	}
 
+	return len(updates), len(toDelete), nil
+}
+
+// sqliteMaxVariables is SQLite's historical default limit on bound
+// parameters per statement, the safe bound for IN lists.
+const sqliteMaxVariables = 999
+
+// execer is the Exec half of *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// deleteMemoriesByID deletes memories in chunks of batch IDs per statement
+// (default 500, capped at sqliteMaxVariables). Deletes cascade to vectors and
+// associations as for single-row deletes. Returns the statements executed.
+func deleteMemoriesByID(ex execer, ids []int64, batch int) (statements int, err error) {
+	if batch <= 0 {
+		batch = 500
+	}
+	batch = min(batch, sqliteMaxVariables)
+	for start := 0; start < len(ids); start += batch {
+		chunk := ids[start:min(start+batch, len(ids))]
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.Repeat("?,", len(chunk))
+		if _, err := ex.Exec(`DELETE FROM memories WHERE id IN (`+placeholders[:len(placeholders)-1]+`)`, args...); err != nil {
+			return statements, err
+		}
+		statements++
+	}
+	return statements, nil
+}
+
+// decayedScore is m's decay score after days without access: salience decayed
+// at the memory's own rate, else its sector's (slowed by arousal for
+// emotional memories), and clamped to any sector floor.
+func decayedScore(m Memory, days, minScore float64, decayRates, floors map[Sector]float64, arousalDecay float64) float64 {
+	lambda := m.DecayLambda // per-memory override wins over the sector rate
+	if lambda == 0 {
+		lambda = decayRates[m.Sector]
+	}
+	if lambda == 0 {
+		lambda = 0.02 // default warm
+	}
+	if m.Sector == SectorEmotional {
+		lambda /= 1 + arousalDecay*m.Arousal
+	}
+
+	score := m.Salience * DecayFactor(lambda, days, m.Salience)
+	if floor, ok := floors[m.Sector]; ok {
+		score = math.Max(score, math.Max(floor, minScore))
+	}
+	return score
+}
+
+func decayAssociations(tx *sql.Tx) {
 	// Decay association weights
 	tx.Exec(`UPDATE associations SET weight = weight * 0.995`)
 	tx.Exec(`DELETE FROM associations WHERE weight < 0.05`)
 
 	// Clean up orphaned waypoints
 	tx.Exec(`DELETE FROM waypoints WHERE id NOT IN (SELECT DISTINCT waypoint_id FROM associations)`)
+}
+
+// --- Archive ---
+
+// ArchiveMemory hides a memory from retrieval without deleting it.
+// Archiving an already-archived memory keeps its original archive time.
+func (s *Store) ArchiveMemory(memoryID int64) error {
+	return s.execOne(`
+		UPDATE memories SET archived_at = COALESCE(archived_at, ?)
+		WHERE id = ?`, s.timestamp(), memoryID)
+}
+
+// RestoreMemory makes an archived memory retrievable again.
+func (s *Store) RestoreMemory(memoryID int64) error {
+	return s.execOne(`UPDATE memories SET archived_at = NULL WHERE id = ?`, memoryID)
+}
+
+// PurgeArchived hard-deletes memories archived before the cutoff, with
+// their logged events other than the delete. Returns the number of memories
+// deleted.
+func (s *Store) PurgeArchived(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UTC().Format("2006-01-02 15:04:05")
+	// Drop events carrying content before the delete events are logged
+	if _, err := tx.Exec(`
+		DELETE FROM events WHERE memory_id IN (
+			SELECT id FROM memories WHERE archived_at IS NOT NULL AND archived_at < ?
+		)`, cutoff,
+	); err != nil {
+		return 0, err
+	}
+	if err := s.stampEvents(tx); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		DELETE FROM memories
+		WHERE archived_at IS NOT NULL AND archived_at < ?`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// stampEvents records the store clock's time in meta event_clock for the
+// event triggers with no row timestamp to copy (reclassify, delete). Call it
+// before such a write; the system clock needs no stamp.
+func (s *Store) stampEvents(ex execer) error {
+	if _, ok := s.clock.(realClock); ok {
+		return nil
+	}
+	_, err := ex.Exec(`
+		INSERT INTO meta (key, value) VALUES ('event_clock', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		s.timestamp(),
+	)
+	return err
+}
+
+// execOne runs a single-row update, returning sql.ErrNoRows if no row matched.
+func (s *Store) execOne(query string, args ...any) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Integrity ---
+
+// Integrity counts rows orphaned by deletes that bypassed the cascade:
+// vectors and associations whose memory is gone, associations whose waypoint
+// is gone, and waypoints with no remaining associations.
+func (s *Store) Integrity() (IntegrityReport, error) {
+	var r IntegrityReport
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM vectors
+		WHERE memory_id NOT IN (SELECT id FROM memories)`).Scan(&r.OrphanedVectors); err != nil {
+		return r, err
+	}
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM associations
+		WHERE memory_id NOT IN (SELECT id FROM memories)
+		   OR waypoint_id NOT IN (SELECT id FROM waypoints)`).Scan(&r.OrphanedAssociations); err != nil {
+		return r, err
+	}
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM waypoints
+		WHERE id NOT IN (SELECT DISTINCT waypoint_id FROM associations)`).Scan(&r.OrphanedWaypoints); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// RepairIntegrity deletes orphaned vectors, associations, and waypoints.
+// Returns counts of rows removed. Associations are removed before waypoints
+// so waypoints orphaned by the association cleanup are also removed.
+func (s *Store) RepairIntegrity() (IntegrityReport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+	defer tx.Rollback()
+
+	var r IntegrityReport
+	steps := []struct {
+		query string
+		count *int
+	}{
+		{`DELETE FROM vectors WHERE memory_id NOT IN (SELECT id FROM memories)`, &r.OrphanedVectors},
+		{`DELETE FROM associations
+		  WHERE memory_id NOT IN (SELECT id FROM memories)
+		     OR waypoint_id NOT IN (SELECT id FROM waypoints)`, &r.OrphanedAssociations},
+		{`DELETE FROM waypoints WHERE id NOT IN (SELECT DISTINCT waypoint_id FROM associations)`, &r.OrphanedWaypoints},
+	}
+	for _, step := range steps {
+		res, err := tx.Exec(step.query)
+		if err != nil {
+			return IntegrityReport{}, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return IntegrityReport{}, err
+		}
+		*step.count = int(n)
+	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, 0, err
+		return IntegrityReport{}, err
 	}
+	return r, nil
+}
 
-	return len(updates), len(toDelete), nil
+// --- Meta ---
+
+// GetMeta returns a persisted setting, or "" if it has never been set.
+func (s *Store) GetMeta(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetMeta persists a setting, replacing any previous value.
+func (s *Store) SetMeta(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+		key, value,
+	)
+	return err
+}
+
+// --- User config ---
+
+// GetUserConfig returns a user's stored config JSON, or nil if none is set.
+func (s *Store) GetUserConfig(userID string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT config FROM user_config WHERE user_id = ?`, userID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SetUserConfig stores a user's config JSON, replacing any previous value.
+func (s *Store) SetUserConfig(userID string, data []byte) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_config (user_id, config, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET config = excluded.config, updated_at = excluded.updated_at`,
+		userID, string(data), s.timestamp(),
+	)
+	return err
 }
 
 // --- Memory cap enforcement ---
 
 // EnforceMemoryLimit deletes the oldest low-salience memories if a user exceeds the limit.
 func (s *Store) EnforceMemoryLimit(userID string, maxCount int) error {
+	_, err := s.enforceMemoryLimit(userID, maxCount)
+	return err
+}
+
+// enforceMemoryLimit is EnforceMemoryLimit returning the deleted memory IDs.
+func (s *Store) enforceMemoryLimit(userID string, maxCount int) ([]int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var count int
-	if err := s.db.QueryRow(`SELECT COUNT(*) FROM memories WHERE user_id = ?`, userID).Scan(&count); err != nil {
-		return err
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM memories WHERE user_id = ? AND archived_at IS NULL`, userID).Scan(&count); err != nil {
+		return nil, err
 	}
 	if count <= maxCount {
-		return nil
+		return nil, nil
 	}
 
-	excess := count - maxCount
-	_, err := s.db.Exec(`
-		DELETE FROM memories WHERE id IN (
-			SELECT id FROM memories
-			WHERE user_id = ?
-			ORDER BY decay_score ASC, created_at ASC
-			LIMIT ?
-		)`, userID, excess,
+	rows, err := tx.Query(`
+		SELECT id FROM memories
+		WHERE user_id = ? AND archived_at IS NULL
+		ORDER BY decay_score ASC, created_at ASC
+		LIMIT ?`, userID, count-maxCount,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.stampEvents(tx); err != nil {
+		return nil, err
+	}
+	if _, err := deleteMemoriesByID(tx, ids, s.deleteBatch); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// Backup writes a consistent snapshot of the live database to destPath with
+// VACUUM INTO. It runs on the store's single connection, so it waits for any
+// in-flight statement and other callers wait for the copy. destPath must not
+// already exist.
+func (s *Store) Backup(destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("engram: backup destination %s already exists", destPath)
+	}
+	if _, err := s.db.Exec(`VACUUM INTO ?`, destPath); err != nil {
+		return fmt.Errorf("engram: backup to %s: %w", destPath, err)
+	}
+	return nil
 }
 
 // Close shuts down the database connection.
 func (s *Store) Close() error {
+	if s.rdb != nil {
+		s.rdb.Close()
+	}
 	return s.db.Close()
 }
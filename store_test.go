@@ -1,8 +1,10 @@
 package engram
 
 import (
+	"database/sql"
 	"math"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -41,6 +43,29 @@ func TestVectorEncodeDecodeEmpty(t *testing.T) {
 	}
 }
 
+func TestDecodeVectorTagged(t *testing.T) {
+	original := []float32{0.25, -1.5, 3}
+
+	tagged := EncodeVector(original)
+	if len(tagged) != len(original)*4+1 || tagged[0] != vectorFormatFloat32 {
+		t.Fatalf("expected a float32 tag prefix, got % x", tagged[:1])
+	}
+	decoded := DecodeVector(tagged)
+	if len(decoded) != len(original) {
+		t.Fatalf("length mismatch: %d vs %d", len(decoded), len(original))
+	}
+	for i := range original {
+		if decoded[i] != original[i] {
+			t.Errorf("index %d: expected %f, got %f", i, original[i], decoded[i])
+		}
+	}
+
+	unknown := append([]byte{0xff}, tagged[1:]...)
+	if v := DecodeVector(unknown); v != nil {
+		t.Errorf("expected nil for an unknown format tag, got %v", v)
+	}
+}
+
 func TestInsertAndGetMemory(t *testing.T) {
 	s := testStore(t)
 
@@ -131,6 +156,26 @@ func TestReinforceSalienceCapsAtOne(t *testing.T) {
 	}
 }
 
+func TestReinforceSalienceCooldown(t *testing.T) {
+	s := testStore(t)
+
+	id, _ := s.InsertMemory(Memory{Content: "test", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "t"})
+	if err := s.ReinforceSalienceWithCooldown(id, 0.15, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReinforceSalienceWithCooldown(id, 0.15, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	mwvs, _ := s.GetMemoriesWithVectors("u1")
+	if math.Abs(mwvs[0].Salience-0.65) > 0.01 {
+		t.Errorf("expected only the first boost (salience ~0.65), got %.2f", mwvs[0].Salience)
+	}
+	if mwvs[0].AccessCount != 1 {
+		t.Errorf("expected access count 1, got %d", mwvs[0].AccessCount)
+	}
+}
+
 func TestRunDecaySweep(t *testing.T) {
 	s := testStore(t)
 
@@ -161,6 +206,142 @@ func TestRunDecaySweep(t *testing.T) {
 	}
 }
 
+func TestRunDecaySweepSectorFloor(t *testing.T) {
+	s := testStore(t)
+
+	// Two equally-aged, low-salience memories that would both decay below the threshold
+	epiID, _ := s.InsertMemory(Memory{Content: "old event", Sector: SectorEpisodic, Salience: 0.05, UserID: "u1", Summary: "e"})
+	semID, _ := s.InsertMemory(Memory{Content: "old fact", Sector: SectorSemantic, Salience: 0.05, UserID: "u1", Summary: "s"})
+	s.db.Exec(`UPDATE memories SET last_accessed_at = '2020-01-01 00:00:00'`)
+
+	floors := map[Sector]float64{SectorSemantic: 0.02}
+	_, deleted, err := s.RunDecaySweepWithFloors(0.01, DefaultDecayRates(), floors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+
+	mwvs, _ := s.GetMemoriesWithVectors("u1")
+	if len(mwvs) != 1 || mwvs[0].ID != semID {
+		t.Fatalf("expected only semantic memory %d to survive (episodic %d pruned), got %v", semID, epiID, mwvs)
+	}
+	if math.Abs(mwvs[0].DecayScore-0.02) > 1e-9 {
+		t.Errorf("expected decay score clamped to floor 0.02, got %.4f", mwvs[0].DecayScore)
+	}
+}
+
+func TestRunDecaySweepPerMemoryLambda(t *testing.T) {
+	s := testStore(t)
+
+	promise, _ := s.InsertMemory(Memory{Content: "save you a seat Friday", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "p", DecayLambda: 0.5})
+	identity, _ := s.InsertMemory(Memory{Content: "grew up in Lisbon", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "i", DecayLambda: 0.001})
+	plain, _ := s.InsertMemory(Memory{Content: "likes tea", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "t"})
+	s.db.Exec(`UPDATE memories SET last_accessed_at = datetime('now', '-2 days')`)
+
+	if _, _, err := s.RunDecaySweep(0.01, DefaultDecayRates()); err != nil {
+		t.Fatal(err)
+	}
+
+	scores := make(map[int64]float64)
+	mwvs, _ := s.GetMemoriesWithVectors("u1")
+	for _, m := range mwvs {
+		scores[m.ID] = m.DecayScore
+	}
+	// score = salience * exp(-lambda * days / (salience + 0.1))
+	expect := func(lambda float64) float64 { return 0.5 * math.Exp(-lambda*2/0.6) }
+	for id, lambda := range map[int64]float64{promise: 0.5, identity: 0.001, plain: DefaultDecayRates()[SectorSemantic]} {
+		if math.Abs(scores[id]-expect(lambda)) > 1e-3 {
+			t.Errorf("memory %d: expected decay score %.4f for lambda %v, got %.4f", id, expect(lambda), lambda, scores[id])
+		}
+	}
+	if !(scores[promise] < scores[plain] && scores[plain] < scores[identity]) {
+		t.Errorf("expected promise < sector default < identity, got %v", scores)
+	}
+}
+
+func TestRunDecaySweepEmotionalArousal(t *testing.T) {
+	s := testStore(t)
+	s.arousalDecay = 9 // full arousal decays 10x slower
+
+	humiliation, _ := s.InsertMemory(Memory{Content: "tripped on stage", Sector: SectorEmotional, Salience: 0.2, UserID: "u1", Summary: "h", Arousal: 1})
+	contentment, _ := s.InsertMemory(Memory{Content: "nice quiet tea", Sector: SectorEmotional, Salience: 0.2, UserID: "u1", Summary: "c", Arousal: 0.05})
+	s.db.Exec(`UPDATE memories SET last_accessed_at = datetime('now', '-600 days')`)
+
+	_, deleted, err := s.RunDecaySweep(0.01, DefaultDecayRates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+
+	mwvs, _ := s.GetMemoriesWithVectors("u1")
+	if len(mwvs) != 1 || mwvs[0].ID != humiliation {
+		t.Fatalf("expected only high-arousal memory %d to survive (low-arousal %d pruned), got %v", humiliation, contentment, mwvs)
+	}
+	if mwvs[0].Arousal != 1 {
+		t.Errorf("expected arousal 1 to round-trip, got %v", mwvs[0].Arousal)
+	}
+}
+
+// countingExecer counts statements passed through to an execer.
+type countingExecer struct {
+	execer
+	calls int
+}
+
+func (c *countingExecer) Exec(query string, args ...any) (sql.Result, error) {
+	c.calls++
+	return c.execer.Exec(query, args...)
+}
+
+func TestRunDecaySweepBatchesDeletes(t *testing.T) {
+	s := testStore(t)
+	s.deleteBatch = 250
+
+	const dead = 600
+	wp, _ := s.UpsertWaypoint("Tokyo", "place")
+	for i := 0; i < dead; i++ {
+		id, _ := s.InsertMemory(Memory{Content: "fading", Sector: SectorEpisodic, Salience: 0.001, UserID: "u1", Summary: "f"})
+		s.InsertVector(id, SectorEpisodic, []float32{1, 0, 0})
+		s.InsertAssociation(id, wp, 0.5)
+	}
+	keep, _ := s.InsertMemory(Memory{Content: "strong", Sector: SectorSemantic, Salience: 0.9, UserID: "u1", Summary: "s"})
+	s.db.Exec(`UPDATE memories SET last_accessed_at = datetime('now', '-600 days') WHERE id != ?`, keep)
+
+	_, deleted, err := s.RunDecaySweep(0.01, DefaultDecayRates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != dead {
+		t.Errorf("expected %d deleted, got %d", dead, deleted)
+	}
+	var memories, vectors, assocs int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memories`).Scan(&memories)
+	s.db.QueryRow(`SELECT COUNT(*) FROM vectors`).Scan(&vectors)
+	s.db.QueryRow(`SELECT COUNT(*) FROM associations`).Scan(&assocs)
+	if memories != 1 || vectors != 0 || assocs != 0 {
+		t.Errorf("expected only the strong memory left and deletes cascaded, got %d memories, %d vectors, %d associations", memories, vectors, assocs)
+	}
+
+	ids := make([]int64, 2100)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	for _, tc := range []struct{ batch, want int }{{0, 5}, {250, 9}, {5000, 3}} {
+		ex := &countingExecer{execer: s.db}
+		n, err := deleteMemoriesByID(ex, ids, tc.batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tc.want || ex.calls != tc.want {
+			t.Errorf("batch %d: expected %d statements for %d IDs, got %d (%d executed)", tc.batch, tc.want, len(ids), n, ex.calls)
+		}
+	}
+}
+
 func TestEnforceMemoryLimit(t *testing.T) {
 	s := testStore(t)
 
@@ -233,6 +414,88 @@ func TestWaypointCRUD(t *testing.T) {
 	}
 }
 
+func TestUpsertWaypointsAndAssociate(t *testing.T) {
+	s := testStore(t)
+
+	tokyo, _ := s.UpsertWaypoint("Tokyo", "place")
+	memID, _ := s.InsertMemory(Memory{Content: "jazz night", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "j"})
+	entities := []Entity{
+		{Text: "Tokyo", Type: "place"},
+		{Text: "Alex", Type: "person"},
+		{Text: "Blue Note", Type: "place"},
+		{Text: "Miles Davis", Type: "music_artist"},
+		{Text: "So What", Type: "song"},
+	}
+	if err := s.UpsertWaypointsAndAssociate(memID, entities, 0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := s.GetAssociatedWaypointIDs(memID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 {
+		t.Fatalf("expected 5 associations, got %d", len(ids))
+	}
+	reused := false
+	for _, id := range ids {
+		reused = reused || id == tokyo
+	}
+	if !reused {
+		t.Errorf("expected existing waypoint %d to be reused, got %v", tokyo, ids)
+	}
+
+	// A failing association (no such memory) rolls back the waypoints created with it
+	err = s.UpsertWaypointsAndAssociate(9999, []Entity{{Text: "Orphan", Type: "topic"}}, 0.5)
+	if err == nil {
+		t.Fatal("expected error linking a missing memory")
+	}
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM waypoints WHERE entity_text = 'Orphan'`).Scan(&n)
+	if n != 0 {
+		t.Errorf("expected rolled-back waypoint, found %d", n)
+	}
+}
+
+func TestIntegrityDetectsAndRepairsOrphanedVector(t *testing.T) {
+	s := testStore(t)
+
+	id, _ := s.InsertMemory(Memory{Content: "kept", Sector: SectorSemantic, Salience: 0.5, UserID: "u1", Summary: "k"})
+	s.InsertVector(id, SectorSemantic, []float32{1, 0, 0})
+
+	// Simulate a delete that bypassed the cascade
+	s.db.Exec(`PRAGMA foreign_keys = OFF`)
+	if _, err := s.db.Exec(`INSERT INTO vectors (memory_id, sector, vector) VALUES (9999, 'semantic', ?)`, EncodeVector([]float32{0, 1, 0})); err != nil {
+		t.Fatal(err)
+	}
+	s.db.Exec(`PRAGMA foreign_keys = ON`)
+
+	report, err := s.Integrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OrphanedVectors != 1 {
+		t.Errorf("expected 1 orphaned vector, got %d", report.OrphanedVectors)
+	}
+
+	repaired, err := s.RepairIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired.OrphanedVectors != 1 {
+		t.Errorf("expected 1 vector removed, got %d", repaired.OrphanedVectors)
+	}
+
+	report, _ = s.Integrity()
+	if report != (IntegrityReport{}) {
+		t.Errorf("expected clean report after repair, got %+v", report)
+	}
+	mwvs, _ := s.GetMemoriesWithVectors("u1")
+	if len(mwvs) != 1 || mwvs[0].Vector == nil {
+		t.Error("repair should not touch vectors of live memories")
+	}
+}
+
 func TestNewStoreCreatesDir(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "subdir", "nested", "test.db")
@@ -249,3 +512,141 @@ func TestDaysSinceUnit(t *testing.T) {
 		t.Errorf("expected ~0 days, got %.4f", d)
 	}
 }
+
+func TestGetCandidateMemoriesWithVectorsCap(t *testing.T) {
+	s := testStore(t)
+
+	var ids []int64
+	for i := 0; i < 10; i++ {
+		salience := 0.3
+		if i == 0 {
+			salience = 0.9 // oldest, but the most salient
+		}
+		id, _ := s.InsertMemory(Memory{Content: "m", Sector: SectorSemantic, Salience: salience, UserID: "u1", Summary: "m"})
+		ids = append(ids, id)
+	}
+
+	mwvs, err := s.GetCandidateMemoriesWithVectors("u1", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mwvs) != 4 {
+		t.Fatalf("expected cap of 4 rows, got %d", len(mwvs))
+	}
+	got := make(map[int64]bool)
+	for _, m := range mwvs {
+		got[m.ID] = true
+	}
+	if !got[ids[0]] {
+		t.Error("expected the most salient memory within the cap")
+	}
+	if !got[ids[9]] || !got[ids[8]] {
+		t.Error("expected the most recent memories within the cap")
+	}
+
+	all, _ := s.GetCandidateMemoriesWithVectors("u1", 0)
+	if len(all) != 10 {
+		t.Errorf("expected no cap at 0, got %d rows", len(all))
+	}
+}
+
+func TestReadReplicaReadsDuringHeldWrite(t *testing.T) {
+	s := testStore(t)
+	if err := s.OpenReadReplica(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		id, _ := s.InsertMemory(Memory{Content: "committed", Sector: SectorSemantic, Salience: 0.5, UserID: "u1"})
+		s.InsertVector(id, SectorSemantic, []float32{1, 0, 0})
+	}
+
+	// Hold the only writer connection in an open write transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`INSERT INTO memories (content, sector, salience, decay_score, user_id) VALUES ('pending', 'semantic', 0.5, 0.5, 'u1')`); err != nil {
+		t.Fatal(err)
+	}
+
+	type loaded struct {
+		mwvs []memoryWithVector
+		err  error
+	}
+	done := make(chan loaded, 1)
+	go func() {
+		mwvs, err := s.GetCandidateMemoriesWithVectors("u1", 0)
+		done <- loaded{mwvs, err}
+	}()
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatal(got.err)
+		}
+		if len(got.mwvs) != 3 {
+			t.Errorf("expected the 3 committed memories, got %d", len(got.mwvs))
+		}
+		for _, m := range got.mwvs {
+			if len(m.Vector) != 3 {
+				t.Errorf("memory #%d: expected its vector, got %v", m.ID, m.Vector)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("read blocked behind the held write")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if mwvs, _ := s.GetCandidateMemoriesWithVectors("u1", 0); len(mwvs) != 4 {
+		t.Errorf("expected the committed write visible to the replica, got %d memories", len(mwvs))
+	}
+}
+
+func TestReadReplicaConcurrentAddAndSearch(t *testing.T) {
+	cm := testEngramConfig(t, Config{ReadReplica: true, EmbeddingProvider: &mockEmbedder{vec: []float32{1, 0, 0}, dim: 3}})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if _, err := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "note", SectorHint: SectorSemantic, Salience: 0.3}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				cm.Search("note", "u1", 5, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if results := cm.Search("note", "u1", 50, nil); len(results) != 40 {
+		t.Errorf("expected all 40 memories searchable, got %d", len(results))
+	}
+}
+
+func TestGetMemoryEntityTypesOnlyForRequestedIDs(t *testing.T) {
+	s := testStore(t)
+
+	quest, _ := s.UpsertWaypoint("the amulet", "quest_objective")
+	place, _ := s.UpsertWaypoint("the tavern", "place")
+	a, _ := s.InsertMemory(Memory{Content: "find the amulet", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "a"})
+	b, _ := s.InsertMemory(Memory{Content: "met at the tavern", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1", Summary: "b"})
+	s.InsertAssociation(a, quest, 1)
+	s.InsertAssociation(a, place, 1)
+	s.InsertAssociation(b, place, 1)
+
+	types, err := s.GetMemoryEntityTypes([]int64{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 || len(types[a]) != 2 {
+		t.Errorf("expected both types for #%d only, got %v", a, types)
+	}
+}
@@ -1,6 +1,7 @@
 package engram
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -178,3 +179,154 @@ func TestMemoryWithVectorsIncludesTemporalFields(t *testing.T) {
 		t.Errorf("expected parent_id 42, got %d", mwvs[0].ParentID)
 	}
 }
+
+func TestSessionTranscriptChronological(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{Clock: clock})
+
+	// Insert out of order; the transcript must follow created_at
+	clock.Advance(time.Minute)
+	cm.AddWithOptions(AddOptions{UserID: "u1", SessionID: "s1", UserMessage: "Second | question", AssistantMessage: "Second answer"})
+	clock.Advance(-time.Minute)
+	cm.AddWithOptions(AddOptions{UserID: "u1", SessionID: "s1", UserMessage: "First question", AssistantMessage: "First answer"})
+	cm.AddWithOptions(AddOptions{UserID: "u1", SessionID: "other", UserMessage: "Elsewhere", AssistantMessage: "Ignored"})
+
+	transcript, err := cm.SessionTranscript("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[2025-06-01 20:00:00] User: First question\n" +
+		"[2025-06-01 20:00:00] Assistant: First answer\n" +
+		"[2025-06-01 20:01:00] User: Second | question\n" +
+		"[2025-06-01 20:01:00] Assistant: Second answer\n"
+	if transcript != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", transcript, want)
+	}
+
+	if _, err := cm.SessionTranscript("missing"); err == nil {
+		t.Error("expected error for unknown session")
+	}
+}
+
+func TestGetThreadStitchesAroundDeletedMemory(t *testing.T) {
+	for _, tt := range []struct {
+		policy ParentPolicy
+		want   []string
+	}{
+		{ParentStitch, []string{"first", "third"}},
+		{ParentDetach, []string{"third"}},
+	} {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			cm := testEngramConfig(t, Config{ParentOnDelete: tt.policy})
+
+			first, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "first", SessionID: "s1"})
+			second, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "second", SessionID: "s1", ParentID: first})
+			third, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "third", SessionID: "s1", ParentID: second})
+
+			// Remove the middle of the chain the way cap enforcement would
+			if _, err := cm.store.db.Exec(`DELETE FROM memories WHERE id = ?`, second); err != nil {
+				t.Fatal(err)
+			}
+
+			thread, err := cm.GetThread(third)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range thread {
+				got = append(got, m.UserMessage)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected thread %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected thread %v, got %v", tt.want, got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestGetLastSessionWithinMaxAge(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{Clock: clock})
+
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "see you soon", AssistantMessage: "bye", SessionID: "s1"})
+	clock.Advance(180 * 24 * time.Hour)
+
+	mems, err := cm.GetLastSessionWithin("u1", 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mems) != 0 {
+		t.Errorf("expected a six-month-old session to count as no recent session, got %d memories", len(mems))
+	}
+
+	mems, _ = cm.GetLastSessionWithin("u1", 365*24*time.Hour)
+	if len(mems) != 1 {
+		t.Errorf("expected the session within the age limit, got %d memories", len(mems))
+	}
+	mems, _ = cm.GetLastSession("u1")
+	if len(mems) != 1 {
+		t.Errorf("expected no age limit by default, got %d memories", len(mems))
+	}
+
+	ctxResult, err := cm.BuildContext(context.Background(), "u1", "hello", ContextOptions{SessionMaxAge: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctxResult.RecentTurns) != 0 {
+		t.Errorf("expected BuildContext to skip the stale session, got %d turns", len(ctxResult.RecentTurns))
+	}
+}
+
+func TestGetChildrenReturnsBranches(t *testing.T) {
+	cm := testEngramConfig(t, Config{})
+
+	root, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "which door?", SessionID: "s1"})
+	left, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "the left one", SessionID: "s1", ParentID: root})
+	right, _ := cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "the right one", SessionID: "s1", ParentID: root})
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "deeper", SessionID: "s1", ParentID: left})
+
+	children, err := cm.GetChildren(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 || children[0].ID != left || children[1].ID != right {
+		t.Fatalf("expected both branches [%d %d], got %v", left, right, children)
+	}
+
+	if roots, _ := cm.GetChildren(0); len(roots) != 0 {
+		t.Errorf("expected no children for parent 0, got %d", len(roots))
+	}
+}
+
+func TestRecordSessionMarkersOnNewSession(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	cm := testEngramConfig(t, Config{Clock: clock, RecordSessionMarkers: true})
+
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "first visit", AssistantMessage: "welcome", SessionID: "s1"})
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "still here", AssistantMessage: "nice", SessionID: "s1"})
+	if mems, _ := cm.GetSession("s1"); len(mems) != 2 {
+		t.Fatalf("expected no marker for a user's first session, got %d memories", len(mems))
+	}
+
+	clock.Advance(14 * 24 * time.Hour)
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "I'm back", AssistantMessage: "missed you", SessionID: "s2"})
+	cm.AddWithOptions(AddOptions{UserID: "u1", UserMessage: "what's new?", AssistantMessage: "lots", SessionID: "s2"})
+
+	mems, err := cm.GetSession("s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mems) != 3 {
+		t.Fatalf("expected one marker plus two turns in s2, got %d memories", len(mems))
+	}
+	marker := mems[0]
+	if marker.Sector != SectorEpisodic || marker.Content != "Session started after 14 days away" {
+		t.Errorf("expected episodic 14-day marker first, got [%s] %q", marker.Sector, marker.Content)
+	}
+}
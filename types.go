@@ -72,6 +72,46 @@ type Memory struct {
 	Summary        string // Short text injected into prompts
 	SessionID      string // Conversation session identifier (UUID or caller-provided)
 	ParentID       int64  // Previous memory in the conversation chain (0 = none)
+
+	// The two halves of the exchange, stored separately from the joined Content
+	// (empty for memories stored before structured storage, and for reflections)
+	UserMessage      string
+	AssistantMessage string
+
+	Valence float64 // -1.0 (negative) – 1.0 (positive); 0 = neutral or unknown
+	Arousal float64 // 0.0 (calm) – 1.0 (intense); 0 = calm or unknown
+
+	// Confidence is how sure the character is of the memory, 0.0 – 1.0,
+	// independent of Salience (importance): a trivial fact can be certain and a
+	// vital impression hazy. Decay and reinforcement leave it unchanged.
+	Confidence float64
+
+	MediaRefs []MediaRef // Images or other media the memory refers to (nil = none)
+
+	IdempotencyKey string // Caller's request key from AddOptions ("" = none)
+
+	DecayLambda float64 // Per-memory decay rate overriding the sector's (0 = sector default)
+
+	// The window a reflection summarizes: ReflectOptions.WindowLabel and the
+	// creation times of the oldest and newest memories it drew on (empty and
+	// zero for other memories)
+	ReflectionWindow string
+	WindowStart      time.Time
+	WindowEnd        time.Time
+
+	// ClassifyConfidence is how sure the heuristic classifier was of Sector
+	// when the memory was added, 0.0 – 1.0, recorded with
+	// Config.RecordClassifyConfidence (nil = not recorded, e.g. a SectorHint
+	// or a classifier that can't report one)
+	ClassifyConfidence *float64
+}
+
+// MediaRef points at media a character "saw" (a screenshot, an item icon).
+// engram never fetches the URI: the caption is what gets embedded, classified,
+// and searched, and the URI is returned for the caller to display.
+type MediaRef struct {
+	URI     string `json:"uri"`
+	Caption string `json:"caption"`
 }
 
 // AddOptions provides the full API for storing memories with temporal context.
@@ -79,23 +119,87 @@ type AddOptions struct {
 	UserID           string
 	UserMessage      string
 	AssistantMessage string
-	SessionID        string   // Optional session identifier
-	ParentID         int64    // Optional parent memory ID (for threading)
-	SectorHint       Sector   // Optional: skip classification
-	Salience         float64  // Optional: override default 0.5
-	Entities         []Entity // Optional: pre-extracted entities
+	SessionID        string     // Optional session identifier
+	ParentID         int64      // Optional parent memory ID (for threading)
+	SectorHint       Sector     // Optional: skip classification
+	Salience         float64    // Optional: override default 0.5
+	Entities         []Entity   // Optional: pre-extracted entities
+	Vector           []float32  // Optional: precomputed embedding (skips the embedder)
+	Valence          float64    // Optional: emotional valence, -1.0 – 1.0 (default 0, neutral)
+	Arousal          float64    // Optional: emotional intensity, 0.0 – 1.0 (default 0, calm)
+	Confidence       float64    // Optional: certainty, 0.0 – 1.0 (default 1, certain), e.g. 0.4 for "I think you mentioned jazz?"
+	RawContent       string     // Optional: store this verbatim instead of the user/assistant exchange (observations, world events)
+	MediaRefs        []MediaRef // Optional: attached media; captions are appended to the searchable content
+	IdempotencyKey   string     // Optional: repeat Adds with the same key (per user) return the first memory's ID
+	DecayLambda      float64    // Optional: decay rate for this memory instead of its sector's (e.g. 0.5 for a one-off promise)
 }
 
+// TieBreaker is the secondary sort key for near-equal Search results.
+type TieBreaker string
+
+const (
+	TieBreakComposite TieBreaker = ""         // Composite score only (default)
+	TieBreakRecency   TieBreaker = "recency"  // Newest first within a band
+	TieBreakSalience  TieBreaker = "salience" // Most salient first within a band
+)
+
 // SearchOptions extends basic search with temporal and session filters.
 type SearchOptions struct {
-	Query     string
-	UserID    string
-	Limit     int
-	Weights   SectorWeights
-	After     *time.Time // Only memories created after this time
-	Before    *time.Time // Only memories created before this time
-	SessionID string     // Filter to a specific session
-	Sectors   []Sector   // Filter to specific sectors
+	Query       string
+	UserID      string
+	Limit       int
+	Weights     SectorWeights
+	After       *time.Time // Only memories created after this time
+	Before      *time.Time // Only memories created before this time
+	SessionID   string     // Filter to a specific session
+	Sectors     []Sector   // Filter to specific sectors
+	QueryVector []float32  // Optional: precomputed query embedding (skips the embedder)
+	ExcludeIDs  []int64    // Never return these memories (e.g. already in the prompt)
+
+	// ExcludeSince drops memories created at or after this time, typically
+	// the start of the current turn, so a reply draws on past context instead
+	// of echoing the exchange just stored
+	ExcludeSince *time.Time
+
+	// ReflectiveBias scales reflective memories' scores by (1 + bias),
+	// independent of Weights: 0 = neutral, -1 = fully suppressed, 0.5 = 1.5x.
+	// A negative bias also exempts reflections from the high-salience guarantee.
+	ReflectiveBias float64
+
+	// QuerySectorAffinity boosts memories whose sector matches the query's own
+	// heuristic sector (e.g. a how-to query favors procedural memories) by
+	// (1 + affinity). 0 = off. Queries with no sector signal are unaffected.
+	QuerySectorAffinity float64
+
+	// TieBreaker orders results whose composite scores are within TieEpsilon
+	// of each other, e.g. TieBreakRecency so the newest of several equally
+	// relevant memories leads (default TieBreakComposite: score order only).
+	TieBreaker TieBreaker
+	TieEpsilon float64 // Width of a relevance band (default 0.02)
+
+	// BalanceAcrossSectors reserves a result for the best memory of each of
+	// Sectors (when one exists) before filling the rest by score, so a union
+	// of sectors isn't monopolized by the most similar one
+	BalanceAcrossSectors bool
+
+	// QueryExpansions are paraphrases of a terse Query (e.g. "back" ->
+	// "lower back pain", "coming back home"). Each is embedded and a memory's
+	// similarity is the best match across the query and its expansions.
+	QueryExpansions []string
+
+	// MinConfidence drops memories the character is less sure of than this;
+	// WeightByConfidence instead scales each score by its memory's Confidence,
+	// so uncertain memories rank lower but can still surface.
+	MinConfidence      float64
+	WeightByConfidence bool
+
+	// ExpandContext bundles each result with up to this many turns before and
+	// after it in the same session, following the ParentID chain, so a terse
+	// turn ("I miss it already") comes back with what it was answering. See
+	// SearchResult.Context. 0 = off.
+	ExpandContext int
+
+	querySector Sector // resolved from Query when QuerySectorAffinity > 0
 }
 
 // SearchResult is a scored memory returned from retrieval.
@@ -103,6 +207,28 @@ type SearchResult struct {
 	Memory
 	CompositeScore float64
 	Similarity     float64
+	Injected       bool // Added by the high-salience guarantee rather than ranked in on score
+
+	// Context is the result's session neighborhood with SearchOptions.ExpandContext:
+	// the surrounding turns and the result itself, oldest first (nil = off, or
+	// the memory has no session)
+	Context []Memory
+}
+
+// ContextOptions controls how BuildContext assembles a prompt context window.
+type ContextOptions struct {
+	SessionID     string        // Session to draw recent turns from (default: user's last session)
+	SessionMaxAge time.Duration // Ignore a last session with no activity this recent (0 = any age)
+	RecentTurns   int           // How many of the latest session turns to include (default 5)
+	Limit         int           // How many long-term memories to retrieve (default 5)
+	Weights       SectorWeights // Personality weights for retrieval (nil = defaults)
+}
+
+// ContextResult is an assembled context window: what was just said plus
+// relevant long-term memories, with no memory appearing in both.
+type ContextResult struct {
+	RecentTurns []Memory       // Latest session turns, oldest first
+	Memories    []SearchResult // Retrieved long-term memories, best first
 }
 
 // Entity represents an extracted entity for the waypoint graph.
@@ -111,39 +237,333 @@ type Entity struct {
 	Type string // "person", "music_artist", "song", "topic", "place"
 }
 
+// UserConfig is a character's per-user identity, stored alongside its memories
+// so integrations don't have to re-send it on every call.
+type UserConfig struct {
+	Persona       string        `json:"persona,omitempty"`        // Default CharacterContext for Reflect
+	SectorWeights SectorWeights `json:"sector_weights,omitempty"` // Default Weights for Search
+	KnownEntities []KnownEntity `json:"known_entities,omitempty"` // Domain entities for integrations to extract
+}
+
+// AddResult is delivered by AddAsync once the memory is stored (or fails).
+type AddResult struct {
+	ID  int64
+	Err error
+}
+
+// EmotionBucket summarizes a user's emotional-sector memories in one time bucket.
+type EmotionBucket struct {
+	Start      time.Time // Inclusive start of the bucket
+	Count      int       // Emotional memories created in the bucket (density)
+	AvgValence float64   // Mean valence of those memories
+}
+
+// EntityPair is two waypoint entities that co-occur in a user's memories.
+type EntityPair struct {
+	A, B      Entity
+	Count     int     // Number of memories mentioning both entities
+	MemoryIDs []int64 // Example memories mentioning both (capped)
+}
+
+// IntegrityReport counts orphaned rows found (or removed) by an integrity check.
+type IntegrityReport struct {
+	OrphanedVectors      int // Vectors whose memory no longer exists
+	OrphanedAssociations int // Associations whose memory or waypoint no longer exists
+	OrphanedWaypoints    int // Waypoints with no associations
+}
+
+// Footprint is how much storage a user's memories occupy, for quotas and
+// billing. Archived memories count until they are purged.
+type Footprint struct {
+	Memories       int   // Memory rows
+	Vectors        int   // Stored embeddings
+	VectorBytes    int64 // Total size of the embedding blobs
+	TextBytes      int64 // Content, summary and exchange text
+	Associations   int   // Memory-waypoint links
+	Waypoints      int   // Distinct waypoints linked (may be shared with other users)
+	EstimatedBytes int64 // TextBytes + VectorBytes + a per-row overhead estimate
+}
+
+// ParentPolicy controls what happens to a memory's children (by ParentID)
+// when it is deleted.
+type ParentPolicy string
+
+const (
+	ParentStitch ParentPolicy = "stitch" // Repoint children at the deleted memory's parent (default)
+	ParentDetach ParentPolicy = "detach" // Set children's ParentID to 0
+)
+
+// DimensionMismatchPolicy controls what Search does when the query embedding's
+// dimension differs from that of most stored vectors, e.g. after the embedding
+// model was swapped mid-life.
+type DimensionMismatchPolicy string
+
+const (
+	DimensionMismatchWarn    DimensionMismatchPolicy = "warn"    // Log and search anyway; mismatched vectors score 0 (default)
+	DimensionMismatchFail    DimensionMismatchPolicy = "fail"    // Log and return no results
+	DimensionMismatchReembed DimensionMismatchPolicy = "reembed" // Log, search anyway, and re-embed the user's memories in the background
+)
+
+// EmptyQueryPolicy controls what Search does with an empty (or all-whitespace)
+// query and no QueryVector.
+type EmptyQueryPolicy string
+
+const (
+	EmptyQueryContext EmptyQueryPolicy = "context" // Return the most salient memories, most recent first among equals (default)
+	EmptyQueryNone    EmptyQueryPolicy = "none"    // Return no results
+	EmptyQueryEmbed   EmptyQueryPolicy = "embed"   // Embed the empty string like any other query
+)
+
+// InjectionPolicy controls where Search puts the high-salience memories it
+// guarantees a place in the results.
+type InjectionPolicy string
+
+const (
+	InjectReplaceLowest InjectionPolicy = "replace_lowest" // Replace the lowest-scored ranked results, keeping Limit (default)
+	InjectAppend        InjectionPolicy = "append"         // Append after the ranked results, exceeding Limit
+)
+
 // Config holds Engram initialization parameters.
 type Config struct {
 	// Storage
-	DBPath             string        // Path to SQLite file (default: ./data/engram.db)
-	MaxMemoriesPerUser int           // Default 500
-	MinDecayScore      float64       // Memories below this are deleted (default 0.01)
+	DBPath             string  // Path to SQLite file (default: ./data/engram.db)
+	MaxMemoriesPerUser int     // Default 500
+	MaxAddsPerMinute   int     // Per-user Add rate limit (0 = unlimited, default)
+	MaxAsyncAdds       int     // Concurrent AddAsync workers (default 4)
+	MinDecayScore      float64 // Memories below this are deleted (default 0.01)
+
+	// ReadReplica opens a second, read-only connection (switching the database
+	// to WAL mode) for Search's reads, so searches don't queue behind Add and
+	// decay writes on the single writer connection
+	ReadReplica bool
+
+	// RecordClassifyConfidence stores the heuristic classifier's confidence in
+	// each classified memory's sector (Memory.ClassifyConfidence), so
+	// low-confidence classifications can be reviewed or re-tuned later
+	RecordClassifyConfidence bool
 
 	// Providers (nil = use defaults)
 	EmbeddingProvider EmbeddingProvider
 	Classifier        SectorClassifier
 	EntityExtractor   EntityExtractor
 
+	// ContradictionChecker confirms conflicts for FindContradictions (nil =
+	// return every sufficiently similar memory for the caller to judge)
+	ContradictionChecker ContradictionChecker
+
+	// SyncReclassify makes Add wait for LLMClassifier reclassification instead
+	// of queueing it, so the stored sector is final when Add returns
+	SyncReclassify bool
+
+	// ExchangeUserWeight classifies exchanges from their halves separately,
+	// giving the user's message this share (0-1) of the decision, since the
+	// user's intent usually sets a memory's nature: "I'm so nervous" answered
+	// with exam facts stays emotional. Needs an ExchangeClassifier
+	// (0 = classify the joined content, default).
+	ExchangeUserWeight float64
+
+	// RecordSessionMarkers makes the first Add of a new session, after earlier
+	// activity, also store an episodic "session started after <gap>" memory, so
+	// a character can recall that the user came back after two weeks. Markers
+	// are neither embedded nor entity-linked.
+	RecordSessionMarkers bool
+
+	// EventLog records every memory operation (add, reinforce, reclassify,
+	// reflect, delete) in an append-only events table, readable with
+	// ReplayEvents, for audit and rebuilding derived indexes
+	EventLog bool
+
+	// MaxEntitiesPerMemory caps extracted entities per memory, keeping the
+	// extractor's highest-priority ones (default 8)
+	MaxEntitiesPerMemory int
+
+	// MutualEntityWeight is the association weight for entities named in both
+	// the user and assistant messages, a topic the character engaged with
+	// rather than one the user merely mentioned (0 = no boost; others get 0.5)
+	MutualEntityWeight float64
+
+	// RedactFunc rewrites text before it is stored, e.g. to mask PII. It is
+	// applied to the content, summary and raw exchange messages before
+	// classification, embedding and entity extraction, so none of them see
+	// the unredacted text (nil = store as given)
+	RedactFunc func(content string) string
+
+	// ContentNormalizer rewrites text before it is embedded, for stored
+	// memories and queries alike, so surface variation ("Hey, I'm back." vs
+	// "hey im back") doesn't move the vector. Stored content is unchanged.
+	// (default NormalizeWhitespace)
+	ContentNormalizer func(text string) string
+
 	// Scoring (nil = use defaults)
 	ScoringWeights *ScoringWeights
 
+	// SearchCandidateCap bounds how many of a user's memories Search loads and
+	// scores: half the most recent, the rest the most salient. Trades recall
+	// for bounded memory on very large users (0 = load all, default)
+	SearchCandidateCap int
+
+	// EntityTypeRecallBoost multiplies the composite score of memories linked to
+	// entities of these types, e.g. {"quest_objective": 1.5}. A memory linked to
+	// several boosted types gets the largest multiplier.
+	EntityTypeRecallBoost map[string]float64
+
+	// SimilarityTransform reshapes each candidate's similarity before it enters
+	// the composite score, e.g. stretching the narrow high band some models
+	// produce so ranking stays sensitive. SearchResult.Similarity stays raw.
+	// Should be monotonic on [0, 1] (nil = identity)
+	SimilarityTransform func(float64) float64
+
+	// QueryEntityBoost is added to the similarity (capped at 1) of memories
+	// linked to an entity the query names, as found by the EntityExtractor: a
+	// query about "Valdris" favors memories linked to Valdris over ones merely
+	// embedded nearby (0 = off, default)
+	QueryEntityBoost float64
+
+	// SimilarityMetric compares query and memory vectors (default MetricCosine).
+	// Dot and euclidean scores are normalized into cosine's range for scoring.
+	SimilarityMetric SimilarityMetric
+
+	// LinkHopWeight is the link weight one shared waypoint gives a graph-expanded
+	// memory; each further shared waypoint closes the remaining gap to 1.0 by the
+	// same fraction (default 0.8; values above 1 are capped at 1)
+	LinkHopWeight float64
+
+	// ExpansionSectors limits which memories waypoint expansion may boost via
+	// the link term, e.g. procedural+semantic so shared entities don't drag
+	// emotional memories into technique queries (nil = all sectors)
+	ExpansionSectors []Sector
+
+	// ReinforceCooldown is the minimum gap between salience boosts for the same
+	// memory; repeat retrievals inside the window don't reinforce (0 = no cooldown)
+	ReinforceCooldown time.Duration
+
+	// DegradedFallback makes Search fall back to lexical keyword scoring when
+	// the query can't be embedded, instead of returning no memories
+	DegradedFallback bool
+
+	// Clock supplies the current time for timestamps, decay, and recency
+	// (nil = system clock). Tests can pass a FakeClock.
+	Clock Clock
+
+	// ParentOnDelete decides how conversation chains survive a deleted memory
+	// (default ParentStitch, so GetThread skips the gap instead of truncating)
+	ParentOnDelete ParentPolicy
+
+	// OnDimensionMismatch decides what Search does when the query embedding
+	// and the stored vectors disagree on dimension (default DimensionMismatchWarn)
+	OnDimensionMismatch DimensionMismatchPolicy
+
+	// HighSalienceInjection decides whether memories injected by Search's
+	// high-salience guarantee displace ranked results or are added beyond
+	// the limit (default InjectReplaceLowest)
+	HighSalienceInjection InjectionPolicy
+
+	// OnEmptyQuery decides what Search returns for an empty query, which
+	// providers either reject or embed into a meaningless vector
+	// (default EmptyQueryContext)
+	OnEmptyQuery EmptyQueryPolicy
+
 	// Decay
 	DecayInterval time.Duration      // Default 12h
+	DecayOnWrite  bool               // Also start a background sweep on Add when none has run within DecayInterval
+	DecayOnRead   bool               // Score Search with decay computed as of now, not the last sweep's stored score
 	DecayRates    map[Sector]float64 // Per-sector lambda overrides (nil = defaults)
 
+	// DecayYield pauses between per-user decay transactions so live searches
+	// and adds get the connection promptly during large sweeps (0 = no pause;
+	// requests still interleave between users)
+	DecayYield time.Duration
+
+	// DecayDeleteBatch is how many dead memories the sweep deletes per
+	// statement (default 500; capped at SQLite's 999 bound variables)
+	DecayDeleteBatch int
+
+	// ArchiveRetention is how long archived memories are kept before the decay
+	// sweep hard-deletes them (0 = keep until restored or deleted explicitly)
+	ArchiveRetention time.Duration
+
+	// SectorMinDecayFloor clamps decay scores for the listed sectors so their
+	// memories never fall below MinDecayScore and are never pruned by the sweep.
+	SectorMinDecayFloor map[Sector]float64
+
+	// EmotionalArousalDecay slows decay of intense emotional memories: an
+	// emotional-sector memory's rate is divided by (1 + EmotionalArousalDecay *
+	// Arousal), so at 3 a fully aroused memory decays 4x slower (0 = off, default)
+	EmotionalArousalDecay float64
+
 	// Reflection (explicit opt-in — never auto-constructed)
 	ReflectionProvider ReflectionProvider
 	ReflectionInterval time.Duration // 0 = no automatic reflection (default)
 
+	// ReflectEveryNMemories reflects a user once that many new non-reflective
+	// memories have been added for them since their last triggered reflection
+	// (0 = off, default). Works alongside or instead of ReflectionInterval.
+	ReflectEveryNMemories int
+
+	// ReflectionConcurrency is how many users a reflection cycle reflects at
+	// once (default 1). ReflectionCallsPerMinute caps provider calls across all
+	// of them, and manual Reflect calls, to respect quotas (0 = unlimited).
+	ReflectionConcurrency    int
+	ReflectionCallsPerMinute int
+
+	// ReflectionPromptTemplate overrides the GeminiReflector prompt (see
+	// WithReflectionPromptTemplate for placeholders). Ignored for other providers.
+	ReflectionPromptTemplate string
+
 	// Legacy / convenience: used to construct default GeminiEmbedder + HeuristicClassifier
 	GeminiAPIKey   string
 	EmbedDimension int // Default 768
 
+	// AutoDetectDimension makes the embedder adopt the dimension of its first
+	// successful embedding (warning if it differs from the requested one) and
+	// records it in the database, so later opens reject mismatched vectors.
+	AutoDetectDimension bool
+
+	// EmbedTaskTypes sets the task type passed to the embedder for stored
+	// memories of each sector (default "RETRIEVAL_DOCUMENT"), e.g. a
+	// classification hint for emotional memories. QueryTaskType does the same
+	// for search queries (default "RETRIEVAL_QUERY").
+	EmbedTaskTypes map[Sector]string
+	QueryTaskType  string
+
 	// resolved holds the merged decay rates after ApplyDefaults
 	decayRates map[Sector]float64
 	// resolved scoring weights
 	scoringWeights ScoringWeights
 }
 
+// ResolvedConfig is the configuration an Engram actually runs with, after
+// ApplyDefaults merged overrides with defaults. Returned by EffectiveConfig;
+// its maps are copies, so changing them has no effect.
+type ResolvedConfig struct {
+	DBPath               string
+	MaxMemoriesPerUser   int
+	MaxAddsPerMinute     int
+	MaxAsyncAdds         int
+	MaxEntitiesPerMemory int
+	SearchCandidateCap   int
+	EmbedDimension       int // The embedder's dimension (detected, if AutoDetectDimension)
+
+	ScoringWeights   ScoringWeights
+	SimilarityMetric SimilarityMetric
+	LinkHopWeight    float64
+	QueryTaskType    string
+
+	DecayInterval       time.Duration
+	DecayRates          map[Sector]float64 // Defaults merged with Config.DecayRates
+	SectorMinDecayFloor map[Sector]float64
+	MinDecayScore       float64
+
+	ReflectionInterval    time.Duration
+	ReflectEveryNMemories int
+	ReflectionConcurrency int
+
+	ParentOnDelete        ParentPolicy
+	OnDimensionMismatch   DimensionMismatchPolicy
+	HighSalienceInjection InjectionPolicy
+	OnEmptyQuery          EmptyQueryPolicy
+}
+
 // ApplyDefaults fills zero-valued fields with sensible defaults.
 func (c *Config) ApplyDefaults() {
 	if c.DBPath == "" {
@@ -161,6 +581,44 @@ func (c *Config) ApplyDefaults() {
 	if c.MinDecayScore == 0 {
 		c.MinDecayScore = 0.01
 	}
+	if c.MaxAsyncAdds <= 0 {
+		c.MaxAsyncAdds = 4
+	}
+	if c.ReflectionConcurrency <= 0 {
+		c.ReflectionConcurrency = 1
+	}
+	if c.MaxEntitiesPerMemory <= 0 {
+		c.MaxEntitiesPerMemory = 8
+	}
+	if c.ParentOnDelete == "" {
+		c.ParentOnDelete = ParentStitch
+	}
+	if c.OnDimensionMismatch == "" {
+		c.OnDimensionMismatch = DimensionMismatchWarn
+	}
+	if c.HighSalienceInjection == "" {
+		c.HighSalienceInjection = InjectReplaceLowest
+	}
+	if c.OnEmptyQuery == "" {
+		c.OnEmptyQuery = EmptyQueryContext
+	}
+	if c.Clock == nil {
+		c.Clock = realClock{}
+	}
+	if c.SimilarityMetric == "" {
+		c.SimilarityMetric = MetricCosine
+	}
+	if c.LinkHopWeight <= 0 {
+		c.LinkHopWeight = defaultLinkHopWeight
+	} else if c.LinkHopWeight > 1 {
+		c.LinkHopWeight = 1
+	}
+	if c.QueryTaskType == "" {
+		c.QueryTaskType = "RETRIEVAL_QUERY"
+	}
+	if c.ContentNormalizer == nil {
+		c.ContentNormalizer = NormalizeWhitespace
+	}
 
 	// Resolve decay rates: defaults merged with overrides
 	c.decayRates = DefaultDecayRates()
@@ -175,3 +633,11 @@ func (c *Config) ApplyDefaults() {
 		c.scoringWeights = DefaultScoringWeights()
 	}
 }
+
+// documentTaskType is the embedding task type for a stored memory in sector.
+func (c Config) documentTaskType(sector Sector) string {
+	if tt := c.EmbedTaskTypes[sector]; tt != "" {
+		return tt
+	}
+	return "RETRIEVAL_DOCUMENT"
+}
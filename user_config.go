@@ -0,0 +1,47 @@
+package engram
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SetUserConfig stores a character's persona config for a user. Reflect uses
+// its Persona when no CharacterContext is passed, and Search uses its
+// SectorWeights when no Weights are passed.
+func (cm *Engram) SetUserConfig(userID string, uc UserConfig) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	if userID == "" {
+		return fmt.Errorf("engram: set user config: empty user ID")
+	}
+	data, err := json.Marshal(uc)
+	if err != nil {
+		return fmt.Errorf("engram: marshal user config: %w", err)
+	}
+	if err := cm.store.SetUserConfig(userID, data); err != nil {
+		return fmt.Errorf("engram: set user config: %w", err)
+	}
+	return nil
+}
+
+// GetUserConfig returns a user's stored config, or nil if none has been set.
+func (cm *Engram) GetUserConfig(userID string) (*UserConfig, error) {
+	if err := cm.enter(); err != nil {
+		return nil, err
+	}
+	defer cm.leave()
+	data, err := cm.store.GetUserConfig(userID)
+	if err != nil {
+		return nil, fmt.Errorf("engram: get user config: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var uc UserConfig
+	if err := json.Unmarshal(data, &uc); err != nil {
+		return nil, fmt.Errorf("engram: decode user config: %w", err)
+	}
+	return &uc, nil
+}
@@ -0,0 +1,57 @@
+package engram
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUserConfigRoundTrip(t *testing.T) {
+	cm := testEngram(t, nil, nil)
+
+	if uc, err := cm.GetUserConfig("lily:p1"); err != nil || uc != nil {
+		t.Fatalf("expected no config before set, got %v, %v", uc, err)
+	}
+
+	want := UserConfig{
+		Persona:       "Lily, a bartender who notices everything",
+		SectorWeights: SectorWeights{SectorEmotional: 1.5, SectorProcedural: 0.5},
+		KnownEntities: []KnownEntity{{Text: "Aphex Twin", Type: "music_artist"}},
+	}
+	if err := cm.SetUserConfig("lily:p1", want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cm.GetUserConfig("lily:p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Persona != want.Persona {
+		t.Fatalf("expected persona %q, got %v", want.Persona, got)
+	}
+	if got.SectorWeights[SectorEmotional] != 1.5 || len(got.KnownEntities) != 1 {
+		t.Errorf("expected weights and entities to round-trip, got %+v", got)
+	}
+}
+
+func TestReflectUsesStoredPersona(t *testing.T) {
+	mock := &mockReflector{}
+	cm := testEngram(t, mock, nil)
+
+	for i := 0; i < 5; i++ {
+		cm.store.InsertMemory(Memory{Content: "memory", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1"})
+	}
+	cm.SetUserConfig("u1", UserConfig{Persona: "A wise old sifu"})
+
+	if _, err := cm.Reflect(context.Background(), ReflectOptions{UserID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.charCtx != "A wise old sifu" {
+		t.Errorf("expected stored persona as character context, got %q", mock.charCtx)
+	}
+
+	// An explicit context still wins
+	cm.Reflect(context.Background(), ReflectOptions{UserID: "u1", CharacterContext: "override"})
+	if mock.charCtx != "override" {
+		t.Errorf("expected explicit character context to override, got %q", mock.charCtx)
+	}
+}
@@ -0,0 +1,167 @@
+package engram
+
+import (
+	"fmt"
+	"sync"
+)
+
+// vectorCache holds decoded embeddings for warmed users, so Search loads only
+// the memory rows and skips the vector blobs. Vectors that change (re-embeds,
+// merges) are re-added; deleted memories are evicted by ID where the delete
+// knows them (merges) and pruned against the stored IDs where it doesn't
+// (decay sweeps, caps, purges). A nil entry records that a memory has no
+// vector.
+type vectorCache struct {
+	mu    sync.RWMutex
+	users map[string]map[int64]cachedVector
+}
+
+// cachedVector is a stored embedding with its norm.
+type cachedVector struct {
+	vec  []float32
+	norm float64
+}
+
+func newVectorCache() *vectorCache {
+	return &vectorCache{users: make(map[string]map[int64]cachedVector)}
+}
+
+// warm replaces userID's cached vectors with those of mwvs.
+func (c *vectorCache) warm(userID string, mwvs []memoryWithVector) {
+	vecs := make(map[int64]cachedVector, len(mwvs))
+	for _, m := range mwvs {
+		vecs[m.ID] = cachedVector{m.Vector, m.Norm}
+	}
+	c.mu.Lock()
+	c.users[userID] = vecs
+	c.mu.Unlock()
+}
+
+// warmed reports whether userID has been warmed.
+func (c *vectorCache) warmed(userID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.users[userID]
+	return ok
+}
+
+// add records a newly stored memory's vector (nil = none) for a warmed user.
+func (c *vectorCache) add(userID string, memoryID int64, vec []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if vecs, ok := c.users[userID]; ok {
+		vecs[memoryID] = cachedVector{vec, vectorNorm(vec)}
+	}
+}
+
+// evict forgets the vectors of deleted memories of userID.
+func (c *vectorCache) evict(userID string, memoryIDs []int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if vecs, ok := c.users[userID]; ok {
+		for _, id := range memoryIDs {
+			delete(vecs, id)
+		}
+	}
+}
+
+// prune evicts userID's cached vectors whose memories no longer exist, after
+// deletes that don't report which memories they removed.
+func (c *vectorCache) prune(store *Store, userID string) error {
+	if !c.warmed(userID) {
+		return nil
+	}
+	ids, err := store.GetUserMemoryIDs(userID)
+	if err != nil {
+		return fmt.Errorf("engram: prune cached vectors: %w", err)
+	}
+	live := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		live[id] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.users[userID] {
+		if !live[id] {
+			delete(c.users[userID], id)
+		}
+	}
+	return nil
+}
+
+// pruneAll prunes every warmed user, e.g. after a decay sweep.
+func (c *vectorCache) pruneAll(store *Store) error {
+	c.mu.RLock()
+	userIDs := make([]string, 0, len(c.users))
+	for userID := range c.users {
+		userIDs = append(userIDs, userID)
+	}
+	c.mu.RUnlock()
+
+	var err error
+	for _, userID := range userIDs {
+		if pruneErr := c.prune(store, userID); pruneErr != nil {
+			err = pruneErr
+		}
+	}
+	return err
+}
+
+// drop forgets userID's vectors.
+func (c *vectorCache) drop(userID string) {
+	c.mu.Lock()
+	delete(c.users, userID)
+	c.mu.Unlock()
+}
+
+// fill sets each candidate's Vector from the cache, loading any memories the
+// cache hasn't seen (e.g. reflections stored since warming) from the store.
+func (c *vectorCache) fill(store *Store, userID string, candidates []memoryWithVector) error {
+	var missing []int64
+	c.mu.RLock()
+	vecs := c.users[userID]
+	for i := range candidates {
+		cv, ok := vecs[candidates[i].ID]
+		if !ok {
+			missing = append(missing, candidates[i].ID)
+			continue
+		}
+		candidates[i].Vector, candidates[i].Norm = cv.vec, cv.norm
+	}
+	c.mu.RUnlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	loaded, err := store.GetVectors(missing)
+	if err != nil {
+		return fmt.Errorf("engram: load uncached vectors: %w", err)
+	}
+	for i := range candidates {
+		if vec, ok := loaded[candidates[i].ID]; ok {
+			candidates[i].Vector, candidates[i].Norm = vec, vectorNorm(vec)
+		}
+	}
+	for _, id := range missing {
+		c.add(userID, id, loaded[id])
+	}
+	return nil
+}
+
+// Warm preloads userID's memory vectors into memory, so the first Search
+// doesn't pay for loading and decoding them. Later Searches for the user read
+// vectors from the cache but still query the memory rows, which carry the
+// salience, decay and archive state scoring needs. Call it again to refresh;
+// ClearUser forgets the user.
+func (cm *Engram) Warm(userID string) error {
+	if err := cm.enter(); err != nil {
+		return err
+	}
+	defer cm.leave()
+	mwvs, err := cm.store.GetMemoriesWithVectors(userID)
+	if err != nil {
+		return fmt.Errorf("engram: warm %s: %w", userID, err)
+	}
+	cm.vectors.warm(userID, mwvs)
+	return nil
+}
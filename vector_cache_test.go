@@ -0,0 +1,96 @@
+package engram
+
+import "testing"
+
+func TestWarmServesVectorsFromCache(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+
+	tokyo, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "tokyo trip", Vector: []float32{1, 0, 0}})
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "my cat", Vector: []float32{0, 1, 0}})
+
+	if err := cm.Warm("u1"); err != nil {
+		t.Fatal(err)
+	}
+	// Added after warming: the cache must pick it up from the write path
+	later, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "tokyo again", Vector: []float32{0.9, 0.44, 0}})
+
+	// With the vectors gone from the database, only the cache can score these
+	if _, err := cm.store.db.Exec(`DELETE FROM vectors`); err != nil {
+		t.Fatal(err)
+	}
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}, Limit: 2})
+	if len(results) != 2 || results[0].ID != tokyo || results[1].ID != later {
+		t.Fatalf("expected cached vectors to rank tokyo memories first, got %+v", results)
+	}
+	if results[0].Similarity < 0.99 {
+		t.Errorf("expected similarity from cached vector, got %.3f", results[0].Similarity)
+	}
+}
+
+func TestWarmLoadsUncachedVectors(t *testing.T) {
+	cm := testEngram(t, nil, &mockEmbedder{dim: 3})
+	if err := cm.Warm("u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stored behind the cache's back, as Reflect does
+	id, _ := cm.store.InsertMemory(Memory{Content: "insight", Sector: SectorReflective, Salience: 0.7, UserID: "u1", Summary: "i"})
+	cm.store.InsertVector(id, SectorReflective, []float32{1, 0, 0})
+
+	results := cm.SearchWithOptions(SearchOptions{UserID: "u1", QueryVector: []float32{1, 0, 0}})
+	if len(results) != 1 || results[0].Similarity < 0.99 {
+		t.Fatalf("expected the uncached vector loaded on demand, got %+v", results)
+	}
+
+	cm.ClearUser("u1")
+	if cm.vectors.warmed("u1") {
+		t.Error("expected ClearUser to drop the user's cached vectors")
+	}
+}
+
+func TestCachedVectorsEvictedWithTheirMemories(t *testing.T) {
+	cm := testEngramConfig(t, Config{EmbeddingProvider: &mockEmbedder{dim: 3}, MaxMemoriesPerUser: 3})
+	cached := func() map[int64]cachedVector {
+		cm.vectors.mu.RLock()
+		defer cm.vectors.mu.RUnlock()
+		return cm.vectors.users["u1"]
+	}
+
+	var ids []int64
+	for _, content := range []string{"first", "second", "third"} {
+		id, _ := cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: content, Vector: []float32{1, 0, 0}, SectorHint: SectorSemantic})
+		ids = append(ids, id)
+	}
+	if err := cm.Warm("u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merge: the merged memory's vector goes with it
+	if err := cm.MergeMemories(ids[0], ids[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cached()[ids[1]]; ok {
+		t.Errorf("expected merged memory #%d evicted", ids[1])
+	}
+
+	// Cap: two more adds push the lowest-scored memory out
+	cm.store.db.Exec(`UPDATE memories SET decay_score = 0.01 WHERE id = ?`, ids[2])
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "fourth", Vector: []float32{1, 0, 0}, SectorHint: SectorSemantic})
+	cm.AddWithOptions(AddOptions{UserID: "u1", RawContent: "fifth", Vector: []float32{1, 0, 0}, SectorHint: SectorSemantic})
+	if _, ok := cached()[ids[2]]; ok {
+		t.Errorf("expected capped memory #%d evicted", ids[2])
+	}
+
+	// Decay sweep: deletes the cache doesn't hear about are pruned afterwards
+	cm.store.db.Exec(`DELETE FROM memories WHERE id = ?`, ids[0])
+	if err := cm.runDecaySweep(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cached()[ids[0]]; ok {
+		t.Errorf("expected deleted memory #%d pruned after the sweep", ids[0])
+	}
+	if len(cached()) != 2 {
+		t.Errorf("expected the two live memories cached, got %d", len(cached()))
+	}
+}
@@ -1,6 +1,7 @@
 package engram
 
 import (
+	"math"
 	"regexp"
 	"strings"
 )
@@ -14,9 +15,52 @@ type KnownEntity struct {
 
 // DefaultEntityExtractor pulls entities from memory content using heuristics.
 // Detects bracket names, quoted strings, capitalized phrases, and caller-provided known entities.
+// Entities are returned in priority order (known > person > quoted > proper noun),
+// so callers capping the count keep the most trustworthy ones.
 // Implements EntityExtractor.
+//
+// Capitalized phrases are the noisiest source, so they are filtered: they are
+// split at stopwords ("Last", "Every"), each remaining run must have
+// MinProperTokens–MaxProperTokens words, and phrases made only of generic
+// words ("Jazz Scene") are dropped. Zero-valued fields use the defaults.
 type DefaultEntityExtractor struct {
 	KnownEntities []KnownEntity
+
+	MinProperTokens    int      // Minimum words in a capitalized phrase after splitting at stopwords (default 2)
+	MaxProperTokens    int      // Maximum words in a capitalized phrase (default 4)
+	ProperStopwords    []string // Words capitalized phrases are split at (nil = DefaultProperStopwords)
+	ProperGenericWords []string // A phrase made only of these is not a proper noun (nil = DefaultProperGenericWords)
+
+	// RequireProperConfirmation only keeps a capitalized phrase that is
+	// corroborated: mentioned more than once, or sharing a word with an entity
+	// found another way (known, bracketed, or quoted).
+	RequireProperConfirmation bool
+}
+
+// DefaultProperStopwords are words that often appear in a capitalized phrase
+// without being part of a name ("Last Weekend", "Every Friday").
+func DefaultProperStopwords() []string {
+	return []string{
+		"the", "a", "an", "this", "that", "these", "those", "my", "your", "our", "their",
+		"last", "next", "every", "each", "some", "any", "all", "most", "many", "few",
+		"today", "tonight", "yesterday", "tomorrow", "maybe", "also", "just", "then",
+		"and", "but", "or", "so", "if", "when", "while", "after", "before", "since",
+	}
+}
+
+// DefaultProperGenericWords are everyday nouns that title-casing turns into
+// fake proper nouns ("Jazz Scene", "Weekend Plans"). A phrase needs at least
+// one word outside this list, so "Harajuku Station" still counts.
+func DefaultProperGenericWords() []string {
+	return []string{
+		"morning", "afternoon", "evening", "night", "day", "days", "week", "weekend", "month", "year", "time",
+		"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+		"music", "jazz", "rock", "pop", "blues", "scene", "song", "songs", "band", "album", "show", "movie",
+		"game", "games", "book", "art", "food", "drink", "drinks", "coffee", "party", "plans", "trip",
+		"station", "street", "road", "city", "town", "park", "bar", "club", "school", "work", "office",
+		"home", "house", "room", "place", "world", "life", "people", "friend", "friends", "family",
+		"good", "great", "new", "old", "big", "little", "best", "first", "happy", "sad", "really", "very",
+	}
 }
 
 // Extract returns entities found in the content.
@@ -34,33 +78,39 @@ func (e *DefaultEntityExtractor) Extract(content string) []Entity {
 		entities = append(entities, Entity{Text: text, Type: entityType})
 	}
 
-	// 1. Player names in brackets: [PlayerName]: message
+	// 1. Known entities (domain-specific, provided by caller)
+	if len(e.KnownEntities) > 0 {
+		lower := strings.ToLower(content)
+		for _, known := range e.KnownEntities {
+			if strings.Contains(lower, strings.ToLower(known.Text)) {
+				add(known.Text, known.Type)
+			}
+		}
+	}
+
+	// 2. Player names in brackets: [PlayerName]: message
 	bracketRe := regexp.MustCompile(`\[([A-Za-z0-9_]+)\]`)
 	for _, match := range bracketRe.FindAllStringSubmatch(content, -1) {
 		add(match[1], "person")
 	}
 
-	// 2. Quoted strings (potential song names, topics, etc.)
+	// 3. Quoted strings (potential song names, topics, etc.)
 	quoteRe := regexp.MustCompile(`"([^"]{2,40})"`)
 	for _, match := range quoteRe.FindAllStringSubmatch(content, -1) {
 		add(match[1], "topic")
 	}
 
-	// 3. Known entities (domain-specific, provided by caller)
-	if len(e.KnownEntities) > 0 {
-		lower := strings.ToLower(content)
-		for _, known := range e.KnownEntities {
-			if strings.Contains(lower, strings.ToLower(known.Text)) {
-				add(known.Text, known.Type)
-			}
-		}
-	}
-
 	// 4. Capitalized multi-word phrases (potential proper nouns, not at sentence start)
 	properRe := regexp.MustCompile(`(?:^|[.!?]\s+|\s)([A-Z][a-z]+(?:\s+[A-Z][a-z]+)+)`)
+	corroborating := len(entities)
 	for _, match := range properRe.FindAllStringSubmatch(content, 5) {
-		text := strings.TrimSpace(match[1])
-		if !isCommonPhrase(text) {
+		for _, text := range e.properNouns(strings.TrimSpace(match[1])) {
+			if isCommonPhrase(text) {
+				continue
+			}
+			if e.RequireProperConfirmation && !properConfirmed(text, content, entities[:corroborating]) {
+				continue
+			}
 			add(text, "topic")
 		}
 	}
@@ -68,6 +118,81 @@ func (e *DefaultEntityExtractor) Extract(content string) []Entity {
 	return entities
 }
 
+// properNouns splits a capitalized phrase at stopwords ("Harajuku Station
+// Last Weekend" → "Harajuku Station", "Weekend") and returns the runs that
+// plausibly name something.
+func (e *DefaultEntityExtractor) properNouns(phrase string) []string {
+	stopwords := e.ProperStopwords
+	if stopwords == nil {
+		stopwords = DefaultProperStopwords()
+	}
+	generic := e.ProperGenericWords
+	if generic == nil {
+		generic = DefaultProperGenericWords()
+	}
+	minTokens, maxTokens := e.MinProperTokens, e.MaxProperTokens
+	if minTokens <= 0 {
+		minTokens = 2
+	}
+	if maxTokens <= 0 {
+		maxTokens = 4
+	}
+
+	var names []string
+	var run []string
+	flush := func() {
+		if len(run) >= minTokens && len(run) <= maxTokens && !allFold(generic, run) {
+			names = append(names, strings.Join(run, " "))
+		}
+		run = nil
+	}
+	for _, w := range strings.Fields(phrase) {
+		if containsFold(stopwords, w) {
+			flush()
+			continue
+		}
+		run = append(run, w)
+	}
+	flush()
+	return names
+}
+
+// allFold reports whether every word is in list, ignoring case.
+func allFold(list, words []string) bool {
+	for _, w := range words {
+		if !containsFold(list, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// properConfirmed reports whether a capitalized phrase is corroborated by a
+// second mention or by a word shared with an already-extracted entity.
+func properConfirmed(phrase, content string, found []Entity) bool {
+	if strings.Count(strings.ToLower(content), strings.ToLower(phrase)) > 1 {
+		return true
+	}
+	for _, ent := range found {
+		for _, w := range strings.Fields(phrase) {
+			if containsFold(strings.Fields(ent.Text), w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // isCommonPhrase filters out false-positive proper nouns.
 func isCommonPhrase(s string) bool {
 	common := []string{
@@ -85,18 +210,31 @@ func isCommonPhrase(s string) bool {
 
 // --- Waypoint graph expansion ---
 
+// defaultLinkHopWeight is the link weight one shared waypoint gives a
+// graph-expanded memory unless Config.LinkHopWeight overrides it.
+const defaultLinkHopWeight = 0.8
+
 // ExpandViaWaypoints performs one-hop graph expansion from seed memories.
-// Returns additional memories linked through shared waypoints (entities).
+// Returns additional memories linked through shared waypoints (entities),
+// weighted by how many distinct waypoints they share with the seeds.
 func ExpandViaWaypoints(store *Store, seedMemories []memoryWithVector, userID string) map[int64]float64 {
-	linkWeights := make(map[int64]float64)
+	return ExpandViaWaypointsWithWeight(store, seedMemories, userID, defaultLinkHopWeight)
+}
 
+// ExpandViaWaypointsWithWeight is ExpandViaWaypoints with the per-hop weight
+// given: one shared waypoint yields hopWeight, and n of them yield
+// 1-(1-hopWeight)^n. hopWeight should be in [0, 1].
+func ExpandViaWaypointsWithWeight(store *Store, seedMemories []memoryWithVector, userID string, hopWeight float64) map[int64]float64 {
 	// Collect seed memory IDs
 	seedIDs := make(map[int64]bool)
 	for _, m := range seedMemories {
 		seedIDs[m.ID] = true
 	}
 
-	// For each seed memory, get its waypoints, then get other memories sharing those waypoints
+	// For each seed memory, get its waypoints, then get other memories sharing
+	// those waypoints. A waypoint shared by several seeds is only expanded once.
+	visited := make(map[int64]bool)
+	shared := make(map[int64]int)
 	for _, m := range seedMemories {
 		waypointIDs, err := store.GetAssociatedWaypointIDs(m.ID)
 		if err != nil {
@@ -104,18 +242,24 @@ func ExpandViaWaypoints(store *Store, seedMemories []memoryWithVector, userID st
 		}
 
 		for _, wpID := range waypointIDs {
+			if visited[wpID] {
+				continue
+			}
+			visited[wpID] = true
+
 			linked, err := store.GetMemoriesByWaypoint(wpID, userID, seedIDs)
 			if err != nil {
 				continue
 			}
 			for _, lm := range linked {
-				// Propagate link weight: 0.8 multiplier per hop
-				if w := 0.8; w > linkWeights[lm.ID] {
-					linkWeights[lm.ID] = w
-				}
+				shared[lm.ID]++
 			}
 		}
 	}
 
+	linkWeights := make(map[int64]float64, len(shared))
+	for id, n := range shared {
+		linkWeights[id] = 1 - math.Pow(1-hopWeight, float64(n))
+	}
 	return linkWeights
 }
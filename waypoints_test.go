@@ -1,6 +1,9 @@
 package engram
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestExtractBracketNames(t *testing.T) {
 	e := &DefaultEntityExtractor{}
@@ -103,3 +106,106 @@ func TestExtractCommonPhrasesFiltered(t *testing.T) {
 		}
 	}
 }
+
+func entityTexts(entities []Entity) map[string]bool {
+	texts := make(map[string]bool, len(entities))
+	for _, ent := range entities {
+		texts[ent.Text] = true
+	}
+	return texts
+}
+
+func TestExtractFiltersFalseProperNouns(t *testing.T) {
+	e := &DefaultEntityExtractor{}
+	got := entityTexts(e.Extract("we met at Harajuku Station Last Weekend and talked about the Jazz Scene"))
+
+	if !got["Harajuku Station"] {
+		t.Errorf("expected 'Harajuku Station' to be extracted, got %v", got)
+	}
+	for _, bogus := range []string{"Last Weekend", "Weekend", "Jazz Scene"} {
+		if got[bogus] {
+			t.Errorf("expected %q to be filtered, got %v", bogus, got)
+		}
+	}
+}
+
+func TestExtractProperNounStopwordSplitting(t *testing.T) {
+	e := &DefaultEntityExtractor{}
+	got := entityTexts(e.Extract("she plays at The Blue Note Tokyo tonight"))
+	if !got["Blue Note Tokyo"] {
+		t.Errorf("expected the phrase split at 'The' into 'Blue Note Tokyo', got %v", got)
+	}
+
+	e = &DefaultEntityExtractor{MaxProperTokens: 2}
+	got = entityTexts(e.Extract("she plays at The Blue Note Tokyo tonight"))
+	if len(got) != 0 {
+		t.Errorf("expected a 3-word phrase rejected with MaxProperTokens 2, got %v", got)
+	}
+}
+
+func TestExtractRequireProperConfirmation(t *testing.T) {
+	e := &DefaultEntityExtractor{RequireProperConfirmation: true}
+
+	got := entityTexts(e.Extract("we met at Harajuku Station once"))
+	if got["Harajuku Station"] {
+		t.Errorf("expected a single uncorroborated mention to be dropped, got %v", got)
+	}
+
+	got = entityTexts(e.Extract("Harajuku Station was packed, so we left Harajuku Station early"))
+	if !got["Harajuku Station"] {
+		t.Errorf("expected a repeated phrase to be confirmed, got %v", got)
+	}
+
+	got = entityTexts(e.Extract(`they sang "Harajuku" by the gates of Harajuku Station`))
+	if !got["Harajuku Station"] {
+		t.Errorf("expected a phrase sharing a word with a quoted entity to be confirmed, got %v", got)
+	}
+}
+
+func TestExpandViaWaypointsGradesBySharedEntities(t *testing.T) {
+	s := testStore(t)
+
+	seed, _ := s.InsertMemory(Memory{Content: "Alex and Lily at Club Mutant", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1"})
+	both, _ := s.InsertMemory(Memory{Content: "Alex told Lily a secret", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1"})
+	one, _ := s.InsertMemory(Memory{Content: "Alex ordered a drink", Sector: SectorEpisodic, Salience: 0.5, UserID: "u1"})
+
+	alex, _ := s.UpsertWaypoint("Alex", "person")
+	lily, _ := s.UpsertWaypoint("Lily", "person")
+	s.InsertAssociation(seed, alex, 0.5)
+	s.InsertAssociation(seed, lily, 0.5)
+	s.InsertAssociation(both, alex, 0.5)
+	s.InsertAssociation(both, lily, 0.5)
+	s.InsertAssociation(one, alex, 0.5)
+
+	weights := ExpandViaWaypoints(s, []memoryWithVector{{Memory: Memory{ID: seed}}}, "u1")
+
+	if w := weights[one]; math.Abs(w-0.8) > 1e-9 {
+		t.Errorf("expected single shared entity to give 0.8, got %.3f", w)
+	}
+	if weights[both] <= weights[one] {
+		t.Errorf("expected two shared entities (%.3f) to outweigh one (%.3f)", weights[both], weights[one])
+	}
+	if _, ok := weights[seed]; ok {
+		t.Error("seed memory should not receive a link weight")
+	}
+
+	weights = ExpandViaWaypointsWithWeight(s, []memoryWithVector{{Memory: Memory{ID: seed}}}, "u1", 0.5)
+	if w := weights[both]; math.Abs(w-0.75) > 1e-9 {
+		t.Errorf("expected two shared entities at hop weight 0.5 to give 0.75, got %.3f", w)
+	}
+}
+
+func TestLinkHopWeightKeptInRange(t *testing.T) {
+	for _, tc := range []struct{ in, want float64 }{
+		{0, 0.8},
+		{-0.5, 0.8},
+		{0.3, 0.3},
+		{1.5, 1},
+	} {
+		cfg := Config{LinkHopWeight: tc.in}
+		cfg.ApplyDefaults()
+		if cfg.LinkHopWeight != tc.want {
+			t.Errorf("LinkHopWeight %v: expected %v, got %v", tc.in, tc.want, cfg.LinkHopWeight)
+		}
+	}
+}
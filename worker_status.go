@@ -0,0 +1,64 @@
+package engram
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Background worker names reported by WorkerStatus.
+const (
+	WorkerDecay      = "decay"
+	WorkerReflection = "reflection"
+)
+
+// WorkerStatus is a snapshot of a background worker's health.
+type WorkerStatus struct {
+	LastRun           time.Time // when the most recent cycle started (zero = never ran)
+	LastError         error     // error from the most recent failed cycle, nil once a cycle succeeds
+	ConsecutiveErrors int       // failed cycles since the last success
+}
+
+// WorkerStatus returns the health of each background worker that has run at
+// least one cycle, keyed by WorkerDecay / WorkerReflection.
+func (cm *Engram) WorkerStatus() map[string]WorkerStatus {
+	cm.statusMu.Lock()
+	defer cm.statusMu.Unlock()
+	out := make(map[string]WorkerStatus, len(cm.status))
+	for name, s := range cm.status {
+		out[name] = s
+	}
+	return out
+}
+
+// runWorkerCycle runs one worker cycle, recording its outcome and converting
+// a panic into an error so a bad cycle can't silently kill the goroutine.
+func (cm *Engram) runWorkerCycle(name string, cycle func() error) {
+	start := cm.config.Clock.Now()
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("engram: %s worker panic: %v", name, r)
+				log.Printf("[engram] %v", err)
+			}
+		}()
+		err = cycle()
+	}()
+
+	cm.statusMu.Lock()
+	defer cm.statusMu.Unlock()
+	if cm.status == nil {
+		cm.status = make(map[string]WorkerStatus)
+	}
+	s := cm.status[name]
+	s.LastRun = start
+	if err != nil {
+		s.LastError = err
+		s.ConsecutiveErrors++
+	} else {
+		s.LastError = nil
+		s.ConsecutiveErrors = 0
+	}
+	cm.status[name] = s
+}